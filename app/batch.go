@@ -0,0 +1,180 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	fhr "github.com/buaazp/fasthttprouter"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+type batchRequest struct {
+	// Path is the path and query string of a GET request to the API, e.g.
+	// /api/v1/users/full?id=1000.
+	Path string `json:"path" validate:"required"`
+}
+
+type batchData struct {
+	Requests []batchRequest `json:"requests" validate:"required"`
+}
+
+type batchResult struct {
+	Path   string          `json:"path"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+type batchResponse struct {
+	common.ResponseBase
+	Responses []batchResult `json:"responses"`
+}
+
+var batchDoc = common.RouteDoc{
+	Summary: "Make several GET requests to the API at once.",
+	Description: "The requests are made concurrently, with the token of the batch request, " +
+		"and their responses are returned in the same order. " +
+		"Requests that do not finish in time get a 504 response.",
+	Request:  batchData{},
+	Response: batchResponse{},
+}
+
+// batchUserKey is the user value in which the token of a batch request is
+// given to its sub-requests, so that they do not need to resolve it again.
+const batchUserKey = "batch_user"
+
+// batchHandler returns the API method handling batch requests. Sub-requests
+// are dispatched to the GET handlers registered in router, going through
+// wrap and initialCaretaker as any other request would.
+func batchHandler(router *fhr.Router) func(md common.MethodData) common.CodeMessager {
+	return func(md common.MethodData) common.CodeMessager {
+		var d batchData
+		if errResp := md.ParseBody(&d); errResp != nil {
+			return errResp
+		}
+		settings := common.GetSettings()
+		if len(d.Requests) > settings.BATCH_MAX_REQUESTS {
+			return common.ErrTooManyBatchRequests.WithMessage("At most " + strconv.Itoa(settings.BATCH_MAX_REQUESTS) + " requests can be made in a batch.")
+		}
+
+		type indexedResult struct {
+			i int
+			batchResult
+		}
+		// the sub-requests still running when the batch returns are cancelled.
+		ctx, cancel := context.WithTimeout(md.Context, time.Duration(settings.BATCH_TIMEOUT)*time.Second)
+		defer cancel()
+		// sub-requests may outlive the handler when the batch times out, and
+		// fasthttp reuses md.Ctx as soon as it returns: they only get copies.
+		origin := newBatchOrigin(md)
+		results := make(chan indexedResult, len(d.Requests))
+		for i, br := range d.Requests {
+			go func(i int, path string) {
+				results <- indexedResult{i, runSubRequest(ctx, router, origin, i, path)}
+			}(i, br.Path)
+		}
+
+		r := batchResponse{Responses: make([]batchResult, len(d.Requests))}
+		done := make([]bool, len(d.Requests))
+	wait:
+		for range d.Requests {
+			select {
+			case res := <-results:
+				r.Responses[res.i] = res.batchResult
+				done[res.i] = true
+			case <-ctx.Done():
+				break wait
+			}
+		}
+		for i, ok := range done {
+			if !ok {
+				r.Responses[i] = batchResult{
+					Path:   d.Requests[i].Path,
+					Status: common.ErrBatchTimeout.Status,
+					Body:   marshalJSON(common.ErrBatchTimeout),
+				}
+			}
+		}
+
+		r.Code = 200
+		return r
+	}
+}
+
+// batchOrigin is what sub-requests need to know of the batch request. It
+// doesn't point into the fasthttp.RequestCtx of the batch.
+type batchOrigin struct {
+	user       common.Token
+	requestID  string
+	host       []byte
+	headers    [][2][]byte
+	remoteAddr net.Addr
+}
+
+// batchForwardedHeaders are copied to the sub-requests, so that they are rate
+// limited and logged with the right IP.
+var batchForwardedHeaders = [...]string{"X-Real-Ip", "X-Forwarded-For", "User-Agent"}
+
+func newBatchOrigin(md common.MethodData) batchOrigin {
+	o := batchOrigin{
+		user:       md.User,
+		requestID:  md.RequestID,
+		host:       append([]byte(nil), md.Ctx.Request.Host()...),
+		remoteAddr: md.Ctx.RemoteAddr(),
+	}
+	for _, h := range batchForwardedHeaders {
+		if v := md.Ctx.Request.Header.Peek(h); len(v) > 0 {
+			o.headers = append(o.headers, [2][]byte{[]byte(h), append([]byte(nil), v...)})
+		}
+	}
+	return o
+}
+
+// runSubRequest makes the i-th request of a batch, on behalf of the user of
+// origin. The sub-request is cancelled along with ctx.
+func runSubRequest(ctx context.Context, router *fhr.Router, origin batchOrigin, i int, path string) batchResult {
+	res := batchResult{Path: path}
+
+	var req fasthttp.Request
+	req.Header.SetMethod(fasthttp.MethodGet)
+	req.SetRequestURI(path)
+	// the path is checked once normalised, as /api/v1/../../metrics would be
+	// dispatched to /metrics.
+	if !strings.HasPrefix(path, "/") || !bytes.HasPrefix(req.URI().Path(), []byte("/api/v1/")) {
+		res.Status = common.ErrInvalidBatchPath.Status
+		res.Body = marshalJSON(common.ErrInvalidBatchPath)
+		return res
+	}
+	req.Header.SetHostBytes(origin.host)
+	for _, h := range origin.headers {
+		req.Header.SetBytesKV(h[0], h[1])
+	}
+	req.Header.Set("X-Request-ID", origin.requestID+"-"+strconv.Itoa(i))
+
+	var c fasthttp.RequestCtx
+	c.Init(&req, origin.remoteAddr, nil)
+	c.SetUserValue(batchUserKey, origin.user)
+	c.SetUserValue(parentContextKey, ctx)
+
+	handle, _ := router.Lookup(fasthttp.MethodGet, string(req.URI().Path()), &c)
+	if handle == nil {
+		res.Status = common.ErrRouteNotFound.Status
+		res.Body = marshalJSON(common.ErrRouteNotFound)
+		return res
+	}
+	handle(&c)
+
+	res.Status = c.Response.StatusCode()
+	body := append([]byte(nil), c.Response.Body()...)
+	if json.Valid(body) {
+		res.Body = body
+	} else {
+		res.Body, _ = json.Marshal(string(body))
+	}
+	return res
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	fhr "github.com/buaazp/fasthttprouter"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+type batchTestResponse struct {
+	common.ResponseBase
+	UserID int    `json:"user_id"`
+	X      string `json:"x"`
+}
+
+// batchTestRouter returns a router with a single GET method, answering with
+// the user making the request and the x query parameter.
+func batchTestRouter() *fhr.Router {
+	raw := fhr.New()
+	newRouter(raw).Method("/api/v1/batch_test", func(md common.MethodData) common.CodeMessager {
+		r := batchTestResponse{UserID: md.ID(), X: md.Query("x")}
+		r.Code = 200
+		return r
+	})
+	return raw
+}
+
+func batchTestMethodData(body string) common.MethodData {
+	c := &fasthttp.RequestCtx{}
+	c.Request.Header.SetMethod(fasthttp.MethodPost)
+	c.Request.SetBodyString(body)
+	return common.MethodData{
+		Ctx:       c,
+		User:      common.Token{ID: 1, UserID: 1000, Value: "token"},
+		RequestID: "batch",
+		Context:   context.Background(),
+	}
+}
+
+func TestBatch(t *testing.T) {
+	testRedis(t)
+	handler := batchHandler(batchTestRouter())
+
+	resp := handler(batchTestMethodData(`{"requests": [
+		{"path": "/api/v1/batch_test?x=1"},
+		{"path": "/api/v1/batch_test?x=2"},
+		{"path": "/api/v1/nonexistent"},
+		{"path": "/metrics"},
+		{"path": "/api/v1/../../metrics"},
+		{"path": "/api/v1/%2e%2e/%2e%2e/metrics"},
+		{"path": "http://example.com/api/v1/batch_test"}
+	]}`))
+	r, ok := resp.(batchResponse)
+	if !ok {
+		t.Fatalf("batch failed: %#v", resp)
+	}
+	wantStatus := []int{200, 200, 404, 400, 400, 400, 400}
+	if len(r.Responses) != len(wantStatus) {
+		t.Fatalf("got %d responses, want %d", len(r.Responses), len(wantStatus))
+	}
+	for i, res := range r.Responses {
+		if res.Status != wantStatus[i] {
+			t.Errorf("%s: status %d, want %d (%s)", res.Path, res.Status, wantStatus[i], res.Body)
+		}
+	}
+
+	// the sub-requests are made in order, by the user making the batch.
+	for i, res := range r.Responses[:2] {
+		var body batchTestResponse
+		if err := json.Unmarshal(res.Body, &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.UserID != 1000 || body.X != strconv.Itoa(i+1) {
+			t.Errorf("%s: got %+v", res.Path, body)
+		}
+	}
+}
+
+func TestBatchTooManyRequests(t *testing.T) {
+	handler := batchHandler(batchTestRouter())
+	var d batchData
+	for i := 0; i <= common.GetSettings().BATCH_MAX_REQUESTS; i++ {
+		d.Requests = append(d.Requests, batchRequest{Path: "/api/v1/batch_test"})
+	}
+	body, _ := json.Marshal(d)
+	resp := handler(batchTestMethodData(string(body)))
+	if resp.GetCode() != common.ErrTooManyBatchRequests.Status {
+		t.Errorf("got %#v, want %v", resp, common.ErrTooManyBatchRequests)
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+// cachePolicy describes how the responses of a route are cached in redis.
+// Only responses to anonymous requests are cached, as authenticated users
+// may be able to see more (e.g. restricted users seeing themselves).
+type cachePolicy struct {
+	// TTL is how long a response is kept in the cache.
+	TTL time.Duration
+	// Tags are the invalidation keys of the route: a call to
+	// common.InvalidateCache with any of them drops all the cached responses
+	// of the route.
+	Tags []string
+}
+
+// cachePolicies maps the routes registered through CachedMethod to their
+// cachePolicy.
+var cachePolicies = map[string]cachePolicy{}
+
+// these query parameters do not change the response, so they are not part
+// of the cache key.
+var uncachedArgs = [...]string{"callback", "token", "k"}
+
+// cacheKey generates the cache key for the request, using its route and its
+// query string with the parameters sorted.
+func cacheKey(c *fasthttp.RequestCtx, route string) string {
+	var args fasthttp.Args
+	c.QueryArgs().CopyTo(&args)
+	for _, k := range uncachedArgs {
+		args.Del(k)
+	}
+	args.Sort(bytes.Compare)
+	sum := sha1.Sum(args.QueryString())
+	return common.CacheKeyPrefix + "resp:" + route + ":" + hex.EncodeToString(sum[:])
+}
+
+// cachedResponse returns the cached response for the request, if any.
+func cachedResponse(key string) ([]byte, bool) {
+	b, err := red.Get(key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// storeResponse saves a response in the cache, and adds it to the sets of
+// each of the policy's tags so that it can be invalidated.
+func storeResponse(key string, policy cachePolicy, data []byte) {
+	p := red.Pipeline()
+	defer p.Close()
+	p.Set(key, data, policy.TTL)
+	for _, tag := range policy.Tags {
+		p.SAdd(common.CacheTagKey(tag), key)
+		p.Expire(common.CacheTagKey(tag), policy.TTL)
+	}
+	if _, err := p.Exec(); err != nil {
+		slog.Error("Error storing cached response", "error", err.Error(), "key", key)
+	}
+}
+
+func setCacheHeaders(c *fasthttp.RequestCtx, policy cachePolicy, hit bool) {
+	if hit {
+		c.Response.Header.Set("X-Cache", "HIT")
+	} else {
+		c.Response.Header.Set("X-Cache", "MISS")
+	}
+	c.Response.Header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(policy.TTL/time.Second)))
+}
+
+// etagOf generates a strong ETag for the given body.
+func etagOf(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches checks whether etag is in the value of an If-None-Match header.
+// The gzip suffix added by wrap is ignored when comparing.
+func etagMatches(ifNoneMatch []byte, etag string) bool {
+	for _, v := range bytes.Split(ifNoneMatch, []byte(",")) {
+		v = bytes.TrimSpace(v)
+		if string(v) == "*" {
+			return true
+		}
+		v = bytes.TrimPrefix(v, []byte("W/"))
+		v = bytes.Replace(v, []byte(gzipETagSuffix), nil, 1)
+		if string(v) == etag {
+			return true
+		}
+	}
+	return false
+}
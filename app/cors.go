@@ -0,0 +1,103 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+// corsAllowedHeaders are the request headers browsers may send to the API.
+var corsAllowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"X-Ripple-Token",
+	"X-Request-ID",
+	"Idempotency-Key",
+}
+
+// corsPolicy is the parsed CORS configuration from the settings.
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	allowCredentials bool
+	exposedHeaders   string
+	maxAge           string
+}
+
+var cors corsPolicy
+
+func loadCORSPolicy(s common.Settings) (corsPolicy, error) {
+	p := corsPolicy{
+		origins:          make(map[string]bool),
+		allowCredentials: s.CORS_ALLOW_CREDENTIALS,
+		exposedHeaders:   s.CORS_EXPOSED_HEADERS,
+		maxAge:           strconv.Itoa(s.CORS_MAX_AGE),
+	}
+	for _, o := range strings.Split(s.CORS_ALLOWED_ORIGINS, ",") {
+		o = strings.TrimSpace(o)
+		switch o {
+		case "":
+		case "*":
+			p.anyOrigin = true
+		default:
+			p.origins[strings.TrimSuffix(o, "/")] = true
+		}
+	}
+	if p.anyOrigin && p.allowCredentials {
+		// any website could make authenticated requests on behalf of the
+		// user.
+		return p, errors.New("CORS_ALLOW_CREDENTIALS can't be used with * in CORS_ALLOWED_ORIGINS")
+	}
+	return p, nil
+}
+
+// allowOrigin sets the CORS headers common to preflight and actual requests,
+// and returns false if the origin of the request is not allowed.
+func (p corsPolicy) allowOrigin(c *fasthttp.RequestCtx) bool {
+	h := &c.Response.Header
+	if !p.anyOrigin && len(p.origins) != 0 {
+		// the response depends on the origin, even when it is not allowed or
+		// missing, and must not be cached for other origins.
+		h.Add("Vary", "Origin")
+	}
+	origin := string(c.Request.Header.Peek("Origin"))
+	if origin == "" || (!p.anyOrigin && !p.origins[origin]) {
+		return false
+	}
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// apply sets the CORS headers on the response to an actual request.
+func (p corsPolicy) apply(c *fasthttp.RequestCtx) {
+	if p.allowOrigin(c) && p.exposedHeaders != "" {
+		c.Response.Header.Set("Access-Control-Expose-Headers", p.exposedHeaders)
+	}
+}
+
+// preflight returns a handler answering OPTIONS requests to a route allowing
+// the given methods. It must be wrapped, as wrap is what checks the origin.
+func (p corsPolicy) preflight(methods []string) fasthttp.RequestHandler {
+	allow := strings.Join(append(append([]string(nil), methods...), "OPTIONS"), ", ")
+	allowHeaders := strings.Join(corsAllowedHeaders, ", ")
+	return func(c *fasthttp.RequestCtx) {
+		c.Response.Header.Set("Allow", allow)
+		allowed := len(c.Response.Header.Peek("Access-Control-Allow-Origin")) != 0
+		if allowed && len(c.Request.Header.Peek("Access-Control-Request-Method")) != 0 {
+			c.Response.Header.Set("Access-Control-Allow-Methods", allow)
+			c.Response.Header.Set("Access-Control-Allow-Headers", allowHeaders)
+			c.Response.Header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		c.SetStatusCode(fasthttp.StatusNoContent)
+	}
+}
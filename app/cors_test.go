@@ -0,0 +1,60 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+func TestLoadCORSPolicy(t *testing.T) {
+	if _, err := loadCORSPolicy(common.Settings{CORS_ALLOWED_ORIGINS: "*", CORS_ALLOW_CREDENTIALS: true}); err == nil {
+		t.Error("any origin with credentials was accepted")
+	}
+	p, err := loadCORSPolicy(common.Settings{CORS_ALLOWED_ORIGINS: "https://a.example/, https://b.example", CORS_ALLOW_CREDENTIALS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.origins["https://a.example"] || !p.origins["https://b.example"] || p.anyOrigin {
+		t.Errorf("unexpected policy %+v", p)
+	}
+}
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name      string
+		origins   string
+		origin    string
+		allowed   bool
+		allowedAs string
+		vary      bool
+	}{
+		{"wildcard", "*", "https://a.example", true, "*", false},
+		{"wildcard without origin", "*", "", false, "", false},
+		{"listed", "https://a.example", "https://a.example", true, "https://a.example", true},
+		{"not listed", "https://a.example", "https://evil.example", false, "", true},
+		{"listed without origin", "https://a.example", "", false, "", true},
+		{"disabled", "", "https://a.example", false, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := loadCORSPolicy(common.Settings{CORS_ALLOWED_ORIGINS: tt.origins})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &fasthttp.RequestCtx{}
+			if tt.origin != "" {
+				c.Request.Header.Set("Origin", tt.origin)
+			}
+			if got := p.allowOrigin(c); got != tt.allowed {
+				t.Errorf("allowOrigin() = %v, want %v", got, tt.allowed)
+			}
+			if got := string(c.Response.Header.Peek("Access-Control-Allow-Origin")); got != tt.allowedAs {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowedAs)
+			}
+			if got := string(c.Response.Header.Peek("Vary")) == "Origin"; got != tt.vary {
+				t.Errorf("Vary: Origin sent = %v, want %v", got, tt.vary)
+			}
+		})
+	}
+}
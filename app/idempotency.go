@@ -0,0 +1,153 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+// idempotencyKeyParam documents the Idempotency-Key header of POST routes.
+var idempotencyKeyParam = common.HeaderParam("Idempotency-Key", "string",
+	"A unique key for the request, such as a UUID. If the request is retried with the same key, "+
+		"the response to the first one is sent again instead of repeating the action, "+
+		"with the Idempotent-Replayed header set.")
+
+// idempotencyExcluded are the POST routes whose responses hand out
+// credentials, which must not be kept in redis to be replayed: they ignore
+// the Idempotency-Key header.
+var idempotencyExcluded = map[string]bool{
+	"/api/v1/tokens":          true,
+	"/api/v1/oauth/token":     true,
+	"/api/v1/oauth/authorize": true,
+	"/api/v1/oauth/clients":   true,
+}
+
+// idempotencyPendingTTL is how long the key of a request still being
+// processed is held, in case the API is stopped before it completes.
+const idempotencyPendingTTL = time.Minute
+
+// idempotentResponse is what is stored in redis for an Idempotency-Key. Status
+// is 0 while the first request is being processed.
+type idempotentResponse struct {
+	// Fingerprint identifies the request, so that a key is not used for two
+	// different requests.
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// idempotentRequest is a POST request made with an Idempotency-Key, whose
+// response must be stored once the method has run.
+type idempotentRequest struct {
+	key         string
+	fingerprint string
+	ttl         time.Duration
+}
+
+// beginIdempotent handles the Idempotency-Key header of authenticated POST
+// requests. If the key was already used by the user, the stored response is
+// written and handled is true. Otherwise, the key is reserved, and the
+// returned idempotentRequest must be given the response through finish.
+// req is nil if the request does not use an Idempotency-Key, or is made to
+// one of the idempotencyExcluded routes.
+func beginIdempotent(c *fasthttp.RequestCtx, md common.MethodData) (req *idempotentRequest, handled bool) {
+	key := c.Request.Header.Peek("Idempotency-Key")
+	ttl := time.Duration(common.GetSettings().IDEMPOTENCY_TTL) * time.Second
+	if !c.IsPost() || len(key) == 0 || md.ID() == 0 || ttl <= 0 || idempotencyExcluded[routeOf(c)] {
+		return nil, false
+	}
+	if !validIdempotencyKey(key) {
+		writeError(c, md, common.ErrInvalidIdempotencyKey)
+		return nil, true
+	}
+
+	keySum := sha1.Sum(key)
+	bodySum := sha1.Sum(c.Request.Body())
+	req = &idempotentRequest{
+		key:         common.CacheKeyPrefix + "idempotency:" + strconv.Itoa(md.ID()) + ":" + hex.EncodeToString(keySum[:]),
+		fingerprint: string(c.Path()) + ":" + hex.EncodeToString(bodySum[:]),
+		ttl:         ttl,
+	}
+
+	pending, _ := json.Marshal(idempotentResponse{Fingerprint: req.fingerprint})
+	reserved, err := red.SetNX(req.key, pending, idempotencyPendingTTL).Result()
+	if err != nil {
+		// better to risk running the method twice than not at all.
+		slog.Error("Error reserving idempotency key", "error", err.Error(), "key", req.key)
+		return nil, false
+	}
+	if reserved {
+		return req, false
+	}
+
+	var stored idempotentResponse
+	b, err := red.Get(req.key).Bytes()
+	if err == nil {
+		err = json.Unmarshal(b, &stored)
+	}
+	switch {
+	case err != nil:
+		// the key expired in the meantime, or is corrupted.
+		writeError(c, md, common.ErrIdempotencyKeyInUse)
+	case stored.Fingerprint != req.fingerprint:
+		writeError(c, md, common.ErrIdempotencyKeyReused)
+	case stored.Status == 0:
+		writeError(c, md, common.ErrIdempotencyKeyInUse)
+	default:
+		c.Response.Header.Set("Idempotent-Replayed", "true")
+		c.SetStatusCode(stored.Status)
+		setContentType(c, md)
+		writeJSON(c, stored.Body)
+	}
+	return nil, true
+}
+
+// finish stores the response to the request, to be replayed when it is
+// retried. Server errors are not stored, and the key is released so that the
+// request can be retried.
+func (r *idempotentRequest) finish(status int, data []byte) {
+	if r == nil {
+		return
+	}
+	var err error
+	if status >= 500 {
+		err = red.Del(r.key).Err()
+	} else {
+		b, _ := json.Marshal(idempotentResponse{
+			Fingerprint: r.fingerprint,
+			Status:      status,
+			Body:        data,
+		})
+		err = red.Set(r.key, b, r.ttl).Err()
+	}
+	if err != nil {
+		slog.Error("Error storing idempotent response", "error", err.Error(), "key", r.key)
+	}
+}
+
+// validIdempotencyKey checks that an Idempotency-Key is at most 255 printable
+// ASCII characters.
+func validIdempotencyKey(key []byte) bool {
+	if len(key) > 255 {
+		return false
+	}
+	for _, ch := range key {
+		if ch < ' ' || ch > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeError(c *fasthttp.RequestCtx, md common.MethodData, e common.APIError) {
+	c.SetStatusCode(e.Status)
+	setContentType(c, md)
+	mkjson(c, e)
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+// idempotentTestRequest returns a POST request by the user 1000, with the
+// given Idempotency-Key and body.
+func idempotentTestRequest(key, body string) (*fasthttp.RequestCtx, common.MethodData) {
+	c := &fasthttp.RequestCtx{}
+	c.Request.Header.SetMethod(fasthttp.MethodPost)
+	c.Request.SetRequestURI("/api/v1/friends/add")
+	c.Request.Header.Set("Idempotency-Key", key)
+	c.Request.SetBodyString(body)
+	return c, common.MethodData{Ctx: c, User: common.Token{ID: 1, UserID: 1000}}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	testRedis(t)
+
+	c, md := idempotentTestRequest("key", `{"user":1001}`)
+	req, handled := beginIdempotent(c, md)
+	if req == nil || handled {
+		t.Fatalf("first request: got %v, %v, want the key to be reserved", req, handled)
+	}
+
+	// retried while the first one is still running.
+	c, md = idempotentTestRequest("key", `{"user":1001}`)
+	if _, handled := beginIdempotent(c, md); !handled || c.Response.StatusCode() != common.ErrIdempotencyKeyInUse.Status {
+		t.Errorf("concurrent retry: got status %d, want %d", c.Response.StatusCode(), common.ErrIdempotencyKeyInUse.Status)
+	}
+
+	body := []byte(`{"code":200,"friend":1001}`)
+	req.finish(200, body)
+
+	c, md = idempotentTestRequest("key", `{"user":1001}`)
+	if _, handled := beginIdempotent(c, md); !handled {
+		t.Fatal("retry was not replayed")
+	}
+	if c.Response.StatusCode() != 200 || string(c.Response.Body()) != string(body) {
+		t.Errorf("replay: got %d %s, want 200 %s", c.Response.StatusCode(), c.Response.Body(), body)
+	}
+	if string(c.Response.Header.Peek("Idempotent-Replayed")) != "true" {
+		t.Error("replay: Idempotent-Replayed is not set")
+	}
+
+	// other users have their own keys.
+	c, md = idempotentTestRequest("key", `{"user":1001}`)
+	md.User = common.Token{ID: 2, UserID: 1002}
+	if req, handled := beginIdempotent(c, md); req == nil || handled {
+		t.Error("the key of another user was used")
+	}
+}
+
+func TestIdempotencyConflict(t *testing.T) {
+	testRedis(t)
+
+	c, md := idempotentTestRequest("key", `{"user":1001}`)
+	req, _ := beginIdempotent(c, md)
+	req.finish(200, []byte(`{"code":200}`))
+
+	c, md = idempotentTestRequest("key", `{"user":1002}`)
+	if _, handled := beginIdempotent(c, md); !handled || c.Response.StatusCode() != common.ErrIdempotencyKeyReused.Status {
+		t.Errorf("different body: got status %d, want %d", c.Response.StatusCode(), common.ErrIdempotencyKeyReused.Status)
+	}
+
+	c, md = idempotentTestRequest("key", `{"user":1001}`)
+	c.Request.SetRequestURI("/api/v1/friends/del")
+	if _, handled := beginIdempotent(c, md); !handled || c.Response.StatusCode() != common.ErrIdempotencyKeyReused.Status {
+		t.Errorf("different path: got status %d, want %d", c.Response.StatusCode(), common.ErrIdempotencyKeyReused.Status)
+	}
+}
+
+func TestIdempotencyServerError(t *testing.T) {
+	testRedis(t)
+
+	c, md := idempotentTestRequest("key", `{}`)
+	req, _ := beginIdempotent(c, md)
+	req.finish(500, []byte(`{"code":500}`))
+
+	// server errors are not stored, so that the request can be retried.
+	c, md = idempotentTestRequest("key", `{}`)
+	if req, handled := beginIdempotent(c, md); req == nil || handled {
+		t.Error("the key is still held after a server error")
+	}
+}
+
+func TestIdempotencyIgnored(t *testing.T) {
+	testRedis(t)
+
+	c, md := idempotentTestRequest("key", `{}`)
+	md.User = common.Token{}
+	if req, handled := beginIdempotent(c, md); req != nil || handled {
+		t.Error("anonymous request used an Idempotency-Key")
+	}
+
+	c, md = idempotentTestRequest("", `{}`)
+	if req, handled := beginIdempotent(c, md); req != nil || handled {
+		t.Error("request without Idempotency-Key was handled")
+	}
+
+	// the responses of these routes hold credentials.
+	c, md = idempotentTestRequest("key", `{}`)
+	c.Request.SetRequestURI("/api/v1/oauth/clients")
+	c.SetUserValue(common.RouteKey, "/api/v1/oauth/clients")
+	if req, handled := beginIdempotent(c, md); req != nil || handled {
+		t.Error("request to a route returning credentials used an Idempotency-Key")
+	}
+
+	c, md = idempotentTestRequest("key\x01", `{}`)
+	if _, handled := beginIdempotent(c, md); !handled || c.Response.StatusCode() != common.ErrInvalidIdempotencyKey.Status {
+		t.Errorf("invalid key: got status %d, want %d", c.Response.StatusCode(), common.ErrInvalidIdempotencyKey.Status)
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"gopkg.in/redis.v5"
+)
+
+func TestMain(m *testing.M) {
+	// LoadSettings panics if the settings without a default are not set.
+	for _, k := range []string{
+		"APP_PORT", "APP_DOMAIN", "HANAYO_KEY", "OSU_API_KEY",
+		"DB_SCHEME", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASS", "DB_NAME",
+		"REDIS_HOST", "REDIS_PORT", "REDIS_PASS", "REDIS_DB", "REDIS_USE_SSL", "REDIS_SSL_SERVER_NAME",
+		"DISCORD_CLIENT_ID", "DISCORD_CLIENT_SECRET", "DISCORD_REDIRECT_URI",
+		"TWITCH_CLIENT_ID", "TWITCH_CLIENT_SECRET", "TWITCH_REDIRECT_URI",
+		"OSU_OAUTH_CLIENT_ID", "OSU_OAUTH_CLIENT_SECRET", "OSU_OAUTH_REDIRECT_URI",
+	} {
+		if _, ok := os.LookupEnv(k); !ok {
+			os.Setenv(k, "")
+		}
+	}
+	common.LoadSettings()
+	os.Exit(m.Run())
+}
+
+// testRedis points red to an in-memory redis server for the duration of the
+// test.
+func testRedis(t *testing.T) *miniredis.Miniredis {
+	s := miniredis.RunT(t)
+	red = redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() {
+		red.Close()
+		red = nil
+	})
+	return s
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/exp/slog"
+)
+
+// systemSettingsInterval is how often the system settings are reloaded from
+// the database, so that all the API instances see the changes made through
+// any of them.
+const systemSettingsInterval = 10 * time.Second
+
+// maintenanceExempt are the routes that keep working during maintenance: the
+// health checks, the global alerts announcing the maintenance, and logging in,
+// as staff without a token need one to make requests during maintenance, or
+// to turn it off.
+var maintenanceExempt = map[string]bool{
+	"/_health":            true,
+	"/api/status":         true,
+	"/api/v1/alerts":      true,
+	"/api/v1/tokens":      true,
+	"/api/v1/oauth/token": true,
+}
+
+// maintenanceReadOnly are the POST routes that do not change any data, and
+// are thus allowed when maintenance only refuses writes.
+var maintenanceReadOnly = map[string]bool{
+	"/api/v1/batch": true,
+}
+
+// inMaintenance tells whether a request must be refused because maintenance
+// mode is on. Staff can still make any request; the token is looked up to
+// know that, and kept for initialCaretaker.
+func inMaintenance(c *fasthttp.RequestCtx, route string) (bool, error) {
+	s := common.GetSystemSettings()
+	if !s.Maintenance || maintenanceExempt[route] || c.IsOptions() {
+		return false, nil
+	}
+	if s.MaintenanceScope != common.MaintenanceAll &&
+		(c.IsGet() || c.IsHead() || maintenanceReadOnly[route]) {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(requestsContext, tokenLookupTimeout)
+	defer cancel()
+	t, err := resolveToken(ctx, c)
+	if err != nil {
+		return true, err
+	}
+	return t.UserPrivileges&common.AdminPrivilegeAccessRAP == 0, nil
+}
+
+// writeMaintenance answers a request refused because of maintenance mode.
+func writeMaintenance(c *fasthttp.RequestCtx) {
+	c.Response.Header.Set("Retry-After", "60")
+	writeError(c, common.MethodData{Ctx: c}, common.ErrMaintenance)
+}
+
+// systemSettingsLoader reloads the system settings every
+// systemSettingsInterval, until ctx is done.
+func systemSettingsLoader(ctx context.Context, db *sqlx.DB) {
+	t := time.NewTicker(systemSettingsInterval)
+	defer t.Stop()
+	for {
+		if _, err := common.LoadSystemSettings(ctx, db); err != nil && ctx.Err() == nil {
+			slog.Error("Error loading the system settings", "error", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
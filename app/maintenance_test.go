@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+// testMaintenance turns maintenance mode on with the given scope for the
+// duration of the test, and points db to a mocked database, with an empty
+// token cache.
+func testMaintenance(t *testing.T, scope string) sqlmock.Sqlmock {
+	loadTestSystemSettings(t, 1, scope)
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = sqlx.NewDb(mockDB, "mysql")
+	resolvedTokens = newTokenCache(time.Minute, 10)
+	t.Cleanup(func() {
+		loadTestSystemSettings(t, 0, common.MaintenanceWrites)
+		mockDB.Close()
+		db = nil
+		resolvedTokens = nil
+	})
+	return mock
+}
+
+func loadTestSystemSettings(t *testing.T, maintenance int, scope string) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	mock.ExpectQuery("SELECT name, value_int, value_string FROM system_settings").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "value_int", "value_string"}).
+			AddRow("website_maintenance", maintenance, "").
+			AddRow("api_maintenance_scope", 0, scope))
+	if _, err := common.LoadSystemSettings(context.Background(), sqlx.NewDb(mockDB, "mysql")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func maintenanceTestRequest(method, path, token string) *fasthttp.RequestCtx {
+	c := &fasthttp.RequestCtx{}
+	c.Request.Header.SetMethod(method)
+	c.Request.SetRequestURI(path)
+	if token != "" {
+		c.Request.Header.Set("X-Ripple-Token", token)
+	}
+	return c
+}
+
+func TestMaintenanceLogin(t *testing.T) {
+	testMaintenance(t, common.MaintenanceAll)
+
+	for _, route := range []string{"/api/v1/tokens", "/api/v1/oauth/token"} {
+		c := maintenanceTestRequest(fasthttp.MethodPost, route, "")
+		if refused, err := inMaintenance(c, route); refused || err != nil {
+			t.Errorf("%s: got %v, %v, want the request to be allowed", route, refused, err)
+		}
+	}
+	c := maintenanceTestRequest(fasthttp.MethodGet, "/api/v1/users", "")
+	if refused, _ := inMaintenance(c, "/api/v1/users"); !refused {
+		t.Error("anonymous request was allowed")
+	}
+}
+
+func TestMaintenanceTokenError(t *testing.T) {
+	mock := testMaintenance(t, common.MaintenanceAll)
+
+	mock.ExpectQuery("FROM tokens").WillReturnError(errors.New("connection refused"))
+	c := maintenanceTestRequest(fasthttp.MethodPost, "/api/v1/friends/add", "dberror")
+	if refused, err := inMaintenance(c, "/api/v1/friends/add"); !refused || err == nil {
+		t.Errorf("got %v, %v, want the request to be refused with an error", refused, err)
+	}
+}
+
+func TestMaintenanceTokenReused(t *testing.T) {
+	mock := testMaintenance(t, common.MaintenanceAll)
+
+	mock.ExpectQuery("FROM tokens").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "user", "privileges", "private", "privileges", "expires_at"}).
+			AddRow(1, 1000, common.PrivilegeRead, false, common.AdminPrivilegeAccessRAP, nil))
+	c := maintenanceTestRequest(fasthttp.MethodPost, "/api/v1/friends/add", "staff")
+	if refused, err := inMaintenance(c, "/api/v1/friends/add"); refused || err != nil {
+		t.Fatalf("got %v, %v, want the request of staff to be allowed", refused, err)
+	}
+	// initialCaretaker gets the token without querying the database again.
+	if tok, err := resolveToken(context.Background(), c); err != nil || tok.UserID != 1000 {
+		t.Errorf("got %+v, %v, want the token of the user 1000", tok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
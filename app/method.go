@@ -1,10 +1,13 @@
 package app
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 	"unsafe"
 
 	"golang.org/x/exp/slog"
@@ -13,83 +16,213 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
-// Method wraps an API method to a HandlerFunc.
-func Method(f func(md common.MethodData) common.CodeMessager, privilegesNeeded ...int) fasthttp.RequestHandler {
+// Method wraps an API method to a HandlerFunc. The token of the request must
+// have all of scopesNeeded. The method has REQUEST_TIMEOUT to complete.
+func Method(f func(md common.MethodData) common.CodeMessager, scopesNeeded ...common.Scope) fasthttp.RequestHandler {
+	return timedMethod(f, 0, scopesNeeded...)
+}
+
+// timedMethod is like Method, but the method has the given time to complete,
+// if not zero.
+func timedMethod(f func(md common.MethodData) common.CodeMessager, timeout time.Duration, scopesNeeded ...common.Scope) fasthttp.RequestHandler {
 	return func(c *fasthttp.RequestCtx) {
-		initialCaretaker(c, f, privilegesNeeded...)
+		initialCaretaker(c, f, timeout, scopesNeeded...)
 	}
 }
 
-func initialCaretaker(c *fasthttp.RequestCtx, f func(md common.MethodData) common.CodeMessager, privilegesNeeded ...int) {
+// requestsContext is the context the requests derive theirs from. It is
+// cancelled by AbortRequests.
+//
+// Requests are not cancelled when the client disconnects, as fasthttp only
+// reads from the connection between requests, and thus can't notice it.
+var requestsContext, abortRequests = context.WithCancel(context.Background())
+
+// AbortRequests cancels the context of all the requests in flight, stopping
+// their database queries. It is meant to be called on shutdown, once the
+// requests were given the time they could have to complete.
+func AbortRequests() {
+	abortRequests()
+}
+
+// parentContextKey is the user value holding the context a request must
+// derive its own from, when it is made on behalf of another one, such as the
+// sub-requests of a batch.
+const parentContextKey = "parent_context"
+
+// requestToken finds the token sent with a request, and whether it is an
+// OAuth bearer token.
+func requestToken(c *fasthttp.RequestCtx) (token string, bearer bool) {
 	qa := c.Request.URI().QueryArgs()
-	var token string
-	var bearerToken bool
 	switch {
 	case len(c.Request.Header.CookieBytes([]byte("X-Ripple-Token"))) > 0:
-		token = string(c.Request.Header.CookieBytes([]byte("X-Ripple-Token")))
+		return string(c.Request.Header.CookieBytes([]byte("X-Ripple-Token"))), false
 	case len(c.Request.Header.Peek("X-Ripple-Token")) > 0:
-		token = string(c.Request.Header.Peek("X-Ripple-Token"))
+		return string(c.Request.Header.Peek("X-Ripple-Token")), false
 	case strings.HasPrefix(string(c.Request.Header.Peek("Authorization")), "Bearer "):
-		token = strings.TrimPrefix(string(c.Request.Header.Peek("Authorization")), "Bearer ")
-		bearerToken = true
+		return strings.TrimPrefix(string(c.Request.Header.Peek("Authorization")), "Bearer "), true
 	case len(qa.Peek("token")) > 0:
-		token = string(qa.Peek("token"))
+		return string(qa.Peek("token")), false
 	case len(qa.Peek("k")) > 0:
-		token = string(qa.Peek("k"))
+		return string(qa.Peek("k")), false
 	default:
-		token = string(c.Request.Header.Cookie("rt"))
+		return string(c.Request.Header.Cookie("rt")), false
+	}
+}
+
+// tokenLookupTimeout is how long resolving a token can take when it is done
+// outside of initialCaretaker, which uses the timeout of the route.
+const tokenLookupTimeout = 2 * time.Second
+
+// resolvedTokenKey is the user value in which resolveToken keeps the token it
+// looked up, so that it is done only once per request.
+const resolvedTokenKey = "resolved_token"
+
+// resolveToken returns the token of the user making a request, or an empty
+// one if the request is anonymous or its token is not valid.
+func resolveToken(ctx context.Context, c *fasthttp.RequestCtx) (common.Token, error) {
+	if t, ok := c.UserValue(batchUserKey).(common.Token); ok {
+		// sub-request of a batch, whose token was already resolved.
+		return t, nil
+	}
+	if t, ok := c.UserValue(resolvedTokenKey).(common.Token); ok {
+		return t, nil
+	}
+	token, bearer := requestToken(c)
+	if token == "" {
+		return common.Token{}, nil
+	}
+	var (
+		t      common.Token
+		exists bool
+		err    error
+	)
+	if bearer {
+		t, exists, err = BearerToken(ctx, token, db)
+	} else {
+		t, exists, err = GetTokenFull(ctx, token, db)
+	}
+	if err != nil {
+		return common.Token{}, err
+	}
+	if !exists {
+		t = common.Token{}
 	}
+	c.SetUserValue(resolvedTokenKey, t)
+	return t, nil
+}
+
+func initialCaretaker(c *fasthttp.RequestCtx, f func(md common.MethodData) common.CodeMessager, timeout time.Duration, scopesNeeded ...common.Scope) {
+	qa := c.Request.URI().QueryArgs()
+
+	// the RequestCtx is a context too, but it is cancelled as soon as the
+	// server starts shutting down, while requests in flight should be given
+	// time to complete.
+	parent := requestsContext
+	if p, ok := c.UserValue(parentContextKey).(context.Context); ok {
+		parent = p
+	}
+	if timeout == 0 {
+		timeout = time.Duration(common.GetSettings().REQUEST_TIMEOUT) * time.Second
+	}
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
 
 	md := common.MethodData{
-		DB:  db,
-		Ctx: c,
-		R:   red,
-	}
-	if token != "" {
-		var (
-			tokenReal common.Token
-			exists    bool
-		)
-		if bearerToken {
-			tokenReal, exists = BearerToken(token, db)
-		} else {
-			tokenReal, exists = GetTokenFull(token, db)
-		}
-		if exists {
-			md.User = tokenReal
-		}
+		DB:        db,
+		Ctx:       c,
+		R:         red,
+		RequestID: common.RequestID(c),
+		Fields:    common.ParseFields(string(qa.Peek("fields"))),
+		Context:   ctx,
 	}
+	if ipRateLimited(c, routeOf(c)) {
+		return
+	}
+	user, err := resolveToken(ctx, c)
+	if err != nil {
+		md.Err(err)
+		writeError(c, md, common.ErrInternal)
+		return
+	}
+	md.User = user
+	c.SetUserValue(userKey, md.User)
 
-	missingPrivileges := 0
-	for _, privilege := range privilegesNeeded {
-		if uint64(md.User.TokenPrivileges)&uint64(privilege) == 0 {
-			missingPrivileges |= privilege
+	if rateLimited(c, routeOf(c), md.User) {
+		return
+	}
+
+	var missingScopes []string
+	for _, scope := range scopesNeeded {
+		if !md.User.HasScope(scope) {
+			missingScopes = append(missingScopes, string(scope))
 		}
 	}
-	if missingPrivileges != 0 {
+	if len(missingScopes) != 0 {
 		slog.Error(
-			"Denied access due to missing privileges",
+			"Denied access due to missing scopes",
 			"tokenHas", md.User.TokenPrivileges,
 			"userHas", md.User.UserPrivileges,
-			"missing", missingPrivileges,
+			"missing", strings.Join(missingScopes, " "),
 			"userID", md.User.UserID,
 			"route", string(c.Request.URI().Path()),
 		)
-		c.SetStatusCode(401)
-		mkjson(c, common.SimpleResponse(401, "Unauthorized."))
+		writeError(c, md, common.ErrMissingScopes)
 		return
 	}
 
+	idem, handled := beginIdempotent(c, md)
+	if handled {
+		return
+	}
+
+	policy, cacheable := cachePolicies[routeOf(c)]
+	cacheable = cacheable && md.ID() == 0
+	var key string
+	if cacheable {
+		key = cacheKey(c, routeOf(c))
+		if data, ok := cachedResponse(key); ok {
+			setCacheHeaders(c, policy, true)
+			c.SetStatusCode(200)
+			setContentType(c, md)
+			writeJSON(c, data)
+			return
+		}
+	}
+
 	resp := f(md)
+	if resp.GetCode() >= 500 && ctx.Err() != nil {
+		// the method most likely failed because its queries were cancelled.
+		resp = common.ErrRequestTimeout
+	}
 	c.SetStatusCode(resp.GetCode())
+	setContentType(c, md)
+
+	data := marshalJSON(resp)
+	if resp.GetCode() == 200 {
+		data = projectFields(md.Fields, data)
+	}
+	if cacheable && resp.GetCode() == 200 {
+		setCacheHeaders(c, policy, false)
+		storeResponse(key, policy, data)
+	}
+	idem.finish(resp.GetCode(), data)
+	writeJSON(c, data)
+}
 
+func setContentType(c *fasthttp.RequestCtx, md common.MethodData) {
 	if md.HasQuery("callback") {
 		c.Response.Header.Add("Content-Type", "application/javascript; charset=utf-8")
 	} else {
 		c.Response.Header.Add("Content-Type", "application/json; charset=utf-8")
 	}
-
-	mkjson(c, resp)
 }
 
 // Very restrictive, but this way it shouldn't completely fuck up.
@@ -98,22 +231,66 @@ var callbackJSONP = regexp.MustCompile(`^[a-zA-Z_\$][a-zA-Z0-9_\$]*$`)
 // mkjson auto indents json, and wraps json into a jsonp callback if specified by the request.
 // then writes to the RequestCtx the data.
 func mkjson(c *fasthttp.RequestCtx, data interface{}) {
+	writeJSON(c, marshalJSON(data))
+}
+
+// projectFields applies the sparse fieldset requested by the client to a
+// response. code and message are always kept.
+func projectFields(f common.Fields, data []byte) []byte {
+	if len(f) == 0 {
+		return data
+	}
+	// f is the one of the MethodData, which must not be changed.
+	withBase := make(common.Fields, len(f)+2)
+	for k, v := range f {
+		withBase[k] = v
+	}
+	withBase["code"], withBase["message"] = common.Fields{}, common.Fields{}
+	projected, err := withBase.Project(data)
+	if err != nil {
+		slog.Error("Error projecting fields", "error", err.Error())
+		return data
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, projected, "", "\t"); err != nil {
+		return projected
+	}
+	return buf.Bytes()
+}
+
+// marshalJSON auto indents json.
+func marshalJSON(data interface{}) []byte {
 	exported, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
 		slog.Error("Error marshalling JSON", "error", err.Error())
-		exported = []byte(`{ "code": 500, "message": "An unexpected error occurred." }`)
+		exported = []byte(`{ "code": 500, "error": "internal_error", "message": "An unexpected error occurred." }`)
 	}
+	return exported
+}
+
+// writeJSON wraps already marshalled json into a jsonp callback if specified
+// by the request, then writes it to the RequestCtx.
+// Successful GET requests are given a strong ETag, and if the client already
+// has the response (If-None-Match) a 304 is sent without any body.
+func writeJSON(c *fasthttp.RequestCtx, exported []byte) {
 	cb := string(c.URI().QueryArgs().Peek("callback"))
 	willcb := cb != "" &&
 		len(cb) < 100 &&
 		callbackJSONP.MatchString(cb)
 	if willcb {
-		c.Write([]byte("/**/ typeof " + cb + " === 'function' && " + cb + "("))
+		exported = append(append([]byte("/**/ typeof "+cb+" === 'function' && "+cb+"("), exported...), ");"...)
 	}
-	c.Write(exported)
-	if willcb {
-		c.Write([]byte(");"))
+
+	if c.IsGet() && c.Response.StatusCode() == 200 {
+		etag := etagOf(exported)
+		c.Response.Header.Set("ETag", etag)
+		if inm := c.Request.Header.Peek("If-None-Match"); len(inm) > 0 && etagMatches(inm, etag) {
+			c.SetStatusCode(fasthttp.StatusNotModified)
+			return
+		}
 	}
+
+	c.Write(exported)
 }
 
 // b2s converts byte slice to a string without memory allocation.
@@ -0,0 +1,18 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+func TestProjectFields(t *testing.T) {
+	f := common.ParseFields("username")
+	got := projectFields(f, []byte(`{"code":200,"username":"a","id":1000,"message":"ok"}`))
+	if want := "{\n\t\"code\": 200,\n\t\"username\": \"a\",\n\t\"message\": \"ok\"\n}"; string(got) != want {
+		t.Errorf("projectFields() = %s, want %s", got, want)
+	}
+	if len(f) != 1 {
+		t.Errorf("projectFields() changed the fields to %v", f)
+	}
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"gopkg.in/redis.v5"
+)
+
+const metricsNamespace = "akatsuki_api"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, by route and status code.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by route and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"route", "method", "status"})
+
+	panicsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "panics_recovered_total",
+		Help:      "Number of panics recovered while handling requests, by route.",
+	}, []string{"route"})
+
+	tokenCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_cache_lookups_total",
+		Help:      "Number of lookups in the in-memory token cache, by result (hit or miss).",
+	}, []string{"result"})
+)
+
+// registerMetrics registers all the collectors exposed on /metrics.
+func registerMetrics(db *sqlx.DB, red *redis.Client) {
+	prometheus.MustRegister(
+		requestsTotal,
+		requestDuration,
+		panicsTotal,
+		tokenCacheLookups,
+		collectors.NewDBStatsCollector(db.DB, "akatsuki"),
+		redisPoolCollector{red},
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_updates_queued",
+			Help:      "Number of token last-used updates waiting to be written to the database.",
+		}, func() float64 {
+			return float64(usedTokens.len())
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_updates_lag_seconds",
+			Help:      "How long the oldest token last-used update has been waiting to be written to the database.",
+		}, func() float64 {
+			return usedTokens.lag().Seconds()
+		}),
+	)
+}
+
+// observeRequest records a handled request in the request metrics.
+func observeRequest(c *fasthttp.RequestCtx, route string, took time.Duration) {
+	status := strconv.Itoa(c.Response.StatusCode())
+	method := string(c.Method())
+	requestsTotal.WithLabelValues(route, method, status).Inc()
+	requestDuration.WithLabelValues(route, method, status).Observe(took.Seconds())
+}
+
+var metricsHandler = fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+
+// MetricsHandler serves the metrics in the prometheus text format on
+// /metrics. It is meant to be served on METRICS_PORT, which only the
+// monitoring should be able to reach, rather than along with the API.
+func MetricsHandler(c *fasthttp.RequestCtx) {
+	if string(c.Path()) != "/metrics" {
+		c.NotFound()
+		return
+	}
+	metricsHandler(c)
+}
+
+var (
+	redisRequestsDesc = prometheus.NewDesc(metricsNamespace+"_redis_pool_requests_total",
+		"Number of times a connection was requested from the redis pool.", nil, nil)
+	redisHitsDesc = prometheus.NewDesc(metricsNamespace+"_redis_pool_hits_total",
+		"Number of times a free connection was found in the redis pool.", nil, nil)
+	redisTimeoutsDesc = prometheus.NewDesc(metricsNamespace+"_redis_pool_timeouts_total",
+		"Number of times a wait for a redis connection timed out.", nil, nil)
+	redisTotalConnsDesc = prometheus.NewDesc(metricsNamespace+"_redis_pool_conns",
+		"Number of connections in the redis pool.", nil, nil)
+	redisFreeConnsDesc = prometheus.NewDesc(metricsNamespace+"_redis_pool_free_conns",
+		"Number of free connections in the redis pool.", nil, nil)
+)
+
+// redisPoolCollector exposes the connection pool stats of a redis client.
+type redisPoolCollector struct {
+	client *redis.Client
+}
+
+func (r redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisRequestsDesc
+	ch <- redisHitsDesc
+	ch <- redisTimeoutsDesc
+	ch <- redisTotalConnsDesc
+	ch <- redisFreeConnsDesc
+}
+
+func (r redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := r.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisRequestsDesc, prometheus.CounterValue, float64(s.Requests))
+	ch <- prometheus.MustNewConstMetric(redisHitsDesc, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(redisTimeoutsDesc, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisTotalConnsDesc, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisFreeConnsDesc, prometheus.GaugeValue, float64(s.FreeConns))
+}
@@ -0,0 +1,450 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+// openAPIDocument generates an OpenAPI 3 document describing the routes in
+// routeTable. Schemas are derived from the Go types given in the RouteDocs.
+func openAPIDocument() map[string]interface{} {
+	g := schemaGenerator{
+		schemas:      make(map[string]interface{}),
+		names:        make(map[reflect.Type]string),
+		requestNames: make(map[reflect.Type]string),
+	}
+	paths := make(map[string]map[string]interface{})
+
+	for _, ri := range routeTable {
+		op := map[string]interface{}{
+			"operationId": strings.ToLower(ri.Method) + operationName(ri.Path),
+			"tags":        []string{routeTag(ri.Path)},
+			"responses":   g.responses(ri.Doc.Response),
+		}
+		if ri.Doc.Summary != "" {
+			op["summary"] = ri.Doc.Summary
+		}
+		if ri.Peppy {
+			op["summary"] = "osu! API compatible endpoint."
+			op["externalDocs"] = map[string]string{"url": "https://github.com/ppy/osu-api/wiki"}
+		}
+		if ri.Doc.Description != "" {
+			op["description"] = ri.Doc.Description
+		}
+
+		docParams := ri.Doc.Params
+		if ri.Method == "GET" && !ri.Peppy {
+			// all the API methods can project their response.
+			docParams = append(docParams[:len(docParams):len(docParams)], common.FieldsParam)
+		}
+		if ri.Method == "POST" && !idempotencyExcluded[ri.Path] {
+			docParams = append(docParams[:len(docParams):len(docParams)], idempotencyKeyParam)
+		}
+		if len(docParams) > 0 {
+			params := make([]interface{}, 0, len(docParams))
+			for _, p := range docParams {
+				schema := map[string]interface{}{"type": p.Type}
+				if p.Multi {
+					schema = map[string]interface{}{"type": "array", "items": schema}
+				}
+				in := p.In
+				if in == "" {
+					in = "query"
+				}
+				param := map[string]interface{}{
+					"name":     p.Name,
+					"in":       in,
+					"required": p.Required,
+					"schema":   schema,
+				}
+				if p.Description != "" {
+					param["description"] = p.Description
+				}
+				params = append(params, param)
+			}
+			op["parameters"] = params
+		}
+
+		if ri.Doc.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": g.requestSchemaOf(reflect.TypeOf(ri.Doc.Request)),
+					},
+				},
+			}
+		}
+
+		if len(ri.Scopes) > 0 {
+			scopes := make([]string, len(ri.Scopes))
+			for i, s := range ri.Scopes {
+				scopes[i] = string(s)
+			}
+			op["security"] = []interface{}{
+				map[string][]string{"token": {}},
+				map[string][]string{"oauth2": scopes},
+			}
+			op["x-scopes"] = scopes
+		}
+
+		if paths[ri.Path] == nil {
+			paths[ri.Path] = make(map[string]interface{})
+		}
+		paths[ri.Path][strings.ToLower(ri.Method)] = op
+	}
+
+	g.documentErrorCodes()
+
+	scopes := make(map[string]string)
+	for _, s := range common.Scopes() {
+		scopes[string(s.Scope)] = s.Description
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   "Akatsuki API",
+			"version": "1.0.0",
+		},
+		"servers": []map[string]string{
+			{"url": "https://" + common.GetSettings().APP_DOMAIN},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]string{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-Ripple-Token",
+				},
+				"oauth2": map[string]interface{}{
+					"type": "oauth2",
+					"flows": map[string]interface{}{
+						"authorizationCode": map[string]interface{}{
+							"authorizationUrl": "/api/v1/oauth/authorize",
+							"tokenUrl":         "/api/v1/oauth/token",
+							"refreshUrl":       "/api/v1/oauth/token",
+							"scopes":           scopes,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openAPIHandler returns a handler serving the OpenAPI document for the routes
+// registered so far.
+func openAPIHandler() fasthttp.RequestHandler {
+	doc, err := json.MarshalIndent(openAPIDocument(), "", "\t")
+	if err != nil {
+		panic(err)
+	}
+	return func(c *fasthttp.RequestCtx) {
+		c.Response.Header.SetContentType("application/json; charset=utf-8")
+		c.SetStatusCode(200)
+		c.Write(doc)
+	}
+}
+
+// routeTag groups routes by the first component of their path after the
+// version, e.g. /api/v1/users/full is in users.
+func routeTag(path string) string {
+	path = strings.TrimPrefix(path, "/api/v1/")
+	path = strings.TrimPrefix(path, "/api/")
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		path = path[:i]
+	}
+	if strings.HasPrefix(path, "get_") {
+		return "peppy"
+	}
+	return path
+}
+
+// operationName converts a path to CamelCase, e.g. /api/v1/users/scores/best
+// becomes UsersScoresBest.
+func operationName(path string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '_' || r == '-' || r == '.'
+	}) {
+		if part == "api" || part == "v1" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// schemaGenerator builds JSON schemas from Go types, the same way
+// encoding/json would serialise them. Named struct types are put in schemas
+// and referenced.
+//
+// The fields of responses are required unless they have omitempty, as they
+// are always serialised. The fields of request bodies are required when they
+// have the required validation rule, as all the others can be left out. A
+// type used for both gets two schemas.
+type schemaGenerator struct {
+	schemas      map[string]interface{}
+	names        map[reflect.Type]string
+	requestNames map[reflect.Type]string
+	// request is set while generating the schema of a request body.
+	request bool
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	unixTimestampType = reflect.TypeOf(common.UnixTimestamp{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+)
+
+func (g *schemaGenerator) responses(resp interface{}) map[string]interface{} {
+	if resp == nil {
+		resp = common.ResponseBase{}
+	}
+	return map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "Successful response.",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": g.schemaOf(reflect.TypeOf(resp)),
+				},
+			},
+		},
+		"default": map[string]interface{}{
+			"description": "Error response.",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": g.schemaOf(reflect.TypeOf(common.ResponseBase{})),
+				},
+			},
+		},
+	}
+}
+
+// documentErrorCodes lists the codes of the error catalogue in the schema of
+// ResponseBase.
+func (g *schemaGenerator) documentErrorCodes() {
+	base, ok := g.schemas[g.names[reflect.TypeOf(common.ResponseBase{})]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	var codes []string
+	var desc strings.Builder
+	desc.WriteString("The code of the error, if the request failed:\n")
+	for _, e := range common.ErrorCatalogue() {
+		codes = append(codes, e.Code)
+		fmt.Fprintf(&desc, "- `%s` (%d): %s\n", e.Code, e.Status, e.Message)
+	}
+	base["properties"].(map[string]interface{})["error"] = map[string]interface{}{
+		"type":        "string",
+		"enum":        codes,
+		"description": desc.String(),
+	}
+}
+
+// requestSchemaOf returns the schema of a request body of type t.
+func (g *schemaGenerator) requestSchemaOf(t reflect.Type) map[string]interface{} {
+	g.request = true
+	defer func() { g.request = false }()
+	return g.schemaOf(t)
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType, unixTimestampType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schemaOf(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		names := g.names
+		if g.request {
+			names = g.requestNames
+		}
+		name, ok := names[t]
+		if !ok {
+			name = g.componentName(t)
+			names[t] = name
+			// placeholder, so that recursive types terminate.
+			g.schemas[name] = nil
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// componentName finds a unique name for a type in the components.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndexByte(pkg, '/'); i != -1 {
+		pkg = pkg[i+1:]
+	}
+	name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	if _, taken := g.schemas[name]; taken && g.request {
+		// the schema of the same type as a response.
+		name += "Request"
+	}
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	g.addFields(t, props, &required)
+	sort.Strings(required)
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// addFields adds the fields of the struct t to props, following the rules of
+// encoding/json: embedded structs without a name in their tag have their
+// fields promoted.
+func (g *schemaGenerator) addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var s map[string]interface{}
+		if strings.Contains(","+opts+",", ",string,") {
+			s = map[string]interface{}{"type": "string"}
+		} else {
+			s = g.schemaOf(ft)
+		}
+		validate := f.Tag.Get("validate")
+		validationKeywords(s, validate)
+		props[name] = s
+		if g.request {
+			if hasValidationRule(validate, "required") {
+				*required = append(*required, name)
+			}
+		} else if !strings.Contains(","+opts+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// hasValidationRule tells whether a validate tag has the given rule.
+func hasValidationRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(r), "="); name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// validationKeywords adds to the schema s the keywords matching the rules of
+// a validate tag, as checked by common.ParseBody.
+func validationKeywords(s map[string]interface{}, tag string) {
+	if tag == "" {
+		return
+	}
+	minKey, maxKey := "minimum", "maximum"
+	switch s["type"] {
+	case "string":
+		minKey, maxKey = "minLength", "maxLength"
+	case "array":
+		minKey, maxKey = "minItems", "maxItems"
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		n, _ := strconv.ParseFloat(arg, 64)
+		switch rule {
+		case "min":
+			s[minKey] = n
+		case "max":
+			s[maxKey] = n
+		case "len":
+			s[minKey], s[maxKey] = n, n
+		case "numeric":
+			s["pattern"] = "^[0-9]+$"
+		case "oneof":
+			var enum []interface{}
+			for _, v := range strings.Fields(arg) {
+				if s["type"] == "integer" {
+					n, _ := strconv.Atoi(v)
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, v)
+				}
+			}
+			s["enum"] = enum
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+type openAPITestBody struct {
+	ID          int     `json:"id" validate:"required"`
+	Name        string  `json:"name" validate:"max=32"`
+	Description *string `json:"description,omitempty" validate:"required"`
+	Hidden      bool    `json:"hidden,omitempty"`
+}
+
+func TestSchemaRequired(t *testing.T) {
+	g := schemaGenerator{
+		schemas:      make(map[string]interface{}),
+		names:        make(map[reflect.Type]string),
+		requestNames: make(map[reflect.Type]string),
+	}
+	typ := reflect.TypeOf(openAPITestBody{})
+	g.requestSchemaOf(typ)
+	g.schemaOf(typ)
+
+	request := g.schemas[g.requestNames[typ]].(map[string]interface{})
+	if got, want := request["required"], []string{"description", "id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("request required = %v, want %v", got, want)
+	}
+	response := g.schemas[g.names[typ]].(map[string]interface{})
+	if got, want := response["required"], []string{"id", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("response required = %v, want %v", got, want)
+	}
+	if g.requestNames[typ] == g.names[typ] {
+		t.Errorf("request and response share the schema %s", g.names[typ])
+	}
+}
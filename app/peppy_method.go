@@ -1,13 +1,30 @@
 package app
 
 import (
+	"context"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/osuAkatsuki/akatsuki-api/common"
 	"github.com/valyala/fasthttp"
 )
 
 // PeppyMethod generates a method for the peppyapi
 func PeppyMethod(a func(c *fasthttp.RequestCtx, db *sqlx.DB)) fasthttp.RequestHandler {
 	return func(c *fasthttp.RequestCtx) {
+		if ipRateLimited(c, routeOf(c)) {
+			return
+		}
+		// the k parameter of the osu! API is the caller's token.
+		ctx, cancel := context.WithTimeout(requestsContext, tokenLookupTimeout)
+		user, err := resolveToken(ctx, c)
+		cancel()
+		if err != nil {
+			common.Err(c, err)
+		}
+		c.SetUserValue(userKey, user)
+		if rateLimited(c, routeOf(c), user) {
+			return
+		}
 
 		c.Response.Header.Add("Content-Type", "application/json; charset=utf-8")
 
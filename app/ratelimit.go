@@ -0,0 +1,256 @@
+package app
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+	"gopkg.in/redis.v5"
+)
+
+// rateLimitTier is the group a caller falls into when deciding how many
+// requests they are allowed to make.
+type rateLimitTier int
+
+const (
+	tierAnonymous rateLimitTier = iota
+	tierUser
+	tierSupporter
+	tierStaff
+	// tierIP is not the tier of a caller, but the limit of the bucket shared
+	// by all the requests made from an IP, whatever their tier.
+	tierIP
+	tierCount
+)
+
+var rateLimitTierString = [...]string{
+	"anonymous",
+	"user",
+	"supporter",
+	"staff",
+	"ip",
+}
+
+func (t rateLimitTier) String() string {
+	return rateLimitTierString[t]
+}
+
+// rateLimit describes a token bucket: Burst is the size of the bucket, and
+// Rate is how many tokens are put back into it every second.
+// A zero Rate means no limit is applied.
+type rateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// rateLimits holds a rateLimit for every tier.
+type rateLimits [tierCount]rateLimit
+
+var (
+	// defaultRateLimits apply to every route that has no entry in
+	// routeRateLimits.
+	defaultRateLimits rateLimits
+	// routeRateLimits overrides the default limits for expensive routes.
+	routeRateLimits map[string]rateLimits
+)
+
+// loadRateLimits sets the rate limits from RATE_LIMITS and
+// RATE_LIMITS_ROUTES.
+func loadRateLimits(s common.Settings) error {
+	defaults, err := parseRateLimits(s.RATE_LIMITS, rateLimits{})
+	if err != nil {
+		return fmt.Errorf("RATE_LIMITS: %w", err)
+	}
+	routes, err := parseRouteRateLimits(s.RATE_LIMITS_ROUTES, defaults)
+	if err != nil {
+		return fmt.Errorf("RATE_LIMITS_ROUTES: %w", err)
+	}
+	defaultRateLimits, routeRateLimits = defaults, routes
+	return nil
+}
+
+// parseRateLimits parses a space separated list of tier=rate/burst, such as
+// "anonymous=2/60 staff=0", on top of base. A rate of 0 means no limit.
+func parseRateLimits(s string, base rateLimits) (rateLimits, error) {
+	limits := base
+	for _, f := range strings.Fields(s) {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok {
+			return limits, fmt.Errorf("invalid limit %q", f)
+		}
+		tier := tierCount
+		for t, n := range rateLimitTierString {
+			if n == name {
+				tier = rateLimitTier(t)
+			}
+		}
+		if tier == tierCount {
+			return limits, fmt.Errorf("unknown tier %q", name)
+		}
+		rate, burst, _ := strings.Cut(value, "/")
+		var (
+			l   rateLimit
+			err error
+		)
+		if l.Rate, err = strconv.ParseFloat(rate, 64); err != nil || l.Rate < 0 {
+			return limits, fmt.Errorf("invalid rate in %q", f)
+		}
+		if l.Rate > 0 {
+			if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+				return limits, fmt.Errorf("invalid burst in %q", f)
+			}
+		}
+		limits[tier] = l
+	}
+	return limits, nil
+}
+
+// parseRouteRateLimits parses a semicolon separated list of route: limits,
+// where limits override defaults as in parseRateLimits.
+func parseRouteRateLimits(s string, defaults rateLimits) (map[string]rateLimits, error) {
+	routes := make(map[string]rateLimits)
+	for _, r := range strings.Split(s, ";") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		route, limits, ok := strings.Cut(r, ":")
+		route = strings.TrimSpace(route)
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("invalid route limits %q", r)
+		}
+		l, err := parseRateLimits(limits, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", route, err)
+		}
+		routes[route] = l
+	}
+	return routes, nil
+}
+
+func tierOf(t common.Token) rateLimitTier {
+	switch {
+	case t.UserID == 0:
+		return tierAnonymous
+	case t.UserPrivileges&common.AdminPrivilegeAccessRAP != 0:
+		return tierStaff
+	case t.UserPrivileges&(common.UserPrivilegeDonor|common.UserPrivilegePremium) != 0:
+		return tierSupporter
+	default:
+		return tierUser
+	}
+}
+
+// tokenBucket atomically takes a token from the bucket at KEYS[1], refilling
+// it first based on the time elapsed since it was last touched.
+// ARGV: rate (tokens/second), burst, current time in milliseconds.
+// Returns: allowed (0/1), tokens remaining, milliseconds until the bucket is
+// full again, milliseconds until the next token is available.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate))
+
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+return {allowed, math.floor(tokens), math.ceil((burst - tokens) * 1000 / rate), retry}
+`)
+
+// ipRateLimited takes a token from the bucket of the client's IP for route,
+// which is shared by all the requests made from it, whatever their token. It
+// is checked before the token of the request is resolved, so that requests
+// with made-up tokens are limited before they cost a database lookup, and a
+// client can't go over the limit by switching between tokens.
+// Like rateLimited, it writes a 429 response and returns true if the client
+// has gone over the limit.
+func ipRateLimited(c *fasthttp.RequestCtx, route string) bool {
+	return takeRateLimit(c, route, "ip:"+clientIP(c), routeLimits(route)[tierIP])
+}
+
+// rateLimited takes a token from the caller's bucket for route, and sets the
+// X-RateLimit-* headers on the response. If the caller has gone over their
+// limit, it writes a 429 response and returns true.
+// Authenticated callers are limited by their token, everyone else by IP.
+func rateLimited(c *fasthttp.RequestCtx, route string, user common.Token) bool {
+	var key string
+	if user.UserID != 0 {
+		// hashed, so that the token itself is not stored in redis.
+		key = "token:" + fmt.Sprintf("%x", sha256.Sum256([]byte(user.Value)))
+	} else {
+		key = "anonymous:" + clientIP(c)
+	}
+	return takeRateLimit(c, route, key, routeLimits(route)[tierOf(user)])
+}
+
+func routeLimits(route string) rateLimits {
+	if limits, ok := routeRateLimits[route]; ok {
+		return limits
+	}
+	return defaultRateLimits
+}
+
+// takeRateLimit takes a token from the bucket key of route, and writes the
+// X-RateLimit-* headers, and the 429 response if the bucket is empty.
+// If redis is unavailable, requests are let through.
+func takeRateLimit(c *fasthttp.RequestCtx, route, key string, limit rateLimit) bool {
+	if !common.GetSettings().RATE_LIMIT_ENABLED || limit.Rate <= 0 || limit.Burst <= 0 {
+		return false
+	}
+	key = "api:ratelimit:" + route + ":" + key
+
+	res, err := tokenBucket.Run(red, []string{key},
+		limit.Rate, limit.Burst, time.Now().UnixNano()/int64(time.Millisecond)).Result()
+	if err != nil {
+		slog.Error("Error checking rate limit", "error", err.Error(), "route", route)
+		return false
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 4 {
+		return false
+	}
+	allowed, _ := vals[0].(int64)
+	remaining, _ := vals[1].(int64)
+	resetMs, _ := vals[2].(int64)
+	retryMs, _ := vals[3].(int64)
+
+	h := &c.Response.Header
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	h.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(msToSeconds(resetMs), 10))
+
+	if allowed == 1 {
+		return false
+	}
+
+	h.Set("Retry-After", strconv.FormatInt(msToSeconds(retryMs), 10))
+	c.Response.Header.SetContentType("application/json; charset=utf-8")
+	c.SetStatusCode(common.ErrRateLimited.Status)
+	mkjson(c, common.ErrRateLimited)
+	return true
+}
+
+func msToSeconds(ms int64) int64 {
+	return int64(math.Ceil(float64(ms) / 1000))
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"net"
+	"testing"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	base := rateLimits{tierStaff: {Rate: 100, Burst: 100}}
+	got, err := parseRateLimits("anonymous=0.5/20 user=2/40 supporter=0", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := rateLimits{
+		tierAnonymous: {Rate: 0.5, Burst: 20},
+		tierUser:      {Rate: 2, Burst: 40},
+		tierSupporter: {},
+		tierStaff:     {Rate: 100, Burst: 100},
+	}
+	if got != want {
+		t.Errorf("parseRateLimits() = %v, want %v", got, want)
+	}
+
+	for _, s := range []string{"anonymous", "nobody=1/1", "user=x/10", "user=1", "user=1/0", "user=-1/10"} {
+		if _, err := parseRateLimits(s, base); err == nil {
+			t.Errorf("parseRateLimits(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestParseRouteRateLimits(t *testing.T) {
+	defaults := rateLimits{tierAnonymous: {Rate: 2, Burst: 60}, tierUser: {Rate: 5, Burst: 120}}
+	got, err := parseRouteRateLimits("/api/v1/a: anonymous=1/10; /api/v1/b: user=0;", defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("parseRouteRateLimits() = %v, want 2 routes", got)
+	}
+	if a := got["/api/v1/a"]; a[tierAnonymous] != (rateLimit{Rate: 1, Burst: 10}) || a[tierUser] != defaults[tierUser] {
+		t.Errorf("/api/v1/a limits = %v", a)
+	}
+	if b := got["/api/v1/b"]; b[tierAnonymous] != defaults[tierAnonymous] || b[tierUser] != (rateLimit{}) {
+		t.Errorf("/api/v1/b limits = %v", b)
+	}
+	if _, err := parseRouteRateLimits("api/v1/a: anonymous=1/10", defaults); err == nil {
+		t.Error("parseRouteRateLimits() accepted a route not starting with /")
+	}
+}
+
+func rateLimitRequest(remote string, headers ...string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	c := new(fasthttp.RequestCtx)
+	c.Init(&req, &net.TCPAddr{IP: net.ParseIP(remote)}, nil)
+	return c
+}
+
+func TestRateLimited(t *testing.T) {
+	testRedis(t)
+	defaultRateLimits = rateLimits{
+		tierAnonymous: {Rate: 1, Burst: 2},
+		tierUser:      {Rate: 1, Burst: 3},
+	}
+	routeRateLimits = nil
+
+	// an untrusted client can't get a new bucket by spoofing its address.
+	for i, want := range []bool{false, false, true} {
+		c := rateLimitRequest("203.0.113.1", "X-Forwarded-For", "198.51.100."+string(rune('1'+i)))
+		if got := rateLimited(c, "/api/v1/test", common.Token{}); got != want {
+			t.Fatalf("anonymous request %d: rateLimited() = %v, want %v", i, got, want)
+		}
+		if want {
+			if c.Response.StatusCode() != 429 || len(c.Response.Header.Peek("Retry-After")) == 0 {
+				t.Errorf("limited request: status %d, Retry-After %q", c.Response.StatusCode(), c.Response.Header.Peek("Retry-After"))
+			}
+		}
+	}
+	// but the address set by a trusted proxy is believed.
+	if rateLimited(rateLimitRequest("127.0.0.1", "X-Real-Ip", "198.51.100.1"), "/api/v1/test", common.Token{}) {
+		t.Error("request forwarded by a proxy was limited with the proxy's bucket")
+	}
+
+	// every token has its own bucket, even for the same user.
+	tokens := []common.Token{{UserID: 1000, Value: "a"}, {UserID: 1000, Value: "b"}}
+	for _, tok := range tokens {
+		for i := 0; i < 3; i++ {
+			if rateLimited(rateLimitRequest("203.0.113.2"), "/api/v1/test", tok) {
+				t.Fatalf("token %s: request %d was limited", tok.Value, i)
+			}
+		}
+		c := rateLimitRequest("203.0.113.2")
+		if !rateLimited(c, "/api/v1/test", tok) {
+			t.Fatalf("token %s: request over the burst was not limited", tok.Value)
+		}
+		if got := string(c.Response.Header.Peek("X-RateLimit-Limit")); got != "3" {
+			t.Errorf("X-RateLimit-Limit = %q, want 3", got)
+		}
+	}
+
+	// tiers without a limit are never limited.
+	staff := common.Token{UserID: 1001, Value: "c", UserPrivileges: common.AdminPrivilegeAccessRAP}
+	for i := 0; i < 10; i++ {
+		if rateLimited(rateLimitRequest("203.0.113.3"), "/api/v1/test", staff) {
+			t.Fatal("staff request was limited")
+		}
+	}
+}
+
+func TestIPRateLimited(t *testing.T) {
+	testRedis(t)
+	defaultRateLimits = rateLimits{
+		tierUser: {Rate: 1, Burst: 3},
+		tierIP:   {Rate: 1, Burst: 4},
+	}
+	routeRateLimits = nil
+
+	// switching between tokens doesn't get around the limit of the IP.
+	for i, want := range []bool{false, false, false, false, true} {
+		c := rateLimitRequest("203.0.113.4")
+		tok := common.Token{UserID: 1000, Value: string(rune('a' + i))}
+		got := ipRateLimited(c, "/api/v1/test") || rateLimited(c, "/api/v1/test", tok)
+		if got != want {
+			t.Fatalf("request %d: limited = %v, want %v", i, got, want)
+		}
+	}
+	// other IPs have their own bucket.
+	if ipRateLimited(rateLimitRequest("203.0.113.5"), "/api/v1/test") {
+		t.Error("request from another IP was limited")
+	}
+}
@@ -2,11 +2,15 @@ package app
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
+	"golang.org/x/exp/slog"
+
 	"github.com/buaazp/fasthttprouter"
 	"github.com/jmoiron/sqlx"
 	"github.com/osuAkatsuki/akatsuki-api/common"
@@ -15,63 +19,155 @@ import (
 
 type router struct {
 	r *fasthttprouter.Router
+	// methods holds the methods registered for each path, so that OPTIONS
+	// handlers can be added for all of them.
+	methods map[string][]string
 }
 
-func (r router) Method(path string, f func(md common.MethodData) common.CodeMessager, privilegesNeeded ...int) {
-	r.r.GET(path, wrap(Method(f, privilegesNeeded...)))
+func newRouter(r *fasthttprouter.Router) router {
+	return router{r: r, methods: make(map[string][]string)}
 }
-func (r router) POSTMethod(path string, f func(md common.MethodData) common.CodeMessager, privilegesNeeded ...int) {
-	r.r.POST(path, wrap(Method(f, privilegesNeeded...)))
+
+// handle registers handle for the given method and path, wrapping it.
+func (r router) handle(method, path string, handle fasthttp.RequestHandler) {
+	r.methods[path] = append(r.methods[path], method)
+	r.r.Handle(method, path, wrap(path, handle))
+}
+
+// routeInfo describes a registered route, for the OpenAPI document.
+type routeInfo struct {
+	Method string
+	Path   string
+	Scopes []common.Scope
+	Doc    common.RouteDoc
+	// Timeout is the time the method has to complete, if not the default.
+	Timeout time.Duration
+	// Peppy is set on the routes of the osu! API clone.
+	Peppy bool
+}
+
+// routeTable holds all the routes registered through a router.
+var routeTable []routeInfo
+
+// register records a route in routeTable, and returns the handler of the
+// API method f.
+func register(method, path string, f func(md common.MethodData) common.CodeMessager, opts []common.RouteOption) fasthttp.RequestHandler {
+	ri := routeInfo{Method: method, Path: path}
+	for _, o := range opts {
+		switch o := o.(type) {
+		case common.Scope:
+			ri.Scopes = append(ri.Scopes, o)
+		case common.Timeout:
+			ri.Timeout = time.Duration(o)
+		case common.RouteDoc:
+			ri.Doc = o
+		}
+	}
+	routeTable = append(routeTable, ri)
+	return timedMethod(f, ri.Timeout, ri.Scopes...)
+}
+
+// Method registers a GET API method. opts are the scopes required to call it,
+// and optionally its timeout and documentation.
+func (r router) Method(path string, f func(md common.MethodData) common.CodeMessager, opts ...common.RouteOption) {
+	r.handle("GET", path, register("GET", path, f, opts))
+}
+
+// POSTMethod registers a POST API method, see Method.
+func (r router) POSTMethod(path string, f func(md common.MethodData) common.CodeMessager, opts ...common.RouteOption) {
+	r.handle("POST", path, register("POST", path, f, opts))
+}
+
+// CachedMethod is like Method, but anonymous responses are cached in redis
+// following policy.
+func (r router) CachedMethod(path string, f func(md common.MethodData) common.CodeMessager, policy cachePolicy, opts ...common.RouteOption) {
+	cachePolicies[path] = policy
+	r.Method(path, f, opts...)
 }
 func (r router) Peppy(path string, a func(c *fasthttp.RequestCtx, db *sqlx.DB)) {
-	r.r.GET(path, wrap(PeppyMethod(a)))
+	routeTable = append(routeTable, routeInfo{Method: "GET", Path: path, Peppy: true})
+	r.handle("GET", path, PeppyMethod(a))
 }
 func (r router) GET(path string, handle fasthttp.RequestHandler) {
-	r.r.GET(path, wrap(handle))
+	r.handle("GET", path, handle)
 }
 func (r router) PlainGET(path string, handle fasthttp.RequestHandler) {
 	r.r.GET(path, handle)
 }
 
+// Preflights registers an OPTIONS handler answering CORS preflight requests
+// for every path registered so far.
+func (r router) Preflights() {
+	for path, methods := range r.methods {
+		r.r.OPTIONS(path, wrap(path, cors.preflight(methods)))
+	}
+}
+
 const (
-	// \x1b is escape code for ESC
-	// <ESC>[<n>m is escape sequence for a certain colour
-	// this is partly inspired from gin, though made even more simplistic.
-	fmtString = "%s | %15s | %-15s |\x1b[%sm %3d \x1b[0m %-7s %s\n"
-	// a kind of human readable RFC3339
-	timeFormat = "2006-01-02 15:04:05"
-	// color reference
-	// http://misc.flogisoft.com/bash/tip_colors_and_formatting
-	colorOk    = "42" // green
-	colorError = "41" // red
+	// appended to the ETag of gzipped responses, as they are a different
+	// representation of the resource.
+	gzipETagSuffix = "-gzip"
 )
 
-// clientIP extracts the real client IP from the request, honouring
-// X-Real-IP / X-Forwarded-For set by the reverse proxy.
+// clientIP returns the real IP of the client, see common.ClientIP.
 func clientIP(c *fasthttp.RequestCtx) string {
-	ip := strings.TrimSpace(string(c.Request.Header.Peek("X-Real-Ip")))
-	if len(ip) > 0 {
-		return ip
-	}
-	ip = string(c.Request.Header.Peek("X-Forwarded-For"))
-	if index := strings.IndexByte(ip, ','); index >= 0 {
-		ip = ip[0:index]
+	return common.ClientIP(c)
+}
+
+// routeOf returns the route template the request was matched against.
+func routeOf(c *fasthttp.RequestCtx) string {
+	return common.Route(c)
+}
+
+// userKey is the user value in which initialCaretaker stores the token the
+// request was authenticated with, so that it can be logged.
+const userKey = "user"
+
+func userOf(c *fasthttp.RequestCtx) common.Token {
+	t, _ := c.UserValue(userKey).(common.Token)
+	return t
+}
+
+// requestID takes the ID of the request from the X-Request-ID header, as set
+// by a reverse proxy, or generates a new one if it is missing or invalid.
+func requestID(c *fasthttp.RequestCtx) string {
+	id := c.Request.Header.Peek("X-Request-ID")
+	if len(id) > 0 && len(id) <= 128 {
+		valid := true
+		for _, ch := range id {
+			if ch <= ' ' || ch > '~' {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return string(id)
+		}
 	}
-	ip = strings.TrimSpace(ip)
-	if len(ip) > 0 {
-		return ip
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatUint(c.ID(), 16)
 	}
-	return c.RemoteIP().String()
+	return hex.EncodeToString(b)
 }
 
 // wrap returns a function that wraps around handle, providing middleware
 // functionality to apply to all API calls, which is to say:
+// - request IDs
+// - CORS headers
 // - logging
 // - panic recovery (reporting to sentry)
 // - gzipping
-func wrap(handle fasthttp.RequestHandler) fasthttp.RequestHandler {
+// - metrics
+// It also makes the route available to the handler through routeOf.
+func wrap(route string, handle fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(c *fasthttp.RequestCtx) {
 		start := time.Now()
+		c.SetUserValue(common.RouteKey, route)
+		reqID := requestID(c)
+		c.SetUserValue(common.RequestIDKey, reqID)
+		c.Response.Header.Set("X-Request-ID", reqID)
+		cors.apply(c)
 
 		defer func() {
 			if rval := recover(); rval != nil {
@@ -85,38 +181,54 @@ func wrap(handle fasthttp.RequestHandler) fasthttp.RequestHandler {
 					err = fmt.Errorf("%v - %#v", rval, rval)
 				}
 				common.Err(c, err)
+				panicsTotal.WithLabelValues(route).Inc()
 				c.SetStatusCode(500)
-				c.SetBodyString(`{ "code": 500, "message": "something really bad happened" }`)
+				c.SetBodyString(`{ "code": 500, "error": "internal_error", "message": "something really bad happened" }`)
 			}
 
-			// switch color to colorError if statusCode is in [500;600)
-			color := colorOk
 			statusCode := c.Response.StatusCode()
-			if statusCode >= 500 && statusCode < 600 {
-				color = colorError
-			}
 
-			if bytes.Contains(c.Request.Header.Peek("Accept-Encoding"), s2b("gzip")) {
+			if statusCode != fasthttp.StatusNotModified && statusCode != fasthttp.StatusNoContent &&
+				bytes.Contains(c.Request.Header.Peek("Accept-Encoding"), s2b("gzip")) {
 				c.Response.Header.Add("Content-Encoding", "gzip")
 				c.Response.Header.Add("Vary", "Accept-Encoding")
+				if etag := c.Response.Header.Peek("ETag"); len(etag) > 1 {
+					c.Response.Header.Set("ETag", string(etag[:len(etag)-1])+gzipETagSuffix+`"`)
+				}
 				b := c.Response.Body()
 				c.Response.ResetBody()
 				fasthttp.WriteGzip(c.Response.BodyWriter(), b)
 			}
 
-			// print stuff
-			fmt.Printf(
-				fmtString,
-				time.Now().Format(timeFormat),
-				time.Since(start).String(),
-				clientIP(c),
-				color,
-				statusCode,
-				c.Method(),
-				c.Path(),
+			took := time.Since(start)
+			observeRequest(c, route, took)
+
+			level := slog.LevelInfo
+			if statusCode >= 500 && statusCode < 600 {
+				level = slog.LevelError
+			}
+			user := userOf(c)
+			slog.Log(c, level, "Handled request",
+				"request_id", reqID,
+				"method", string(c.Method()),
+				"route", route,
+				"path", string(c.Path()),
+				"status", statusCode,
+				"latency_ms", float64(took.Microseconds())/1000,
+				"response_size", len(c.Response.Body()),
+				"ip", clientIP(c),
+				"user_id", user.UserID,
+				"token_id", user.ID,
 			)
 		}()
 
+		if refused, err := inMaintenance(c, route); refused {
+			if err != nil {
+				common.Err(c, err)
+			}
+			writeMaintenance(c)
+			return
+		}
 		handle(c)
 	}
 }
@@ -1,10 +1,14 @@
 package app
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"sync"
 	"time"
 
+	"golang.org/x/exp/slog"
+
 	fhr "github.com/buaazp/fasthttprouter"
 	"github.com/jmoiron/sqlx"
 	"github.com/osuAkatsuki/akatsuki-api/app/internals"
@@ -17,6 +21,11 @@ import (
 var (
 	db  *sqlx.DB
 	red *redis.Client
+
+	// stopWorkers tells the background workers to stop, and workers waits
+	// for them to have done so.
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
 )
 
 // Start begins taking HTTP connections.
@@ -24,9 +33,17 @@ func Start(dbO *sqlx.DB) *fhr.Router {
 	db = dbO
 
 	rawRouter := fhr.New()
-	r := router{rawRouter}
+	r := newRouter(rawRouter)
 
 	settings := common.GetSettings()
+	var err error
+	cors, err = loadCORSPolicy(settings)
+	if err != nil {
+		panic("Invalid CORS settings: " + err.Error())
+	}
+	if err := loadRateLimits(settings); err != nil {
+		panic("Invalid rate limits: " + err.Error())
+	}
 
 	// initialise redis
 	var tlsConfig *tls.Config
@@ -43,11 +60,39 @@ func Start(dbO *sqlx.DB) *fhr.Router {
 	})
 	peppy.R = red
 
+	registerMetrics(db, red)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopWorkers = cancel
+
 	// token updater
-	go tokenUpdater(db)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		tokenUpdater(ctx, db)
+	}()
+
+	// token cache
+	resolvedTokens = newTokenCache(time.Duration(settings.TOKEN_CACHE_TTL)*time.Second, settings.TOKEN_CACHE_SIZE)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		tokenInvalidator(ctx)
+	}()
+
+	// system settings, such as maintenance mode
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		systemSettingsLoader(ctx, db)
+	}()
 
 	// start load achievements
-	go v1.LoadAchievementsEvery(db, time.Minute*10)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		v1.LoadAchievementsEvery(ctx, db, time.Minute*10)
+	}()
 
 	// peppyapi
 	{
@@ -59,79 +104,148 @@ func Start(dbO *sqlx.DB) *fhr.Router {
 		r.Peppy("/api/get_beatmaps", peppy.GetBeatmap)
 	}
 
+	// response cache policies for public data
+	var (
+		usersCache       = cachePolicy{TTL: 30 * time.Second, Tags: []string{"users"}}
+		leaderboardCache = cachePolicy{TTL: 30 * time.Second, Tags: []string{"leaderboard"}}
+		clansCache       = cachePolicy{TTL: time.Minute, Tags: []string{"clans"}}
+		beatmapsCache    = cachePolicy{TTL: 5 * time.Minute, Tags: []string{"beatmaps"}}
+		badgesCache      = cachePolicy{TTL: 10 * time.Minute, Tags: []string{"badges"}}
+		tbadgesCache     = cachePolicy{TTL: 10 * time.Minute, Tags: []string{"tbadges"}}
+		alertsCache      = cachePolicy{TTL: 30 * time.Second, Tags: []string{"alerts"}}
+	)
+
 	// v1 API
 	{
-		r.Method("/_health", v1.HealthGET)
+		r.Method("/_health", v1.HealthGET, v1.HealthGETDoc)
 
-		r.POSTMethod("/api/v1/tokens/self/delete", v1.TokenSelfDeletePOST)
+		r.POSTMethod("/api/v1/tokens", v1.TokenNewPOST, v1.TokenNewPOSTDoc)
+		r.POSTMethod("/api/v1/tokens/self/delete", v1.TokenSelfDeletePOST, v1.TokenSelfDeletePOSTDoc)
+
+		// OAuth 2 endpoints called by client applications
+		r.POSTMethod("/api/v1/oauth/token", v1.OAuthTokenPOST, v1.OAuthTokenPOSTDoc)
+		r.POSTMethod("/api/v1/oauth/revoke", v1.OAuthRevokePOST, v1.OAuthRevokePOSTDoc)
+		r.POSTMethod("/api/v1/oauth/introspect", v1.OAuthIntrospectPOST, v1.OAuthIntrospectPOSTDoc)
+		r.Method("/api/v1/oauth/scopes", v1.OAuthScopesGET, v1.OAuthScopesGETDoc)
+
+		// several GET requests in one, made with the caller's token
+		r.POSTMethod("/api/v1/batch", batchHandler(rawRouter), batchDoc)
 
 		// Auth-free API endpoints (public data)
-		r.Method("/api/v1/ping", v1.PingGET)
-		r.Method("/api/v1/surprise_me", v1.SurpriseMeGET)
-
-		r.Method("/api/v1/match", v1.MatchGET)
-
-		r.Method("/api/v1/users", v1.UsersGET)
-		r.Method("/api/v1/users/whatid", v1.UserWhatsTheIDGET)
-		r.Method("/api/v1/users/full", v1.UserFullGET)
-		r.Method("/api/v1/users/achievements", v1.UserAchievementsGET)
-		r.Method("/api/v1/users/userpage", v1.UserUserpageGET)
-		r.Method("/api/v1/users/lookup", v1.UserLookupGET)
-		r.Method("/api/v1/users/scores/best", v1.UserScoresBestGET)
-		r.Method("/api/v1/users/scores/recent", v1.UserScoresRecentGET)
-		r.Method("/api/v1/users/scores/first", v1.UserFirstGET)
-		r.Method("/api/v1/users/scores/pinned", v1.UserScoresPinnedGET)
-		r.Method("/api/v1/users/most_played", v1.UserMostPlayedBeatmapsGET)
-		r.Method("/api/v1/badges", v1.BadgesGET)
-		r.Method("/api/v1/badges/members", v1.BadgeMembersGET)
-		r.Method("/api/v1/clans", v1.ClansGET)
-		r.Method("/api/v1/clans/members", v1.ClanMembersGET)
-		r.Method("/api/v1/clans/stats", v1.ClanStatsGET)
-		r.Method("/api/v1/clans/stats/all", v1.ClanLeaderboardGET)
-		r.Method("/api/v1/clans/stats/first", v1.ClansFirstPlaceRankingGET)
-		r.Method("/api/v1/clans/invite", v1.ResolveInviteGET)
-		r.Method("/api/v1/tbadges", v1.TBadgesGET)
-		r.Method("/api/v1/tbadges/members", v1.TBadgeMembersGET)
-		r.Method("/api/v1/beatmaps", v1.BeatmapGET)
-		r.Method("/api/v1/leaderboard", v1.LeaderboardGET)
-		r.Method("/api/v1/tokens", v1.TokenGET)
-		r.Method("/api/v1/users/self", v1.UserSelfGET)
-		r.Method("/api/v1/tokens/self", v1.TokenSelfGET)
-		r.Method("/api/v1/blog/posts", v1.BlogPostsGET)
-		r.Method("/api/v1/score", v1.ScoreGET)
-		r.Method("/api/v1/scores", v1.ScoresGET)
-		r.Method("/api/v1/grades", v1.UserGradesGET)
-		r.Method("/api/v1/countries", v1.CountriesGET)
-		r.Method("/api/v1/hypothetical-rank", v1.HypotheticalRankGET)
-
-		r.Method("/api/v1/discord/callback", v1.DiscordCallbackGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/twitch/callback", v1.TwitchCallbackGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/osu/callback", v1.OfficialOsuCallbackGET, common.PrivilegeReadConfidential)
-
-		// ReadConfidential privilege required
-		r.Method("/api/v1/friends", v1.FriendsGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/followers", v1.FollowersGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/friends/with", v1.FriendsWithGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/users/self/donor_info", v1.UsersSelfDonorInfoGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/users/self/favourite_mode", v1.UsersSelfFavouriteModeGET, common.PrivilegeReadConfidential)
-		r.Method("/api/v1/users/self/settings", v1.UsersSelfSettingsGET, common.PrivilegeReadConfidential)
-
-		// Write privilege required
-		r.POSTMethod("/api/v1/friends/add", v1.FriendsAddPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/friends/del", v1.FriendsDelPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/scores/pin", v1.ScoresPinAddPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/scores/unpin", v1.ScoresPinDelPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/self/connections/unlink-discord", v1.DiscordUnlinkPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/self/connections/unlink-twitch", v1.TwitchUnlinkPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/self/connections/unlink-osu", v1.OfficialOsuUnlinkPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/self/settings", v1.UsersSelfSettingsPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/users/self/userpage", v1.UserSelfUserpagePOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/clans/join", v1.ClanJoinPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/clans/invite", v1.ClanGenerateInvitePOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/clans/leave", v1.ClanLeavePOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/clans/settings", v1.ClanSettingsPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/clans/kick", v1.ClanKickPOST, common.PrivilegeWrite)
-		r.POSTMethod("/api/v1/clans/transfer-ownership", v1.ClanTransferOwnershipPOST, common.PrivilegeWrite)
+		r.Method("/api/v1/ping", v1.PingGET, v1.PingGETDoc)
+		r.Method("/api/v1/surprise_me", v1.SurpriseMeGET, v1.SurpriseMeGETDoc)
+
+		r.Method("/api/v1/match", v1.MatchGET, v1.MatchGETDoc)
+
+		r.Method("/api/v1/users", v1.UsersGET, v1.UsersGETDoc)
+		r.Method("/api/v1/users/whatid", v1.UserWhatsTheIDGET, v1.UserWhatsTheIDGETDoc)
+		r.CachedMethod("/api/v1/users/full", v1.UserFullGET, usersCache, v1.UserFullGETDoc)
+		r.Method("/api/v1/users/achievements", v1.UserAchievementsGET, v1.UserAchievementsGETDoc)
+		r.Method("/api/v1/users/userpage", v1.UserUserpageGET, v1.UserUserpageGETDoc)
+		r.Method("/api/v1/users/lookup", v1.UserLookupGET, common.Timeout(3*time.Second), v1.UserLookupGETDoc)
+		r.Method("/api/v1/users/scores/best", v1.UserScoresBestGET, v1.UserScoresBestGETDoc)
+		r.Method("/api/v1/users/scores/recent", v1.UserScoresRecentGET, v1.UserScoresRecentGETDoc)
+		r.Method("/api/v1/users/scores/first", v1.UserFirstGET, v1.UserFirstGETDoc)
+		r.Method("/api/v1/users/scores/pinned", v1.UserScoresPinnedGET, v1.UserScoresPinnedGETDoc)
+		r.Method("/api/v1/users/most_played", v1.UserMostPlayedBeatmapsGET, v1.UserMostPlayedBeatmapsGETDoc)
+		r.CachedMethod("/api/v1/badges", v1.BadgesGET, badgesCache, v1.BadgesGETDoc)
+		r.CachedMethod("/api/v1/badges/members", v1.BadgeMembersGET, badgesCache, v1.BadgeMembersGETDoc)
+		r.CachedMethod("/api/v1/clans", v1.ClansGET, clansCache, v1.ClansGETDoc)
+		r.CachedMethod("/api/v1/clans/members", v1.ClanMembersGET, clansCache, v1.ClanMembersGETDoc)
+		r.CachedMethod("/api/v1/clans/stats", v1.ClanStatsGET, clansCache, v1.ClanStatsGETDoc)
+		r.CachedMethod("/api/v1/clans/stats/all", v1.ClanLeaderboardGET, clansCache, common.Timeout(5*time.Second), v1.ClanLeaderboardGETDoc)
+		r.CachedMethod("/api/v1/clans/stats/first", v1.ClansFirstPlaceRankingGET, clansCache, v1.ClansFirstPlaceRankingGETDoc)
+		r.Method("/api/v1/clans/invite", v1.ResolveInviteGET, v1.ResolveInviteGETDoc)
+		r.CachedMethod("/api/v1/tbadges", v1.TBadgesGET, tbadgesCache, v1.TBadgesGETDoc)
+		r.CachedMethod("/api/v1/tbadges/members", v1.TBadgeMembersGET, tbadgesCache, v1.TBadgeMembersGETDoc)
+		r.CachedMethod("/api/v1/beatmaps", v1.BeatmapGET, beatmapsCache, v1.BeatmapGETDoc)
+		r.CachedMethod("/api/v1/beatmaps/status_changes", v1.BeatmapStatusChangesGET, beatmapsCache, v1.BeatmapStatusChangesGETDoc)
+		r.CachedMethod("/api/v1/leaderboard", v1.LeaderboardGET, leaderboardCache, v1.LeaderboardGETDoc)
+		r.Method("/api/v1/tokens", v1.TokenGET, v1.TokenGETDoc)
+		r.Method("/api/v1/users/self", v1.UserSelfGET, v1.UserSelfGETDoc)
+		r.Method("/api/v1/tokens/self", v1.TokenSelfGET, v1.TokenSelfGETDoc)
+		r.Method("/api/v1/blog/posts", v1.BlogPostsGET, v1.BlogPostsGETDoc)
+		r.Method("/api/v1/score", v1.ScoreGET, v1.ScoreGETDoc)
+		r.Method("/api/v1/scores", v1.ScoresGET, v1.ScoresGETDoc)
+		r.Method("/api/v1/grades", v1.UserGradesGET, v1.UserGradesGETDoc)
+		r.Method("/api/v1/countries", v1.CountriesGET, v1.CountriesGETDoc)
+		r.Method("/api/v1/hypothetical-rank", v1.HypotheticalRankGET, v1.HypotheticalRankGETDoc)
+		r.CachedMethod("/api/v1/alerts", v1.AlertsGET, alertsCache, v1.AlertsGETDoc)
+
+		r.Method("/api/v1/discord/callback", v1.DiscordCallbackGET, common.ScopeConnectionsLink, v1.DiscordCallbackGETDoc)
+		r.Method("/api/v1/twitch/callback", v1.TwitchCallbackGET, common.ScopeConnectionsLink, v1.TwitchCallbackGETDoc)
+		r.Method("/api/v1/osu/callback", v1.OfficialOsuCallbackGET, common.ScopeConnectionsLink, v1.OfficialOsuCallbackGETDoc)
+
+		// ReadConfidential scopes required
+		r.Method("/api/v1/friends", v1.FriendsGET, common.ScopeFriendsRead, v1.FriendsGETDoc)
+		r.Method("/api/v1/followers", v1.FollowersGET, common.ScopeFriendsRead, v1.FollowersGETDoc)
+		r.Method("/api/v1/friends/with", v1.FriendsWithGET, common.ScopeFriendsRead, v1.FriendsWithGETDoc)
+		r.Method("/api/v1/users/self/donor_info", v1.UsersSelfDonorInfoGET, common.ScopeSettingsRead, v1.UsersSelfDonorInfoGETDoc)
+		r.Method("/api/v1/users/self/favourite_mode", v1.UsersSelfFavouriteModeGET, common.ScopeSettingsRead, v1.UsersSelfFavouriteModeGETDoc)
+		r.Method("/api/v1/users/self/settings", v1.UsersSelfSettingsGET, common.ScopeSettingsRead, v1.UsersSelfSettingsGETDoc)
+		r.Method("/api/v1/tokens/self/consents", v1.TokenSelfConsentsGET, common.ScopeTokensManage, v1.TokenSelfConsentsGETDoc)
+		r.Method("/api/v1/oauth/clients", v1.OAuthClientsGET, common.ScopeReadConfidential, v1.OAuthClientsGETDoc)
+		r.Method("/api/v1/users/self/audit", v1.AuditSelfGET, common.ScopeReadConfidential, v1.AuditSelfGETDoc)
+
+		// Admin routes
+		r.Method("/api/v1/audit", v1.AuditGET, common.ScopeViewUserAdvanced, v1.AuditGETDoc)
+		r.Method("/api/v1/meta", v1.MetaGET, common.ScopeAPIMeta, v1.MetaGETDoc)
+		r.Method("/api/v1/admin/users/moderation", v1.AdminUserModerationHistoryGET, common.ScopeManageUser, v1.AdminUserModerationHistoryGETDoc)
+		r.Method("/api/v1/admin/settings", v1.SystemSettingsGET, common.ScopeManageSettings, v1.SystemSettingsGETDoc)
+		r.Method("/api/v1/admin/alerts", v1.AdminAlertsGET, common.ScopeManageSettings, v1.AdminAlertsGETDoc)
+
+		// Write scopes required
+		r.POSTMethod("/api/v1/friends/add", v1.FriendsAddPOST, common.ScopeFriendsWrite, v1.FriendsAddPOSTDoc)
+		r.POSTMethod("/api/v1/friends/del", v1.FriendsDelPOST, common.ScopeFriendsWrite, v1.FriendsDelPOSTDoc)
+		r.POSTMethod("/api/v1/users/scores/pin", v1.ScoresPinAddPOST, common.ScopeScoresPin, v1.ScoresPinAddPOSTDoc)
+		r.POSTMethod("/api/v1/users/scores/unpin", v1.ScoresPinDelPOST, common.ScopeScoresPin, v1.ScoresPinDelPOSTDoc)
+		r.POSTMethod("/api/v1/users/self/connections/unlink-discord", v1.DiscordUnlinkPOST, common.ScopeConnectionsUnlink, v1.DiscordUnlinkPOSTDoc)
+		r.POSTMethod("/api/v1/users/self/connections/unlink-twitch", v1.TwitchUnlinkPOST, common.ScopeConnectionsUnlink, v1.TwitchUnlinkPOSTDoc)
+		r.POSTMethod("/api/v1/users/self/connections/unlink-osu", v1.OfficialOsuUnlinkPOST, common.ScopeConnectionsUnlink, v1.OfficialOsuUnlinkPOSTDoc)
+		r.POSTMethod("/api/v1/users/self/settings", v1.UsersSelfSettingsPOST, common.ScopeSettingsWrite, v1.UsersSelfSettingsPOSTDoc)
+		r.POSTMethod("/api/v1/users/self/userpage", v1.UserSelfUserpagePOST, common.ScopeSettingsWrite, v1.UserSelfUserpagePOSTDoc)
+		r.POSTMethod("/api/v1/clans/join", v1.ClanJoinPOST, common.ScopeClansManage, v1.ClanJoinPOSTDoc)
+		r.POSTMethod("/api/v1/clans/invite", v1.ClanGenerateInvitePOST, common.ScopeClansManage, v1.ClanGenerateInvitePOSTDoc)
+		r.POSTMethod("/api/v1/clans/leave", v1.ClanLeavePOST, common.ScopeClansManage, v1.ClanLeavePOSTDoc)
+		r.POSTMethod("/api/v1/clans/settings", v1.ClanSettingsPOST, common.ScopeClansManage, v1.ClanSettingsPOSTDoc)
+		r.POSTMethod("/api/v1/clans/kick", v1.ClanKickPOST, common.ScopeClansManage, v1.ClanKickPOSTDoc)
+		r.POSTMethod("/api/v1/clans/transfer-ownership", v1.ClanTransferOwnershipPOST, common.ScopeClansManage, v1.ClanTransferOwnershipPOSTDoc)
+		r.POSTMethod("/api/v1/tokens/delete", v1.TokenDeletePOST, common.ScopeTokensManage, v1.TokenDeletePOSTDoc)
+		r.POSTMethod("/api/v1/tokens/delete_others", v1.TokenDeleteOthersPOST, common.ScopeTokensManage, v1.TokenDeleteOthersPOSTDoc)
+		r.POSTMethod("/api/v1/tokens/edit", v1.TokenEditPOST, common.ScopeTokensManage, v1.TokenEditPOSTDoc)
+		r.POSTMethod("/api/v1/tokens/self/consents/revoke", v1.TokenSelfConsentsRevokePOST, common.ScopeTokensManage, v1.TokenSelfConsentsRevokePOSTDoc)
+		r.Method("/api/v1/oauth/authorize", v1.OAuthAuthorizeGET, common.ScopeWrite, v1.OAuthAuthorizeGETDoc)
+		r.POSTMethod("/api/v1/oauth/authorize", v1.OAuthAuthorizePOST, common.ScopeWrite, v1.OAuthAuthorizePOSTDoc)
+		r.POSTMethod("/api/v1/oauth/clients", v1.OAuthClientNewPOST, common.ScopeWrite, v1.OAuthClientNewPOSTDoc)
+		r.POSTMethod("/api/v1/oauth/clients/delete", v1.OAuthClientDeletePOST, common.ScopeWrite, v1.OAuthClientDeletePOSTDoc)
+
+		// Admin write routes
+		r.POSTMethod("/api/v1/admin/users/restrict", v1.AdminUserRestrictPOST, common.ScopeManageUser, v1.AdminUserRestrictPOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/unrestrict", v1.AdminUserUnrestrictPOST, common.ScopeManageUser, v1.AdminUserUnrestrictPOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/ban", v1.AdminUserBanPOST, common.ScopeManageUser, v1.AdminUserBanPOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/unban", v1.AdminUserUnbanPOST, common.ScopeManageUser, v1.AdminUserUnbanPOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/freeze", v1.AdminUserFreezePOST, common.ScopeManageUser, v1.AdminUserFreezePOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/unfreeze", v1.AdminUserUnfreezePOST, common.ScopeManageUser, v1.AdminUserUnfreezePOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/silence", v1.AdminUserSilencePOST, common.ScopeManageUser, v1.AdminUserSilencePOSTDoc)
+		r.POSTMethod("/api/v1/admin/users/unsilence", v1.AdminUserUnsilencePOST, common.ScopeManageUser, v1.AdminUserUnsilencePOSTDoc)
+		r.POSTMethod("/api/v1/meta/achievements/reload", v1.MetaAchievementsReloadPOST, common.ScopeAPIMeta, v1.MetaAchievementsReloadPOSTDoc)
+		r.POSTMethod("/api/v1/meta/cache/flush", v1.MetaCacheFlushPOST, common.ScopeAPIMeta, v1.MetaCacheFlushPOSTDoc)
+		r.POSTMethod("/api/v1/meta/maintenance", v1.MetaMaintenancePOST, common.ScopeAPIMeta, v1.MetaMaintenancePOSTDoc)
+		r.POSTMethod("/api/v1/admin/beatmaps/status", v1.BeatmapStatusPOST, common.ScopeBeatmap, v1.BeatmapStatusPOSTDoc)
+		r.POSTMethod("/api/v1/admin/badges", v1.BadgeNewPOST, common.ScopeManageBadges, v1.BadgeNewPOSTDoc)
+		r.POSTMethod("/api/v1/admin/badges/edit", v1.BadgeEditPOST, common.ScopeManageBadges, v1.BadgeEditPOSTDoc)
+		r.POSTMethod("/api/v1/admin/badges/delete", v1.BadgeDeletePOST, common.ScopeManageBadges, v1.BadgeDeletePOSTDoc)
+		r.POSTMethod("/api/v1/admin/badges/grant", v1.BadgeGrantPOST, common.ScopeManageBadges, v1.BadgeGrantPOSTDoc)
+		r.POSTMethod("/api/v1/admin/badges/revoke", v1.BadgeRevokePOST, common.ScopeManageBadges, v1.BadgeRevokePOSTDoc)
+		r.POSTMethod("/api/v1/admin/tbadges", v1.TBadgeNewPOST, common.ScopeManageBadges, v1.TBadgeNewPOSTDoc)
+		r.POSTMethod("/api/v1/admin/tbadges/edit", v1.TBadgeEditPOST, common.ScopeManageBadges, v1.TBadgeEditPOSTDoc)
+		r.POSTMethod("/api/v1/admin/tbadges/delete", v1.TBadgeDeletePOST, common.ScopeManageBadges, v1.TBadgeDeletePOSTDoc)
+		r.POSTMethod("/api/v1/admin/tbadges/grant", v1.TBadgeGrantPOST, common.ScopeManageBadges, v1.TBadgeGrantPOSTDoc)
+		r.POSTMethod("/api/v1/admin/tbadges/revoke", v1.TBadgeRevokePOST, common.ScopeManageBadges, v1.TBadgeRevokePOSTDoc)
+		r.POSTMethod("/api/v1/admin/settings", v1.SystemSettingsPOST, common.ScopeManageSettings, v1.SystemSettingsPOSTDoc)
+		r.POSTMethod("/api/v1/admin/alerts", v1.AlertNewPOST, common.ScopeManageSettings, v1.AlertNewPOSTDoc)
+		r.POSTMethod("/api/v1/admin/alerts/edit", v1.AlertEditPOST, common.ScopeManageSettings, v1.AlertEditPOSTDoc)
+		r.POSTMethod("/api/v1/admin/alerts/delete", v1.AlertDeletePOST, common.ScopeManageSettings, v1.AlertDeletePOSTDoc)
 	}
 
 	// in the new osu-web, the old endpoints are also in /v1 it seems. So /shrug
@@ -146,7 +260,27 @@ func Start(dbO *sqlx.DB) *fhr.Router {
 
 	r.GET("/api/status", internals.Status)
 
+	// must come after all the routes it documents.
+	r.GET("/api/v1/openapi.json", openAPIHandler())
+
+	r.Preflights()
 	rawRouter.NotFound = v1.Handle404
 
 	return rawRouter
 }
+
+// Shutdown stops the background workers started by Start, waiting for them
+// to finish writing any pending data, such as token last-used times, and then
+// closes the redis client. The database is left to the caller, as it is the
+// one that opened it.
+func Shutdown() {
+	if stopWorkers != nil {
+		stopWorkers()
+	}
+	workers.Wait()
+	if red != nil {
+		if err := red.Close(); err != nil {
+			slog.Error("Error closing redis client", "error", err.Error())
+		}
+	}
+}
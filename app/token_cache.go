@@ -0,0 +1,191 @@
+package app
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// tokenCache is an in-memory LRU cache of resolved tokens, so that
+// authenticated requests do not need to hit the database every time. Entries
+// are kept for a short time, never past the expiry of the token, and are
+// dropped early when a message is received on common.TokenInvalidationChannel.
+//
+// Only this API publishes invalidations: when another service, such as the
+// admin panel or bancho, deletes a token or changes the privileges of a user,
+// the cached tokens keep their old privileges until the TTL is over.
+type tokenCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type tokenCacheEntry struct {
+	key     string
+	token   common.Token
+	expires time.Time
+}
+
+// resolvedTokens is the token cache, keyed by "api:<md5 of the token>" for API
+// tokens and "bearer:<sha256 of the token>" for OAuth access tokens. It is
+// set up by Start.
+var resolvedTokens *tokenCache
+
+func newTokenCache(ttl time.Duration, size int) *tokenCache {
+	return &tokenCache{
+		ttl:     ttl,
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *tokenCache) get(key string) (common.Token, bool) {
+	if c.ttl <= 0 {
+		return common.Token{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		tokenCacheLookups.WithLabelValues("miss").Inc()
+		return common.Token{}, false
+	}
+	e := el.Value.(*tokenCacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		tokenCacheLookups.WithLabelValues("miss").Inc()
+		return common.Token{}, false
+	}
+	c.ll.MoveToFront(el)
+	tokenCacheLookups.WithLabelValues("hit").Inc()
+	return e.token, true
+}
+
+// set caches the token until the TTL is over, or until expires if the token
+// expires earlier. A zero expires is for tokens that never expire.
+func (c *tokenCache) set(key string, t common.Token, expires time.Time) {
+	if c.ttl <= 0 || c.size <= 0 {
+		return
+	}
+	if e := time.Now().Add(c.ttl); expires.IsZero() || e.Before(expires) {
+		expires = e
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.ll.PushFront(&tokenCacheEntry{
+		key:     key,
+		token:   t,
+		expires: expires,
+	})
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+// remove must be called with c.mu held.
+func (c *tokenCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.entries, el.Value.(*tokenCacheEntry).key)
+}
+
+// invalidate drops all the entries for which drop returns true.
+func (c *tokenCache) invalidate(drop func(key string, t common.Token) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*tokenCacheEntry)
+		if drop(e.key, e.token) {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// handleInvalidation handles a message received on
+// common.TokenInvalidationChannel.
+func (c *tokenCache) handleInvalidation(msg string) {
+	kind, value := msg, ""
+	if i := strings.IndexByte(msg, ':'); i != -1 {
+		kind, value = msg[:i], msg[i+1:]
+	}
+	switch kind {
+	case "token":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			break
+		}
+		c.invalidate(func(key string, t common.Token) bool {
+			return t.ID == id && strings.HasPrefix(key, "api:")
+		})
+		return
+	case "bearer":
+		c.invalidate(func(key string, _ common.Token) bool {
+			return key == "bearer:"+value
+		})
+		return
+	case "user":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			break
+		}
+		c.invalidate(func(_ string, t common.Token) bool {
+			return t.UserID == id
+		})
+		return
+	}
+	slog.Warn("Received invalid token invalidation message", "message", msg)
+}
+
+// tokenInvalidator listens for invalidations on
+// common.TokenInvalidationChannel until ctx is done. As messages sent while
+// not subscribed are lost, the whole cache is dropped every time the
+// subscription is (re)established.
+func tokenInvalidator(ctx context.Context) {
+	for ctx.Err() == nil {
+		ps, err := red.Subscribe(common.TokenInvalidationChannel)
+		if err != nil {
+			slog.Error("Error subscribing to token invalidations", "error", err.Error())
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				ps.Close()
+			case <-done:
+			}
+		}()
+		resolvedTokens.invalidate(func(string, common.Token) bool { return true })
+
+		for {
+			msg, err := ps.ReceiveMessage()
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("Error receiving token invalidations", "error", err.Error())
+				}
+				break
+			}
+			resolvedTokens.handleInvalidation(msg.Payload)
+		}
+		close(done)
+		ps.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+func TestTokenCacheExpiry(t *testing.T) {
+	c := newTokenCache(time.Minute, 10)
+	tok := common.Token{ID: 1, UserID: 1000}
+
+	c.set("api:never", tok, time.Time{})
+	if _, ok := c.get("api:never"); !ok {
+		t.Error("token without expiry is not cached")
+	}
+	c.set("api:later", tok, time.Now().Add(time.Hour))
+	if e := c.entries["api:later"].Value.(*tokenCacheEntry); e.expires.After(time.Now().Add(time.Minute)) {
+		t.Errorf("entry expires at %v, after the TTL", e.expires)
+	}
+	c.set("api:expired", tok, time.Now().Add(-time.Second))
+	if _, ok := c.get("api:expired"); ok {
+		t.Error("token is cached past its expiry")
+	}
+}
+
+func TestTokenCacheInvalidation(t *testing.T) {
+	c := newTokenCache(time.Minute, 10)
+	c.set("api:a", common.Token{ID: 1, UserID: 1000}, time.Time{})
+	c.set("api:b", common.Token{ID: 2, UserID: 1000}, time.Time{})
+	c.set("bearer:c", common.Token{ID: -1, UserID: 1000}, time.Time{})
+	c.set("api:d", common.Token{ID: 3, UserID: 1001}, time.Time{})
+
+	c.handleInvalidation("token:1")
+	if _, ok := c.get("api:a"); ok {
+		t.Error("api:a is still cached after token:1")
+	}
+	c.handleInvalidation("bearer:c")
+	if _, ok := c.get("bearer:c"); ok {
+		t.Error("bearer:c is still cached after bearer:c")
+	}
+	c.handleInvalidation("user:1000")
+	if _, ok := c.get("api:b"); ok {
+		t.Error("api:b is still cached after user:1000")
+	}
+	if _, ok := c.get("api:d"); !ok {
+		t.Error("api:d of another user was dropped")
+	}
+}
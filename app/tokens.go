@@ -1,10 +1,12 @@
 package app
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
 	"golang.org/x/exp/slog"
@@ -14,94 +16,221 @@ import (
 )
 
 // GetTokenFull retrieves an user ID and their token privileges knowing their API token.
-func GetTokenFull(token string, db *sqlx.DB) (common.Token, bool) {
+// Tokens past their expiry are treated as if they did not exist.
+func GetTokenFull(ctx context.Context, token string, db *sqlx.DB) (common.Token, bool, error) {
+	hash := fmt.Sprintf("%x", md5.Sum([]byte(token)))
+	if t, ok := resolvedTokens.get("api:" + hash); ok {
+		usedTokens.markToken(t.ID)
+		return t, true, nil
+	}
+
 	var (
 		t             common.Token
 		tokenPrivsRaw uint64
 		userPrivsRaw  uint64
 		priv8         bool
+		expiresAt     sql.NullInt64
 	)
-	err := db.QueryRow(`SELECT
-	t.id, t.user, t.privileges, t.private, u.privileges
+	err := db.QueryRowContext(ctx, `SELECT
+	t.id, t.user, t.privileges, t.private, u.privileges, t.expires_at
 FROM tokens t
 INNER JOIN users u ON u.id = t.user
-WHERE token = ? LIMIT 1`,
-		fmt.Sprintf("%x", md5.Sum([]byte(token)))).
+WHERE token = ? AND (t.expires_at IS NULL OR t.expires_at > UNIX_TIMESTAMP())
+LIMIT 1`, hash).
 		Scan(
-			&t.ID, &t.UserID, &tokenPrivsRaw, &priv8, &userPrivsRaw,
+			&t.ID, &t.UserID, &tokenPrivsRaw, &priv8, &userPrivsRaw, &expiresAt,
 		)
-	updateTokens <- t.ID
+	switch {
+	case err == sql.ErrNoRows:
+		return common.Token{}, false, nil
+	case err != nil:
+		return common.Token{}, false, err
+	}
 	if priv8 {
 		// all privileges, they'll get removed by canOnly anyway.
 		tokenPrivsRaw = (common.PrivilegeBeatmap << 1) - 1
 	}
 	t.UserPrivileges = common.UserPrivileges(userPrivsRaw)
 	t.TokenPrivileges = common.Privileges(tokenPrivsRaw).CanOnly(t.UserPrivileges)
-	switch {
-	case err == sql.ErrNoRows:
-		return common.Token{}, false
-	case err != nil:
-		panic(err)
-	default:
-		t.Value = token
-		return t, true
+	// the user's own tokens, unlike OAuth ones, can manage the other tokens of
+	// the user.
+	if t.TokenPrivileges&common.PrivilegeWrite != 0 {
+		t.Scopes = []common.Scope{common.ScopeTokensManage}
+	}
+	usedTokens.markToken(t.ID)
+	t.Value = token
+	var expires time.Time
+	if expiresAt.Valid {
+		expires = time.Unix(expiresAt.Int64, 0)
+	}
+	resolvedTokens.set("api:"+hash, t, expires)
+	return t, true, nil
+}
+
+const (
+	// tokenFlushInterval is how often the last-used times of tokens are
+	// written to the database.
+	tokenFlushInterval = 10 * time.Second
+	// tokenFlushThreshold is the number of pending updates that triggers a
+	// write before tokenFlushInterval has passed.
+	tokenFlushThreshold = 500
+)
+
+// tokenUsage is the set of tokens that have been used since the last-used
+// times were last written to the database. Marking a token as used never
+// blocks on the database, and a token used many times is only written once.
+type tokenUsage struct {
+	mu sync.Mutex
+	// tokens are the IDs of API tokens.
+	tokens map[int]struct{}
+	// bearers are the hashed OAuth access tokens.
+	bearers map[string]struct{}
+	// since is when the oldest pending update was queued.
+	since time.Time
+	// full is signalled when there are tokenFlushThreshold pending updates.
+	full chan struct{}
+}
+
+var usedTokens = &tokenUsage{
+	tokens:  make(map[int]struct{}),
+	bearers: make(map[string]struct{}),
+	full:    make(chan struct{}, 1),
+}
+
+func (u *tokenUsage) markToken(id int) {
+	u.mu.Lock()
+	u.tokens[id] = struct{}{}
+	u.marked()
+	u.mu.Unlock()
+}
+
+func (u *tokenUsage) markBearer(hash string) {
+	u.mu.Lock()
+	u.bearers[hash] = struct{}{}
+	u.marked()
+	u.mu.Unlock()
+}
+
+// marked must be called with u.mu held after adding a token.
+func (u *tokenUsage) marked() {
+	if u.since.IsZero() {
+		u.since = time.Now()
+	}
+	if len(u.tokens)+len(u.bearers) >= tokenFlushThreshold {
+		select {
+		case u.full <- struct{}{}:
+		default:
+		}
 	}
 }
 
-var updateTokens = make(chan int, 100)
+// take empties the set, returning what was in it.
+func (u *tokenUsage) take() (tokens []int, bearers []string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id := range u.tokens {
+		tokens = append(tokens, id)
+	}
+	for h := range u.bearers {
+		bearers = append(bearers, h)
+	}
+	u.tokens = make(map[int]struct{})
+	u.bearers = make(map[string]struct{})
+	u.since = time.Time{}
+	return
+}
 
-func tokenUpdater(db *sqlx.DB) {
+// len returns the number of pending updates.
+func (u *tokenUsage) len() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.tokens) + len(u.bearers)
+}
+
+// lag returns for how long the oldest pending update has been waiting.
+func (u *tokenUsage) lag() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.since.IsZero() {
+		return 0
+	}
+	return time.Since(u.since)
+}
+
+// tokenUpdater writes the last-used times of tokens to the database every
+// tokenFlushInterval, or earlier if there are many of them. Once ctx is done,
+// it writes what is left and returns.
+func tokenUpdater(ctx context.Context, db *sqlx.DB) {
+	t := time.NewTicker(tokenFlushInterval)
+	defer t.Stop()
 	for {
-		// prepare a queue of tokens to update.
-		tokensToUpdate := make([]int, 0, 50)
-	AwaitLoop:
-		for {
-			// if we got ten, move on and update
-			if len(tokensToUpdate) >= 50 {
-				break
-			}
-			// if we ain't got any, add what we get straight from updateTokens
-			if len(tokensToUpdate) == 0 {
-				x := <-updateTokens
-				tokensToUpdate = append(tokensToUpdate, x)
-				continue
-			}
-
-			// otherwise, wait from updateTokens with a timeout of 10 seconds
-			select {
-			case x := <-updateTokens:
-				tokensToUpdate = append(tokensToUpdate, x)
-			case <-time.After(10 * time.Second):
-				// wondering what this means?
-				// https://golang.org/ref/spec#Break_statements
-				break AwaitLoop
-			}
+		select {
+		case <-ctx.Done():
+			flushTokenUpdates(db)
+			return
+		case <-t.C:
+		case <-usedTokens.full:
 		}
+		flushTokenUpdates(db)
+	}
+}
 
-		q, a, _ := sqlx.In("UPDATE tokens SET last_updated = ? WHERE id IN (?)", time.Now().Unix(), tokensToUpdate)
+func flushTokenUpdates(db *sqlx.DB) {
+	tokens, bearers := usedTokens.take()
 
-		q = db.Rebind(q)
-		_, err := db.Exec(q, a...)
+	if len(tokens) > 0 {
+		q, a, _ := sqlx.In("UPDATE tokens SET last_updated = ? WHERE id IN (?)", time.Now().Unix(), tokens)
+		_, err := db.Exec(db.Rebind(q), a...)
 		if err != nil {
 			slog.Error("Error updating tokens", "error", err.Error())
 		}
 	}
+
+	if len(bearers) > 0 {
+		q, a, _ := sqlx.In("UPDATE osin_access SET last_used = NOW() WHERE access_token IN (?)", bearers)
+		_, err := db.Exec(db.Rebind(q), a...)
+		if err != nil {
+			slog.Error("Error updating OAuth access tokens", "error", err.Error())
+		}
+	}
 }
 
 // BearerToken parses a Token given in the Authorization header, with the
-// Bearer prefix.
-func BearerToken(token string, db *sqlx.DB) (common.Token, bool) {
+// Bearer prefix. Expired access tokens are not accepted.
+func BearerToken(ctx context.Context, token string, db *sqlx.DB) (common.Token, bool, error) {
 	var x struct {
-		Scope string
-		Extra int
+		Scope     string
+		Extra     int
+		ExpiresAt int64 `db:"expires_at"`
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+	if t, ok := resolvedTokens.get("bearer:" + hash); ok {
+		usedTokens.markBearer(hash)
+		return t, true, nil
+	}
+
+	err := db.GetContext(ctx, &x, `SELECT scope, extra, UNIX_TIMESTAMP(created_at + INTERVAL expires_in SECOND) AS expires_at FROM osin_access
+WHERE access_token = ? AND created_at + INTERVAL expires_in SECOND > NOW()
+LIMIT 1`, hash)
+	switch {
+	case err == sql.ErrNoRows:
+		return common.Token{}, false, nil
+	case err != nil:
+		return common.Token{}, false, err
 	}
-	db.Get(&x, "SELECT scope, extra FROM osin_access WHERE access_token = ? LIMIT 1", fmt.Sprintf("%x", sha256.Sum256([]byte(token))))
 	if x.Extra == 0 {
-		return common.Token{}, false
+		return common.Token{}, false, nil
 	}
 
 	var privs uint64
-	db.Get(&privs, "SELECT privileges FROM users WHERE id = ? LIMIT 1", x.Extra)
+	err = db.GetContext(ctx, &privs, "SELECT privileges FROM users WHERE id = ? LIMIT 1", x.Extra)
+	switch {
+	case err == sql.ErrNoRows:
+		return common.Token{}, false, nil
+	case err != nil:
+		return common.Token{}, false, err
+	}
+	usedTokens.markBearer(hash)
 
 	var t common.Token
 	t.ID = -1
@@ -109,6 +238,8 @@ func BearerToken(token string, db *sqlx.DB) (common.Token, bool) {
 	t.Value = token
 	t.UserPrivileges = common.UserPrivileges(privs)
 	t.TokenPrivileges = common.OAuthPrivileges(x.Scope).CanOnly(t.UserPrivileges)
+	t.Scopes = common.OAuthFineScopes(x.Scope, t.UserPrivileges)
+	resolvedTokens.set("bearer:"+hash, t, time.Unix(x.ExpiresAt, 0))
 
-	return t, true
+	return t, true, nil
 }
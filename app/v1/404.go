@@ -17,13 +17,14 @@ func Handle404(c *fasthttp.RequestCtx) {
 	c.Response.Header.Add("X-Real-404", "yes")
 	data, err := json.MarshalIndent(response404{
 		ResponseBase: common.ResponseBase{
-			Code: 404,
+			Code:  common.ErrRouteNotFound.Status,
+			Error: common.ErrRouteNotFound.Code,
 		},
 		Cats: surpriseMe(),
 	}, "", "\t")
 	if err != nil {
 		panic(err)
 	}
-	c.SetStatusCode(404)
+	c.SetStatusCode(common.ErrRouteNotFound.Status)
 	c.Write(data)
 }
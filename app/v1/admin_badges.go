@@ -0,0 +1,385 @@
+package v1
+
+import (
+	"database/sql"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// badgeKind describes the tables of a kind of badge: the regular badges, or
+// the tournament badges.
+type badgeKind struct {
+	// table holds the badges, and members which user has which badge.
+	table   string
+	members string
+	// name is the target type of the badges in the audit log, and their
+	// cache tag.
+	name string
+	// titles is whether the badges of this kind give titles, see
+	// getEligibleTitles.
+	titles bool
+}
+
+var (
+	regularBadges    = badgeKind{table: "badges", members: "user_badges", name: "badges", titles: true}
+	tournamentBadges = badgeKind{table: "tourmnt_badges", members: "user_tourmnt_badges", name: "tbadges"}
+)
+
+type badgeNewData struct {
+	Name   string `json:"name" validate:"required,max=32"`
+	Icon   string `json:"icon" validate:"required,max=32"`
+	Colour string `json:"colour" validate:"max=16"`
+}
+
+type badgeEditData struct {
+	ID     int     `json:"id" validate:"required"`
+	Name   *string `json:"name" validate:"min=1,max=32"`
+	Icon   *string `json:"icon" validate:"min=1,max=32"`
+	Colour *string `json:"colour" validate:"max=16"`
+}
+
+type tbadgeNewData struct {
+	Name string `json:"name" validate:"required,max=32"`
+	Icon string `json:"icon" validate:"required,max=32"`
+}
+
+type tbadgeEditData struct {
+	ID   int     `json:"id" validate:"required"`
+	Name *string `json:"name" validate:"min=1,max=32"`
+	Icon *string `json:"icon" validate:"min=1,max=32"`
+}
+
+type badgeIDData struct {
+	ID int `json:"id" validate:"required"`
+}
+
+type badgeGrantData struct {
+	Badge int `json:"badge" validate:"required"`
+	User  int `json:"user" validate:"required"`
+}
+
+type singleBadgeResponse struct {
+	common.ResponseBase
+	Badge singleBadge `json:"badge"`
+}
+
+type singleTBadgeResponse struct {
+	common.ResponseBase
+	Badge TsingleBadge `json:"tbadge"`
+}
+
+// BadgeNewPOST creates a new badge.
+func BadgeNewPOST(md common.MethodData) common.CodeMessager {
+	var d badgeNewData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	res, err := md.DB.ExecContext(md.Context, "INSERT INTO badges(name, icon, colour) VALUES (?, ?, ?)", d.Name, d.Icon, d.Colour)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r := singleBadgeResponse{Badge: singleBadge{ID: int(id), Name: d.Name, Icon: d.Icon, Colour: d.Colour}}
+	md.Audit(regularBadges.auditType(), id, nil, r.Badge)
+	md.InvalidateCache(regularBadges.name)
+	r.Code = 200
+	return r
+}
+
+// BadgeEditPOST changes the name, icon or colour of a badge.
+func BadgeEditPOST(md common.MethodData) common.CodeMessager {
+	var d badgeEditData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	q := new(common.UpdateQuery).
+		Add("name", d.Name).
+		Add("icon", d.Icon).
+		Add("colour", d.Colour)
+	if errResp := editBadge(md, regularBadges, d.ID, q); errResp != nil {
+		return errResp
+	}
+
+	r := singleBadgeResponse{Badge: singleBadge{ID: d.ID}}
+	err := md.DB.QueryRowContext(md.Context, "SELECT name, icon, colour FROM badges WHERE id = ?", d.ID).
+		Scan(&r.Badge.Name, &r.Badge.Icon, &r.Badge.Colour)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
+
+// BadgeDeletePOST deletes a badge, taking it away from all the users who
+// have it.
+func BadgeDeletePOST(md common.MethodData) common.CodeMessager {
+	return deleteBadge(md, regularBadges)
+}
+
+// BadgeGrantPOST gives a badge to a user.
+func BadgeGrantPOST(md common.MethodData) common.CodeMessager {
+	return grantBadge(md, regularBadges)
+}
+
+// BadgeRevokePOST takes a badge away from a user.
+func BadgeRevokePOST(md common.MethodData) common.CodeMessager {
+	return revokeBadge(md, regularBadges)
+}
+
+// TBadgeNewPOST creates a new tournament badge.
+func TBadgeNewPOST(md common.MethodData) common.CodeMessager {
+	var d tbadgeNewData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	res, err := md.DB.ExecContext(md.Context, "INSERT INTO tourmnt_badges(name, icon) VALUES (?, ?)", d.Name, d.Icon)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r := singleTBadgeResponse{Badge: TsingleBadge{ID: int(id), Name: d.Name, Icon: d.Icon}}
+	md.Audit(tournamentBadges.auditType(), id, nil, r.Badge)
+	md.InvalidateCache(tournamentBadges.name)
+	r.Code = 200
+	return r
+}
+
+// TBadgeEditPOST changes the name or icon of a tournament badge.
+func TBadgeEditPOST(md common.MethodData) common.CodeMessager {
+	var d tbadgeEditData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	q := new(common.UpdateQuery).
+		Add("name", d.Name).
+		Add("icon", d.Icon)
+	if errResp := editBadge(md, tournamentBadges, d.ID, q); errResp != nil {
+		return errResp
+	}
+
+	r := singleTBadgeResponse{Badge: TsingleBadge{ID: d.ID}}
+	err := md.DB.QueryRowContext(md.Context, "SELECT name, icon FROM tourmnt_badges WHERE id = ?", d.ID).
+		Scan(&r.Badge.Name, &r.Badge.Icon)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
+
+// TBadgeDeletePOST deletes a tournament badge, taking it away from all the
+// users who have it.
+func TBadgeDeletePOST(md common.MethodData) common.CodeMessager {
+	return deleteBadge(md, tournamentBadges)
+}
+
+// TBadgeGrantPOST gives a tournament badge to a user.
+func TBadgeGrantPOST(md common.MethodData) common.CodeMessager {
+	return grantBadge(md, tournamentBadges)
+}
+
+// TBadgeRevokePOST takes a tournament badge away from a user.
+func TBadgeRevokePOST(md common.MethodData) common.CodeMessager {
+	return revokeBadge(md, tournamentBadges)
+}
+
+func editBadge(md common.MethodData, k badgeKind, id int, q *common.UpdateQuery) common.CodeMessager {
+	columns := q.Columns()
+	if len(columns) == 0 {
+		// nothing to change, but the badge must still exist.
+		columns = []string{"id"}
+	}
+	before, err := auditColumns(md, k.table, id, columns)
+	switch {
+	case err == sql.ErrNoRows:
+		return common.ErrBadgeNotFound
+	case err != nil:
+		md.Err(err)
+		return Err500
+	}
+	if len(q.Columns()) == 0 {
+		return nil
+	}
+	_, err = md.DB.ExecContext(md.Context, "UPDATE "+k.table+" SET "+q.Fields()+" WHERE id = ?", append(q.Parameters, id)...)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit(k.auditType(), id, before, q.Changes())
+	// the badges are shown on the profiles of their members.
+	md.InvalidateCache(k.name, "users")
+	return nil
+}
+
+func deleteBadge(md common.MethodData, k badgeKind) common.CodeMessager {
+	var d badgeIDData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	before, err := auditColumns(md, k.table, d.ID, []string{"name", "icon"})
+	switch {
+	case err == sql.ErrNoRows:
+		return common.ErrBadgeNotFound
+	case err != nil:
+		md.Err(err)
+		return Err500
+	}
+
+	var members []int
+	err = md.DB.SelectContext(md.Context, &members, "SELECT user FROM "+k.members+" WHERE badge = ?", d.ID)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+
+	tx, err := md.DB.BeginTx(md.Context, nil)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if _, err := tx.ExecContext(md.Context, "DELETE FROM "+k.members+" WHERE badge = ?", d.ID); err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	if _, err := tx.ExecContext(md.Context, "DELETE FROM "+k.table+" WHERE id = ?", d.ID); err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	if err := tx.Commit(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit(k.auditType(), d.ID, before, nil)
+
+	if k.titles {
+		for _, u := range members {
+			if err := refreshUserTitle(md, u); err != nil {
+				md.Err(err)
+			}
+		}
+	}
+	md.InvalidateCache(k.name, "users")
+	return common.SimpleResponse(200, "badge deleted")
+}
+
+func grantBadge(md common.MethodData, k badgeKind) common.CodeMessager {
+	var d badgeGrantData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	if errResp := checkBadgeGrant(md, k, d); errResp != nil {
+		return errResp
+	}
+
+	var has bool
+	err := md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM "+k.members+" WHERE user = ? AND badge = ?)", d.User, d.Badge).Scan(&has)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if has {
+		return common.ErrBadgeAlreadyGranted
+	}
+	_, err = md.DB.ExecContext(md.Context, "INSERT INTO "+k.members+"(user, badge) VALUES (?, ?)", d.User, d.Badge)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("user", d.User, nil, map[string]interface{}{k.auditType(): d.Badge})
+	md.InvalidateCache(k.name, "users")
+	return common.SimpleResponse(200, "badge granted")
+}
+
+func revokeBadge(md common.MethodData, k badgeKind) common.CodeMessager {
+	var d badgeGrantData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	res, err := md.DB.ExecContext(md.Context, "DELETE FROM "+k.members+" WHERE user = ? AND badge = ?", d.User, d.Badge)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return common.ErrBadgeNotGranted
+	}
+	md.Audit("user", d.User, map[string]interface{}{k.auditType(): d.Badge}, nil)
+
+	if k.titles {
+		if err := refreshUserTitle(md, d.User); err != nil {
+			md.Err(err)
+		}
+	}
+	md.InvalidateCache(k.name, "users")
+	return common.SimpleResponse(200, "badge revoked")
+}
+
+// checkBadgeGrant checks that both the badge and the user of a grant exist.
+func checkBadgeGrant(md common.MethodData, k badgeKind, d badgeGrantData) common.CodeMessager {
+	var exists bool
+	err := md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM "+k.table+" WHERE id = ?)", d.Badge).Scan(&exists)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if !exists {
+		return common.ErrBadgeNotFound
+	}
+	err = md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", d.User).Scan(&exists)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if !exists {
+		return common.ErrUserNotFound
+	}
+	return nil
+}
+
+// auditType is the target type of the badges in the audit log.
+func (k badgeKind) auditType() string {
+	return k.name[:len(k.name)-1]
+}
+
+// refreshUserTitle clears the title of a user if they are no longer eligible
+// for it, such as after losing the badge giving it. They are then shown the
+// first title they are eligible for, as the users who never picked any.
+func refreshUserTitle(md common.MethodData, userID int) error {
+	var (
+		privileges uint64
+		title      sql.NullString
+	)
+	err := md.DB.QueryRowContext(md.Context, "SELECT privileges, user_title FROM users WHERE id = ?", userID).Scan(&privileges, &title)
+	if err != nil || !title.Valid || title.String == "" {
+		return err
+	}
+	eligibleTitles, err := getEligibleTitles(md, userID, privileges)
+	if err != nil {
+		return err
+	}
+	for _, t := range eligibleTitles {
+		if t.ID == title.String {
+			return nil
+		}
+	}
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET user_title = NULL WHERE id = ? AND user_title = ?", userID, title.String)
+	if err != nil {
+		return err
+	}
+	md.Audit("user", userID, map[string]interface{}{"user_title": title.String}, map[string]interface{}{"user_title": nil})
+	return nil
+}
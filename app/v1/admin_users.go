@@ -0,0 +1,421 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"gopkg.in/redis.v5"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// moderationEventChannel is the redis channel on which every moderation
+// action is published as a moderationEvent, so that bancho can apply it to
+// the users who are online.
+const moderationEventChannel = "api:user_moderation"
+
+type moderationData struct {
+	ID     int    `json:"id" validate:"required,min=1"`
+	Reason string `json:"reason" validate:"required,max=2048"`
+}
+
+type silenceData struct {
+	moderationData
+	// Duration is how long the user is silenced for, in seconds, up to four
+	// weeks.
+	Duration int `json:"duration" validate:"required,min=1,max=2419200"`
+}
+
+// moderationState is the state of a user that can be changed by moderators.
+type moderationState struct {
+	ID          int                  `json:"id"`
+	Privileges  uint64               `json:"privileges"`
+	Restricted  bool                 `json:"restricted"`
+	Banned      bool                 `json:"banned"`
+	Frozen      bool                 `json:"frozen"`
+	BanDate     common.UnixTimestamp `json:"ban_date"`
+	SilenceInfo silenceInfo          `json:"silence_info"`
+	country     string
+}
+
+type moderationResponse struct {
+	common.ResponseBase
+	User moderationState `json:"user"`
+}
+
+type moderationEvent struct {
+	UserID      int    `json:"user_id"`
+	ModeratorID int    `json:"moderator_id"`
+	Action      string `json:"action"`
+	Reason      string `json:"reason"`
+	// Duration is set for silences, in seconds.
+	Duration int `json:"duration,omitempty"`
+}
+
+// moderationAction describes how an action changes a user.
+type moderationAction struct {
+	name string
+	// privilege is the admin privilege needed for the action, in addition
+	// to the manage_user scope.
+	privilege common.UserPrivileges
+	// set is the SET clause updating the users table. Its parameters are
+	// those returned by params.
+	set    string
+	params func(d silenceData) []interface{}
+	// leaderboards is -1 if the user must be removed from the leaderboards,
+	// and 1 if they must be added back.
+	leaderboards int
+	// notBanned is set for the actions that can't be taken against banned
+	// users.
+	notBanned bool
+}
+
+var (
+	restrictAction = moderationAction{
+		name:         "restrict",
+		privilege:    common.AdminPrivilegeBanUsers,
+		set:          "privileges = privileges & ~?",
+		params:       privilegesParam(common.UserPrivilegePublic),
+		leaderboards: -1,
+	}
+	unrestrictAction = moderationAction{
+		name:         "unrestrict",
+		privilege:    common.AdminPrivilegeBanUsers,
+		set:          "privileges = privileges | ?, ban_datetime = 0",
+		params:       privilegesParam(common.UserPrivilegePublic),
+		leaderboards: 1,
+		// it would make them public without lifting the ban.
+		notBanned: true,
+	}
+	banAction = moderationAction{
+		name:         "ban",
+		privilege:    common.AdminPrivilegeBanUsers,
+		set:          "privileges = privileges & ~?, ban_datetime = UNIX_TIMESTAMP()",
+		params:       privilegesParam(common.UserPrivilegePublic | common.UserPrivilegeNormal),
+		leaderboards: -1,
+	}
+	unbanAction = moderationAction{
+		name:         "unban",
+		privilege:    common.AdminPrivilegeBanUsers,
+		set:          "privileges = privileges | ?, ban_datetime = 0",
+		params:       privilegesParam(common.UserPrivilegePublic | common.UserPrivilegeNormal),
+		leaderboards: 1,
+	}
+	freezeAction = moderationAction{
+		name:      "freeze",
+		privilege: common.AdminPrivilegeFreezeUsers,
+		set:       "frozen = 1",
+	}
+	unfreezeAction = moderationAction{
+		name:      "unfreeze",
+		privilege: common.AdminPrivilegeFreezeUsers,
+		set:       "frozen = 0",
+	}
+	silenceAction = moderationAction{
+		name:      "silence",
+		privilege: common.AdminPrivilegeSilenceUsers,
+		set:       "silence_end = UNIX_TIMESTAMP() + ?, silence_reason = ?",
+		params: func(d silenceData) []interface{} {
+			return []interface{}{d.Duration, d.Reason}
+		},
+	}
+	unsilenceAction = moderationAction{
+		name:      "unsilence",
+		privilege: common.AdminPrivilegeSilenceUsers,
+		set:       "silence_end = 0, silence_reason = ''",
+	}
+)
+
+func privilegesParam(p common.UserPrivileges) func(silenceData) []interface{} {
+	return func(silenceData) []interface{} {
+		return []interface{}{uint64(p)}
+	}
+}
+
+// AdminUserRestrictPOST restricts a user, hiding them from the leaderboards
+// and the other users.
+func AdminUserRestrictPOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, restrictAction)
+}
+
+// AdminUserUnrestrictPOST lifts the restriction of a user.
+func AdminUserUnrestrictPOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, unrestrictAction)
+}
+
+// AdminUserBanPOST bans a user, preventing them from logging in.
+func AdminUserBanPOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, banAction)
+}
+
+// AdminUserUnbanPOST lifts the ban of a user.
+func AdminUserUnbanPOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, unbanAction)
+}
+
+// AdminUserFreezePOST freezes a user, who has to verify their identity before
+// playing again.
+func AdminUserFreezePOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, freezeAction)
+}
+
+// AdminUserUnfreezePOST unfreezes a user.
+func AdminUserUnfreezePOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, unfreezeAction)
+}
+
+// AdminUserSilencePOST prevents a user from chatting for the given duration.
+func AdminUserSilencePOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, silenceAction)
+}
+
+// AdminUserUnsilencePOST lifts the silence of a user.
+func AdminUserUnsilencePOST(md common.MethodData) common.CodeMessager {
+	return moderateUser(md, unsilenceAction)
+}
+
+func moderateUser(md common.MethodData, a moderationAction) common.CodeMessager {
+	var d silenceData
+	var errResp common.CodeMessager
+	if a.name == "silence" {
+		errResp = md.ParseBody(&d)
+	} else {
+		errResp = md.ParseBody(&d.moderationData)
+	}
+	if errResp != nil {
+		return errResp
+	}
+	if md.User.UserPrivileges&a.privilege == 0 {
+		return common.ErrForbidden
+	}
+	if d.ID == md.ID() {
+		return common.ErrModerateSelf
+	}
+
+	tx, err := md.DB.BeginTx(md.Context, nil)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	before, err := moderationStateOf(md, tx, d.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		tx.Rollback()
+		return common.ErrUserNotFound
+	case err != nil:
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	if common.UserPrivileges(before.Privileges)&common.AdminPrivilegeAccessRAP != 0 &&
+		md.User.UserPrivileges&common.AdminPrivilegeManagePrivilege == 0 {
+		tx.Rollback()
+		return common.ErrModerateStaff
+	}
+	if a.notBanned && before.Banned {
+		tx.Rollback()
+		return common.ErrUserBanned
+	}
+
+	var params []interface{}
+	if a.params != nil {
+		params = a.params(d)
+	}
+	_, err = tx.ExecContext(md.Context, "UPDATE users SET "+a.set+" WHERE id = ?", append(params, d.ID)...)
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	var expiresAt interface{}
+	if d.Duration > 0 {
+		expiresAt = before.now + int64(d.Duration)
+	}
+	_, err = tx.ExecContext(md.Context, `INSERT INTO user_moderation_history(user_id, moderator_id, action, reason, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, UNIX_TIMESTAMP())`, d.ID, md.ID(), a.name, d.Reason, expiresAt)
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	after, err := moderationStateOf(md, tx, d.ID)
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	if err := tx.Commit(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("user", d.ID, before.moderationState, after.moderationState)
+
+	switch a.leaderboards {
+	case -1:
+		removeFromLeaderboards(md, after.moderationState)
+	case 1:
+		// users who are still banned or restricted are not added back.
+		if !after.Restricted && !after.Banned {
+			addToLeaderboards(md, after.moderationState)
+		}
+	}
+	if before.Privileges != after.Privileges {
+		md.InvalidateUserTokens(d.ID)
+	}
+	md.InvalidateCache("users", "leaderboard")
+
+	ev, _ := json.Marshal(moderationEvent{
+		UserID:      d.ID,
+		ModeratorID: md.ID(),
+		Action:      a.name,
+		Reason:      d.Reason,
+		Duration:    d.Duration,
+	})
+	if err := md.R.Publish(moderationEventChannel, string(ev)).Err(); err != nil {
+		md.Err(err)
+	}
+
+	r := moderationResponse{User: after.moderationState}
+	r.Code = 200
+	return r
+}
+
+// lockedModerationState is the moderation state of a user, along with the
+// time of the database when it was read.
+type lockedModerationState struct {
+	moderationState
+	now int64
+}
+
+// moderationStateOf reads the moderation state of a user, locking their row
+// until the end of tx.
+func moderationStateOf(md common.MethodData, tx *sql.Tx, id int) (lockedModerationState, error) {
+	s := lockedModerationState{moderationState: moderationState{ID: id}}
+	err := tx.QueryRowContext(md.Context, `SELECT privileges, frozen, ban_datetime, silence_end, silence_reason, country,
+		UNIX_TIMESTAMP() FROM users WHERE id = ? FOR UPDATE`, id).Scan(
+		&s.Privileges, &s.Frozen, &s.BanDate, &s.SilenceInfo.End, &s.SilenceInfo.Reason, &s.country, &s.now,
+	)
+	p := common.UserPrivileges(s.Privileges)
+	s.Banned = p&common.UserPrivilegeNormal == 0
+	s.Restricted = !s.Banned && p&common.UserPrivilegePublic == 0
+	return s, err
+}
+
+// leaderboardKeys returns the keys of the sorted sets of the global and
+// country leaderboards, for vanilla, relax and autopilot, along with the
+// user_stats mode they are made from.
+func leaderboardKeys(country string) map[string]int {
+	keys := make(map[string]int)
+	for i, board := range [...]string{"leaderboard", "relaxboard", "autoboard"} {
+		for modeID, m := range modesToReadable {
+			// relax has no mania, and autopilot is only osu! standard.
+			if (i == 1 && modeID == 3) || (i == 2 && modeID != 0) {
+				continue
+			}
+			key := "ripple:" + board + ":" + m
+			keys[key] = modeID + i*4
+			if country != "" {
+				keys[key+":"+strings.ToLower(country)] = modeID + i*4
+			}
+		}
+	}
+	return keys
+}
+
+// removeFromLeaderboards removes a user from all the leaderboards.
+func removeFromLeaderboards(md common.MethodData, s moderationState) {
+	p := md.R.Pipeline()
+	defer p.Close()
+	for key := range leaderboardKeys(s.country) {
+		p.ZRem(key, strconv.Itoa(s.ID))
+	}
+	if _, err := p.Exec(); err != nil {
+		md.Err(err)
+	}
+}
+
+// addToLeaderboards adds a user back to the leaderboards, with their current
+// pp.
+func addToLeaderboards(md common.MethodData, s moderationState) {
+	rows, err := md.DB.QueryContext(md.Context, "SELECT mode, pp FROM user_stats WHERE user_id = ? AND pp > 0", s.ID)
+	if err != nil {
+		md.Err(err)
+		return
+	}
+	defer rows.Close()
+	pp := make(map[int]float64)
+	for rows.Next() {
+		var (
+			mode  int
+			value float64
+		)
+		if err := rows.Scan(&mode, &value); err != nil {
+			md.Err(err)
+			return
+		}
+		pp[mode] = value
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+		return
+	}
+
+	p := md.R.Pipeline()
+	defer p.Close()
+	for key, mode := range leaderboardKeys(s.country) {
+		if v, ok := pp[mode]; ok {
+			p.ZAdd(key, redis.Z{Score: v, Member: strconv.Itoa(s.ID)})
+		}
+	}
+	if _, err := p.Exec(); err != nil {
+		md.Err(err)
+	}
+}
+
+type moderationHistoryEntry struct {
+	ID          int                   `json:"id"`
+	ModeratorID int                   `json:"moderator_id"`
+	Action      string                `json:"action"`
+	Reason      string                `json:"reason"`
+	ExpiresAt   *common.UnixTimestamp `json:"expires_at"`
+	Time        common.UnixTimestamp  `json:"time"`
+}
+
+type moderationHistoryResponse struct {
+	common.ResponseBase
+	History []moderationHistoryEntry `json:"history"`
+}
+
+// AdminUserModerationHistoryGET retrieves the moderation actions taken
+// against a user, most recent first.
+func AdminUserModerationHistoryGET(md common.MethodData) common.CodeMessager {
+	id := common.Int(md.Query("id"))
+	if id == 0 {
+		return common.ErrInvalidID
+	}
+	rows, err := md.DB.QueryContext(md.Context, `SELECT id, moderator_id, action, reason, expires_at, created_at
+		FROM user_moderation_history WHERE user_id = ? ORDER BY id DESC `+common.Paginate(md.Query("p"), md.Query("l"), 100), id)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+
+	r := moderationHistoryResponse{History: []moderationHistoryEntry{}}
+	for rows.Next() {
+		var e moderationHistoryEntry
+		if err := rows.Scan(&e.ID, &e.ModeratorID, &e.Action, &e.Reason, &e.ExpiresAt, &e.Time); err != nil {
+			md.Err(err)
+			return Err500
+		}
+		r.History = append(r.History, e)
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
@@ -0,0 +1,285 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// globalAlertChannel is the redis channel on which every change of the
+// global alerts is published as a globalAlertEvent, so that bancho can show
+// the new alerts to the users online.
+const globalAlertChannel = "api:global_alert"
+
+// globalAlert is an announcement shown as a banner on the website or in
+// bancho while it is active, that is to say from StartsAt until EndsAt, or
+// forever if EndsAt is null.
+type globalAlert struct {
+	ID        int                   `json:"id"`
+	Message   string                `json:"message"`
+	Severity  string                `json:"severity"`
+	Target    string                `json:"target"`
+	StartsAt  common.UnixTimestamp  `json:"starts_at"`
+	EndsAt    *common.UnixTimestamp `json:"ends_at"`
+	CreatedBy int                   `json:"created_by"`
+	CreatedAt common.UnixTimestamp  `json:"created_at"`
+}
+
+const globalAlertFields = "id, message, severity, target, starts_at, ends_at, created_by, created_at"
+
+func (a *globalAlert) scan(row interface{ Scan(...interface{}) error }) error {
+	var endsAt sql.NullInt64
+	err := row.Scan(&a.ID, &a.Message, &a.Severity, &a.Target, &a.StartsAt, &endsAt, &a.CreatedBy, &a.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if endsAt.Valid {
+		t := common.UnixTimestamp(time.Unix(endsAt.Int64, 0))
+		a.EndsAt = &t
+	}
+	return nil
+}
+
+type globalAlertsResponse struct {
+	common.ResponseBase
+	Alerts []globalAlert `json:"alerts"`
+}
+
+type singleGlobalAlertResponse struct {
+	common.ResponseBase
+	Alert globalAlert `json:"alert"`
+}
+
+type globalAlertEvent struct {
+	Action string      `json:"action"`
+	Alert  globalAlert `json:"alert"`
+}
+
+// AlertsGET lists the global alerts active right now, for all targets or,
+// with target, for the website or bancho only.
+func AlertsGET(md common.MethodData) common.CodeMessager {
+	wc := common.Where("target IN ('all', ?)", md.Query("target"), "website", "bancho")
+	clause := "WHERE "
+	if wc.Clause != "" {
+		clause = wc.Clause + " AND "
+	}
+	return listAlerts(md, "SELECT "+globalAlertFields+" FROM global_alerts "+clause+
+		"starts_at <= UNIX_TIMESTAMP() AND (ends_at IS NULL OR ends_at > UNIX_TIMESTAMP()) "+
+		"ORDER BY FIELD(severity, 'critical', 'warning', 'info'), starts_at DESC", wc.Params...)
+}
+
+// AdminAlertsGET lists all the global alerts, including the ones that ended
+// or are yet to start, most recent first.
+func AdminAlertsGET(md common.MethodData) common.CodeMessager {
+	return listAlerts(md, "SELECT "+globalAlertFields+" FROM global_alerts ORDER BY id DESC "+
+		common.Paginate(md.Query("p"), md.Query("l"), 100))
+}
+
+func listAlerts(md common.MethodData, query string, params ...interface{}) common.CodeMessager {
+	rows, err := md.DB.QueryContext(md.Context, query, params...)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+	r := globalAlertsResponse{Alerts: []globalAlert{}}
+	for rows.Next() {
+		var a globalAlert
+		if err := a.scan(rows); err != nil {
+			md.Err(err)
+			return Err500
+		}
+		r.Alerts = append(r.Alerts, a)
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
+
+type alertNewData struct {
+	Message  string `json:"message" validate:"required,max=1024"`
+	Severity string `json:"severity" validate:"required,oneof=info warning critical"`
+	// Target is where the alert is shown: all (the default), website or
+	// bancho.
+	Target string `json:"target" validate:"oneof=all website bancho"`
+	// StartsAt defaults to now.
+	StartsAt *common.UnixTimestamp `json:"starts_at"`
+	// EndsAt is null for alerts shown until they are deleted.
+	EndsAt *common.UnixTimestamp `json:"ends_at"`
+}
+
+// AlertNewPOST creates a global alert.
+func AlertNewPOST(md common.MethodData) common.CodeMessager {
+	if md.User.UserPrivileges&common.AdminPrivilegeSendAlerts == 0 {
+		return common.ErrForbidden
+	}
+	var d alertNewData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	if d.Target == "" {
+		d.Target = "all"
+	}
+	startsAt := time.Now()
+	if d.StartsAt != nil {
+		startsAt = time.Time(*d.StartsAt)
+	}
+	var endsAt *int64
+	if d.EndsAt != nil {
+		if !time.Time(*d.EndsAt).After(startsAt) {
+			return common.ErrInvalidAlertWindow
+		}
+		unix := time.Time(*d.EndsAt).Unix()
+		endsAt = &unix
+	}
+
+	res, err := md.DB.ExecContext(md.Context, `INSERT INTO global_alerts(message, severity, target, starts_at, ends_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, UNIX_TIMESTAMP())`, d.Message, d.Severity, d.Target, startsAt.Unix(), endsAt, md.ID())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	a, err := getAlert(md, int(id))
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("alert", id, nil, a)
+	alertChanged(md, "new", a)
+	r := singleGlobalAlertResponse{Alert: a}
+	r.Code = 200
+	return r
+}
+
+type alertEditData struct {
+	ID       int                   `json:"id" validate:"required"`
+	Message  *string               `json:"message" validate:"max=1024"`
+	Severity *string               `json:"severity" validate:"oneof=info warning critical"`
+	Target   *string               `json:"target" validate:"oneof=all website bancho"`
+	StartsAt *common.UnixTimestamp `json:"starts_at"`
+	// EndsAt is kept raw to tell apart a missing field (don't change the end)
+	// from an explicit null (show the alert until it is deleted).
+	EndsAt json.RawMessage `json:"ends_at"`
+}
+
+// AlertEditPOST changes a global alert. The fields left out are not changed.
+func AlertEditPOST(md common.MethodData) common.CodeMessager {
+	if md.User.UserPrivileges&common.AdminPrivilegeSendAlerts == 0 {
+		return common.ErrForbidden
+	}
+	var d alertEditData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	before, err := getAlert(md, d.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		return common.ErrAlertNotFound
+	case err != nil:
+		md.Err(err)
+		return Err500
+	}
+
+	startsAt, endsAt := time.Time(before.StartsAt), before.EndsAt
+	if d.StartsAt != nil {
+		startsAt = time.Time(*d.StartsAt)
+	}
+	if len(d.EndsAt) != 0 {
+		endsAt = nil
+		if string(d.EndsAt) != "null" {
+			endsAt = new(common.UnixTimestamp)
+			if err := json.Unmarshal(d.EndsAt, endsAt); err != nil {
+				return common.ErrInvalidAlertWindow
+			}
+		}
+	}
+	var endsAtUnix sql.NullInt64
+	if endsAt != nil {
+		if !time.Time(*endsAt).After(startsAt) {
+			return common.ErrInvalidAlertWindow
+		}
+		endsAtUnix = sql.NullInt64{Int64: time.Time(*endsAt).Unix(), Valid: true}
+	}
+
+	_, err = md.DB.ExecContext(md.Context, `UPDATE global_alerts SET message = ?, severity = ?, target = ?,
+		starts_at = ?, ends_at = ? WHERE id = ?`,
+		valueOr(d.Message, before.Message), valueOr(d.Severity, before.Severity), valueOr(d.Target, before.Target),
+		startsAt.Unix(), endsAtUnix, d.ID)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	after, err := getAlert(md, d.ID)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("alert", d.ID, before, after)
+	alertChanged(md, "edit", after)
+	r := singleGlobalAlertResponse{Alert: after}
+	r.Code = 200
+	return r
+}
+
+type alertIDData struct {
+	ID int `json:"id" validate:"required"`
+}
+
+// AlertDeletePOST deletes a global alert.
+func AlertDeletePOST(md common.MethodData) common.CodeMessager {
+	if md.User.UserPrivileges&common.AdminPrivilegeSendAlerts == 0 {
+		return common.ErrForbidden
+	}
+	var d alertIDData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	a, err := getAlert(md, d.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		return common.ErrAlertNotFound
+	case err != nil:
+		md.Err(err)
+		return Err500
+	}
+	if _, err := md.DB.ExecContext(md.Context, "DELETE FROM global_alerts WHERE id = ?", d.ID); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("alert", d.ID, a, nil)
+	alertChanged(md, "delete", a)
+	return common.SimpleResponse(200, "alert deleted")
+}
+
+func getAlert(md common.MethodData, id int) (globalAlert, error) {
+	var a globalAlert
+	err := a.scan(md.DB.QueryRowContext(md.Context, "SELECT "+globalAlertFields+" FROM global_alerts WHERE id = ?", id))
+	return a, err
+}
+
+// alertChanged drops the cached lists of alerts, and tells bancho about the
+// change.
+func alertChanged(md common.MethodData, action string, a globalAlert) {
+	md.InvalidateCache("alerts")
+	b, _ := json.Marshal(globalAlertEvent{Action: action, Alert: a})
+	if err := md.R.Publish(globalAlertChannel, string(b)).Err(); err != nil {
+		md.Err(err)
+	}
+}
+
+func valueOr(s *string, def string) string {
+	if s == nil {
+		return def
+	}
+	return *s
+}
@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+type auditEntry struct {
+	ID         int                  `json:"id"`
+	UserID     int                  `json:"user_id"`
+	TokenID    int                  `json:"token_id"`
+	Route      string               `json:"route"`
+	TargetType string               `json:"target_type"`
+	TargetID   string               `json:"target_id"`
+	Before     json.RawMessage      `json:"before"`
+	After      json.RawMessage      `json:"after"`
+	IP         string               `json:"ip"`
+	Time       common.UnixTimestamp `json:"time"`
+}
+
+type auditResponse struct {
+	common.ResponseBase
+	Entries []auditEntry `json:"entries"`
+}
+
+// AuditSelfGET retrieves the entries of the audit log of the changes made by
+// the user.
+func AuditSelfGET(md common.MethodData) common.CodeMessager {
+	return auditEntries(md, common.Where("user_id = ?", strconv.Itoa(md.ID())))
+}
+
+// AuditGET retrieves the entries of the audit log of any user, or of all the
+// users if none is given.
+func AuditGET(md common.MethodData) common.CodeMessager {
+	return auditEntries(md, new(common.WhereClause).Where("user_id = ?", md.Query("user")))
+}
+
+func auditEntries(md common.MethodData, wc *common.WhereClause) common.CodeMessager {
+	wc.Where("target_type = ?", md.Query("target_type")).
+		Where("target_id = ?", md.Query("target_id")).
+		Where("route = ?", md.Query("route"))
+	rows, err := md.DB.QueryContext(md.Context, `SELECT id, user_id, token_id, route, target_type, target_id,
+		before_state, after_state, ip, created_at FROM audit_log `+
+		wc.ClauseSafe()+" ORDER BY id DESC "+common.Paginate(md.Query("p"), md.Query("l"), 100), wc.Params...)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+
+	r := auditResponse{Entries: []auditEntry{}}
+	for rows.Next() {
+		var (
+			e             auditEntry
+			before, after sql.NullString
+		)
+		err := rows.Scan(&e.ID, &e.UserID, &e.TokenID, &e.Route, &e.TargetType, &e.TargetID,
+			&before, &after, &e.IP, &e.Time)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		e.Before, e.After = rawJSON(before), rawJSON(after)
+		r.Entries = append(r.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
+
+func rawJSON(s sql.NullString) json.RawMessage {
+	if !s.Valid {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(s.String)
+}
+
+// auditColumns retrieves the current value of the given columns of a row,
+// to be logged in the audit log before they are changed.
+func auditColumns(md common.MethodData, table string, id int, columns []string) (map[string]interface{}, error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]interface{}, len(columns))
+	err := md.DB.QueryRowxContext(md.Context, "SELECT "+strings.Join(columns, ", ")+" FROM "+table+" WHERE id = ?", id).MapScan(m)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range m {
+		if b, ok := v.([]byte); ok {
+			m[k] = string(b)
+		}
+	}
+	return m, nil
+}
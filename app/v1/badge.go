@@ -26,9 +26,9 @@ func BadgesGET(md common.MethodData) common.CodeMessager {
 		err  error
 	)
 	if md.Query("id") != "" {
-		rows, err = md.DB.Query("SELECT id, name, icon, colour FROM badges WHERE id = ? LIMIT 1", md.Query("id"))
+		rows, err = md.DB.QueryContext(md.Context, "SELECT id, name, icon, colour FROM badges WHERE id = ? LIMIT 1", md.Query("id"))
 	} else {
-		rows, err = md.DB.Query("SELECT id, name, icon, colour FROM badges " + common.Paginate(md.Query("p"), md.Query("l"), 50))
+		rows, err = md.DB.QueryContext(md.Context, "SELECT id, name, icon, colour FROM badges "+common.Paginate(md.Query("p"), md.Query("l"), 50))
 	}
 	if err != nil {
 		md.Err(err)
@@ -64,7 +64,7 @@ func BadgeMembersGET(md common.MethodData) common.CodeMessager {
 
 	var members badgeMembersData
 
-	err := md.DB.Select(&members.Members, `
+	err := md.DB.SelectContext(md.Context, &members.Members, `
 	SELECT users.id, users.username, users.register_datetime, users.privileges,
 	users.latest_activity, users.username_aka, users.country
 	FROM user_badges ub
@@ -84,7 +84,7 @@ func getMultipleBeatmaps(md common.MethodData) common.CodeMessager {
 		In("beatmapset_id", pm("s")...).
 		In("beatmap_md5", pm("md5")...)
 
-	rows, err := md.DB.Query(baseBeatmapSelect+
+	rows, err := md.DB.QueryContext(md.Context, baseBeatmapSelect+
 		where.Clause+" "+sort+" "+
 		common.Paginate(md.Query("p"), md.Query("l"), 50), where.Params...)
 	if err != nil {
@@ -112,7 +112,7 @@ func getMultipleBeatmaps(md common.MethodData) common.CodeMessager {
 
 func getBeatmapSingle(md common.MethodData, beatmapID int) common.CodeMessager {
 	var b beatmap
-	err := md.DB.QueryRow(baseBeatmapSelect+"WHERE beatmap_id = ? LIMIT 1", beatmapID).Scan(
+	err := md.DB.QueryRowContext(md.Context, baseBeatmapSelect+"WHERE beatmap_id = ? LIMIT 1", beatmapID).Scan(
 		&b.BeatmapID, &b.BeatmapsetID, &b.BeatmapMD5,
 		&b.SongName, &b.AR, &b.OD, &b.MaxCombo,
 		&b.HitLength, &b.Ranked, &b.RankedStatusFrozen,
@@ -120,7 +120,7 @@ func getBeatmapSingle(md common.MethodData, beatmapID int) common.CodeMessager {
 	)
 	switch {
 	case err == sql.ErrNoRows:
-		return common.SimpleResponse(404, "That beatmap could not be found!")
+		return common.ErrBeatmapNotFound
 	case err != nil:
 		md.Err(err)
 		return Err500
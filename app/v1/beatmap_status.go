@@ -0,0 +1,255 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// The ranked statuses of beatmaps, as in beatmaps.ranked.
+const (
+	rankedStatusPending   = 0
+	rankedStatusRanked    = 2
+	rankedStatusQualified = 4
+	rankedStatusLoved     = 5
+)
+
+// beatmapStatusChannel is the redis channel on which every change of the
+// ranked status of beatmaps is published as a beatmapStatusEvent, so that
+// the score server can drop its cached beatmaps and leaderboards.
+const beatmapStatusChannel = "api:beatmap_status"
+
+// beatmapStatusActions maps the actions of BeatmapStatusPOST to the ranked
+// status they set, or -1 if they only change whether it is frozen.
+var beatmapStatusActions = map[string]int{
+	"rank":     rankedStatusRanked,
+	"love":     rankedStatusLoved,
+	"qualify":  rankedStatusQualified,
+	"unrank":   rankedStatusPending,
+	"freeze":   -1,
+	"unfreeze": -1,
+}
+
+type beatmapStatusData struct {
+	// Either BeatmapID or BeatmapsetID must be given.
+	BeatmapID    int    `json:"beatmap_id"`
+	BeatmapsetID int    `json:"beatmapset_id"`
+	Action       string `json:"action" validate:"required,oneof=rank love qualify unrank freeze unfreeze"`
+	Reason       string `json:"reason" validate:"required,max=2048"`
+}
+
+// beatmapStatus is the ranked status of a beatmap, as published on
+// beatmapStatusChannel and logged in the audit log.
+type beatmapStatus struct {
+	BeatmapID  int    `json:"beatmap_id"`
+	BeatmapMD5 string `json:"beatmap_md5"`
+	Ranked     int    `json:"ranked"`
+	Frozen     bool   `json:"frozen"`
+}
+
+type beatmapStatusEvent struct {
+	Action   string          `json:"action"`
+	Beatmaps []beatmapStatus `json:"beatmaps"`
+}
+
+// BeatmapStatusPOST changes the ranked status of a beatmap, or of all the
+// beatmaps of a set. Setting the status also freezes it, so that it is not
+// overwritten when the beatmaps are updated from the osu! API.
+func BeatmapStatusPOST(md common.MethodData) common.CodeMessager {
+	var d beatmapStatusData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	if (d.BeatmapID == 0) == (d.BeatmapsetID == 0) {
+		return common.ErrBeatmapOrSet
+	}
+	where := "beatmap_id = ?"
+	id := d.BeatmapID
+	if d.BeatmapsetID != 0 {
+		where, id = "beatmapset_id = ?", d.BeatmapsetID
+	}
+
+	tx, err := md.DB.BeginTx(md.Context, nil)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	rows, err := tx.QueryContext(md.Context, "SELECT beatmap_id, beatmapset_id, beatmap_md5, ranked, ranked_status_freezed FROM beatmaps WHERE "+where+" FOR UPDATE", id)
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	var before []beatmap
+	for rows.Next() {
+		var b beatmap
+		if err := rows.Scan(&b.BeatmapID, &b.BeatmapsetID, &b.BeatmapMD5, &b.Ranked, &b.RankedStatusFrozen); err != nil {
+			rows.Close()
+			tx.Rollback()
+			md.Err(err)
+			return Err500
+		}
+		before = append(before, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+	if len(before) == 0 {
+		tx.Rollback()
+		return common.ErrBeatmapNotFound
+	}
+
+	frozen := 1
+	if d.Action == "unfreeze" {
+		frozen = 0
+	}
+	if status := beatmapStatusActions[d.Action]; status >= 0 {
+		_, err = tx.ExecContext(md.Context, "UPDATE beatmaps SET ranked = ?, ranked_status_freezed = ? WHERE "+where, status, frozen, id)
+	} else {
+		_, err = tx.ExecContext(md.Context, "UPDATE beatmaps SET ranked_status_freezed = ? WHERE "+where, frozen, id)
+	}
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return Err500
+	}
+
+	ev := beatmapStatusEvent{Action: d.Action}
+	after := make([]beatmap, len(before))
+	for i, b := range before {
+		after[i] = b
+		after[i].RankedStatusFrozen = frozen
+		if status := beatmapStatusActions[d.Action]; status >= 0 {
+			after[i].Ranked = status
+		}
+		_, err := tx.ExecContext(md.Context, `INSERT INTO beatmap_status_history(beatmap_id, beatmapset_id, beatmap_md5, user_id,
+			action, old_status, new_status, frozen, reason, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, UNIX_TIMESTAMP())`,
+			b.BeatmapID, b.BeatmapsetID, b.BeatmapMD5, md.ID(), d.Action, b.Ranked, after[i].Ranked, frozen, d.Reason)
+		if err != nil {
+			tx.Rollback()
+			md.Err(err)
+			return Err500
+		}
+		ev.Beatmaps = append(ev.Beatmaps, statusOf(after[i]))
+	}
+	if err := tx.Commit(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+
+	kind := "beatmap"
+	if d.BeatmapsetID != 0 {
+		kind = "beatmapset"
+	}
+	beforeStatus := make([]beatmapStatus, len(before))
+	for i, b := range before {
+		beforeStatus[i] = statusOf(b)
+	}
+	md.Audit(kind, id, beforeStatus, ev.Beatmaps)
+	md.InvalidateCache("beatmaps")
+
+	b, _ := json.Marshal(ev)
+	if err := md.R.Publish(beatmapStatusChannel, string(b)).Err(); err != nil {
+		md.Err(err)
+	}
+
+	rows, err = md.DB.QueryContext(md.Context, baseBeatmapSelect+"WHERE "+where, id)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+	r := beatmapSetResponse{Beatmaps: make([]beatmap, 0, len(after))}
+	for rows.Next() {
+		var b beatmap
+		err = rows.Scan(
+			&b.BeatmapID, &b.BeatmapsetID, &b.BeatmapMD5,
+			&b.SongName, &b.AR, &b.OD, &b.MaxCombo,
+			&b.HitLength, &b.Ranked, &b.RankedStatusFrozen,
+			&b.LatestUpdate,
+		)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		r.Beatmaps = append(r.Beatmaps, b)
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
+
+func statusOf(b beatmap) beatmapStatus {
+	return beatmapStatus{
+		BeatmapID:  b.BeatmapID,
+		BeatmapMD5: b.BeatmapMD5,
+		Ranked:     b.Ranked,
+		Frozen:     b.RankedStatusFrozen == 1,
+	}
+}
+
+type beatmapStatusChange struct {
+	ID           int                  `json:"id"`
+	BeatmapID    int                  `json:"beatmap_id"`
+	BeatmapsetID int                  `json:"beatmapset_id"`
+	SongName     string               `json:"song_name"`
+	UserID       int                  `json:"user_id"`
+	Username     string               `json:"username"`
+	Action       string               `json:"action"`
+	OldStatus    int                  `json:"old_status"`
+	NewStatus    int                  `json:"new_status"`
+	Frozen       bool                 `json:"frozen"`
+	Reason       string               `json:"reason"`
+	Time         common.UnixTimestamp `json:"time"`
+}
+
+type beatmapStatusChangesResponse struct {
+	common.ResponseBase
+	Changes []beatmapStatusChange `json:"changes"`
+}
+
+// BeatmapStatusChangesGET lists the most recent changes of the ranked status
+// of beatmaps.
+func BeatmapStatusChangesGET(md common.MethodData) common.CodeMessager {
+	wc := common.
+		Where("h.beatmap_id = ?", md.Query("b")).
+		Where("h.beatmapset_id = ?", md.Query("s")).
+		Where("h.action = ?", md.Query("action"), "rank", "love", "qualify", "unrank", "freeze", "unfreeze")
+	rows, err := md.DB.QueryContext(md.Context, `SELECT h.id, h.beatmap_id, h.beatmapset_id, COALESCE(beatmaps.song_name, ''),
+		h.action, h.old_status, h.new_status, h.frozen, h.reason, h.created_at,
+		users.id, users.username
+		FROM beatmap_status_history h
+		INNER JOIN users ON users.id = h.user_id
+		LEFT JOIN beatmaps ON beatmaps.beatmap_id = h.beatmap_id `+
+		wc.ClauseSafe()+" ORDER BY h.id DESC "+common.Paginate(md.Query("p"), md.Query("l"), 100), wc.Params...)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+
+	r := beatmapStatusChangesResponse{Changes: []beatmapStatusChange{}}
+	for rows.Next() {
+		var c beatmapStatusChange
+		err := rows.Scan(&c.ID, &c.BeatmapID, &c.BeatmapsetID, &c.SongName,
+			&c.Action, &c.OldStatus, &c.NewStatus, &c.Frozen, &c.Reason, &c.Time,
+			&c.UserID, &c.Username)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		r.Changes = append(r.Changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Code = 200
+	return r
+}
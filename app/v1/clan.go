@@ -37,6 +37,79 @@ type SingleClanResponse struct {
 	Clan `json:"clan"`
 }
 
+type multiClanResponse struct {
+	common.ResponseBase
+	Clans []Clan `json:"clans"`
+}
+
+type clanLbData struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	ChosenMode modeData `json:"chosen_mode"`
+}
+
+type clanLeaderboard struct {
+	Page  int          `json:"page"`
+	Clans []clanLbData `json:"clans"`
+}
+
+type clanLeaderboardResponse struct {
+	common.ResponseBase
+	clanLeaderboard
+}
+
+type clanModeStats struct {
+	Clan
+	ChosenMode modeData `json:"chosen_mode"`
+}
+
+type clanStatsResponse struct {
+	common.ResponseBase
+	Clan clanModeStats `json:"clan"`
+}
+
+type clanInviteResponse struct {
+	common.ResponseBase
+	Clan Clan `json:"clan"`
+}
+
+type clanInviteCodeResponse struct {
+	common.ResponseBase
+	Invite string `json:"invite"`
+}
+
+type clanMembersData struct {
+	Clan
+	Members []userData `json:"members"`
+}
+
+type clanMembersResponse struct {
+	common.ResponseBase
+	Clan clanMembersData `json:"clan"`
+}
+
+type clanJoinData struct {
+	ID     int    `json:"id,omitempty" validate:"min=1"`
+	Invite string `json:"invite,omitempty"`
+}
+
+type clanSettingsData struct {
+	Tag         string `json:"tag,omitempty" validate:"required"`
+	Name        string `json:"name,omitempty" validate:"required"`
+	Description string `json:"desc,omitempty"`
+	// Icon        string `json:"icon,omitempty"`
+	Background string `json:"bg,omitempty"`
+	Status     int    `json:"status" validate:"oneof=0 1 2 3"`
+}
+
+type clanTransferOwnershipData struct {
+	NewOwnerUserID int `json:"new_owner_user_id" validate:"required,min=1"`
+}
+
+type clanKickData struct {
+	User int `json:"user" validate:"required,min=1"`
+}
+
 // clansGET retrieves all the clans on this ripple instance.
 func ClansGET(md common.MethodData) common.CodeMessager {
 	if md.Query("id") != "" {
@@ -50,12 +123,8 @@ func ClansGET(md common.MethodData) common.CodeMessager {
 		r.ResponseBase.Code = 200
 		return r
 	}
-	type Res struct {
-		common.ResponseBase
-		Clans []Clan `json:"clans"`
-	}
-	r := Res{}
-	rows, err := md.DB.Query("SELECT id, name, description, tag, icon, owner, status FROM clans " + common.Paginate(md.Query("p"), md.Query("l"), 50))
+	r := multiClanResponse{}
+	rows, err := md.DB.QueryContext(md.Context, "SELECT id, name, description, tag, icon, owner, status FROM clans "+common.Paginate(md.Query("p"), md.Query("l"), 50))
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -80,18 +149,9 @@ func ClanLeaderboardGET(md common.MethodData) common.CodeMessager {
 	if err != nil || page == 0 {
 		page = 1
 	}
-	type clanLbData struct {
-		ID         int      `json:"id"`
-		Name       string   `json:"name"`
-		ChosenMode modeData `json:"chosen_mode"`
-	}
-	type clanLeaderboard struct {
-		Page  int          `json:"page"`
-		Clans []clanLbData `json:"clans"`
-	}
 	relax := common.Int(md.Query("rx"))
 	if relax < 0 || relax > 2 {
-		return common.SimpleResponse(400, "invalid relax value")
+		return common.ErrInvalidRelax
 	}
 
 	cl := clanLeaderboard{Page: page}
@@ -108,7 +168,7 @@ func ClanLeaderboardGET(md common.MethodData) common.CodeMessager {
 		ORDER BY pp DESC
 		LIMIT ?, 50`
 
-	rows, err := md.DB.Query(q, mode+(relax*4), (page-1)*50)
+	rows, err := md.DB.QueryContext(md.Context, q, mode+(relax*4), (page-1)*50)
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -127,11 +187,7 @@ func ClanLeaderboardGET(md common.MethodData) common.CodeMessager {
 		clan.ChosenMode.GlobalLeaderboardRank = &rank
 		cl.Clans = append(cl.Clans, clan)
 	}
-	type Res struct {
-		common.ResponseBase
-		clanLeaderboard
-	}
-	r := Res{clanLeaderboard: cl}
+	r := clanLeaderboardResponse{clanLeaderboard: cl}
 	r.ResponseBase.Code = 200
 	return r
 }
@@ -142,28 +198,19 @@ func ClanStatsGET(md common.MethodData) common.CodeMessager {
 	}
 	id, err := strconv.Atoi(md.Query("id"))
 	if err != nil {
-		return common.SimpleResponse(400, "please pass a valid ID")
+		return common.ErrInvalidID
 	}
 	mode := common.Int(md.Query("m"))
 
-	type clanModeStats struct {
-		Clan
-		ChosenMode modeData `json:"chosen_mode"`
-	}
-
 	relax := common.Int(md.Query("rx"))
 	if relax < 0 || relax > 2 {
-		return common.SimpleResponse(400, "invalid relax value")
+		return common.ErrInvalidRelax
 	}
 
-	type Res struct {
-		common.ResponseBase
-		Clan clanModeStats `json:"clan"`
-	}
 	cms := clanModeStats{}
 	cms.Clan, err = getClan(id, md)
 	if err != nil {
-		return Res{Clan: cms}
+		return clanStatsResponse{Clan: cms}
 	}
 	q := `SELECT SUM(pp) / (COUNT(users.clan_id) + 1) AS pp, SUM(ranked_score),
 		SUM(total_score), SUM(playcount), SUM(replays_watched),
@@ -171,7 +218,7 @@ func ClanStatsGET(md common.MethodData) common.CodeMessager {
 		FROM user_stats INNER JOIN users ON users.id = user_stats.user_id
 		WHERE users.clan_id = ? AND user_stats.mode = ? AND users.privileges & 1`
 	var pp float64
-	err = md.DB.QueryRow(q, id, mode+(relax*4)).Scan(
+	err = md.DB.QueryRowContext(md.Context, q, id, mode+(relax*4)).Scan(
 		&pp, &cms.ChosenMode.RankedScore,
 		&cms.ChosenMode.TotalScore, &cms.ChosenMode.PlayCount, &cms.ChosenMode.ReplaysWatched,
 		&cms.ChosenMode.Accuracy, &cms.ChosenMode.TotalHits,
@@ -183,7 +230,7 @@ func ClanStatsGET(md common.MethodData) common.CodeMessager {
 
 	cms.ChosenMode.PP = int(pp)
 	var rank int
-	err = md.DB.QueryRow(`
+	err = md.DB.QueryRowContext(md.Context, `
 		SELECT COUNT(pp)
 		FROM (
 			SELECT SUM(pp) / (COUNT(clan_id) + 1) AS pp
@@ -202,7 +249,7 @@ func ClanStatsGET(md common.MethodData) common.CodeMessager {
 		return Err500
 	}
 	cms.ChosenMode.GlobalLeaderboardRank = &rank
-	r := Res{Clan: cms}
+	r := clanStatsResponse{Clan: cms}
 	r.ResponseBase.Code = 200
 	return r
 }
@@ -212,65 +259,56 @@ func ResolveInviteGET(md common.MethodData) common.CodeMessager {
 	if s == "" {
 		return ErrMissingField("invite")
 	}
-	type Res struct {
-		common.ResponseBase
-		Clan Clan `json:"clan"`
-	}
-
 	clan := Clan{}
-	err := md.DB.QueryRow("SELECT id, name, description, tag, icon, owner FROM clans WHERE invite = ?", s).Scan(&clan.ID, &clan.Name, &clan.Description, &clan.Tag, &clan.Icon, &clan.Owner)
+	err := md.DB.QueryRowContext(md.Context, "SELECT id, name, description, tag, icon, owner FROM clans WHERE invite = ?", s).Scan(&clan.ID, &clan.Name, &clan.Description, &clan.Tag, &clan.Icon, &clan.Owner)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return common.SimpleResponse(404, "No clan with given invite found.")
+			return common.ErrInviteNotFound
 		} else {
 			md.Err(err)
 			return Err500
 		}
 	}
-	r := Res{Clan: clan}
+	r := clanInviteResponse{Clan: clan}
 	r.ResponseBase.Code = 200
 	return r
 }
 
 func ClanJoinPOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
 	var cID int
-	err := md.DB.QueryRow("SELECT clan_id FROM users WHERE id = ?", md.ID()).Scan(&cID)
+	err := md.DB.QueryRowContext(md.Context, "SELECT clan_id FROM users WHERE id = ?", md.ID()).Scan(&cID)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
 	if cID != 0 {
-		return common.SimpleResponse(403, "already joined a clan")
+		return common.ErrAlreadyInClan
 	}
 
-	var u struct {
-		ID     int    `json:"id,omitempty"`
-		Invite string `json:"invite,omitempty"`
-	}
+	var u clanJoinData
 
-	md.Unmarshal(&u)
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
+	}
 	u.Invite = strings.TrimSpace(u.Invite)
 	if u.ID == 0 && u.Invite == "" {
-		return common.SimpleResponse(400, "id or invite required")
+		return common.ErrClanIDOrInvite
 	}
 
-	r := struct {
-		common.ResponseBase
-		Clan Clan `json:"clan"`
-	}{}
+	r := clanInviteResponse{}
 	var hasInvite bool
 
 	if u.Invite != "" {
-		row := md.DB.QueryRow("SELECT id FROM clans where invite = ?", u.Invite)
+		row := md.DB.QueryRowContext(md.Context, "SELECT id FROM clans where invite = ?", u.Invite)
 		err = row.Scan(&u.ID)
 
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return common.SimpleResponse(404, "invalid invite provided")
+				return common.ErrInviteNotFound
 			}
 			md.Err(err)
 			return Err500
@@ -280,50 +318,55 @@ func ClanJoinPOST(md common.MethodData) common.CodeMessager {
 	}
 
 	if u.ID <= 0 {
-		return common.SimpleResponse(400, "invalid id parameter")
+		return common.ErrInvalidID
 	}
 
 	c, err := getClan(u.ID, md)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return common.SimpleResponse(404, "clan not found")
+			return common.ErrClanNotFound
 		}
 		md.Err(err)
 		return Err500
 	}
 
 	if c.Status == 0 || (c.Status == 2 && !hasInvite) {
-		return common.SimpleResponse(403, "closed")
+		return common.ErrClanClosed
 	}
 
 	var count int
-	err = md.DB.QueryRow("SELECT COUNT(id) FROM users WHERE clan_id = ?", c.ID).Scan(&count)
+	err = md.DB.QueryRowContext(md.Context, "SELECT COUNT(id) FROM users WHERE clan_id = ?", c.ID).Scan(&count)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
 
 	if count >= clanMemberLimit {
-		return common.SimpleResponse(403, "clan is full")
+		return common.ErrClanFull
 	}
 
-	tx, err := md.DB.Begin()
+	tx, err := md.DB.BeginTx(md.Context, nil)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
 
 	if c.Status == 3 {
-		_, err = tx.Exec("INSERT INTO clan_requests VALUES (?, ?, DEFAULT) ON DUPLICATE KEY UPDATE time = NOW()", c.ID, md.ID())
+		_, err = tx.ExecContext(md.Context, "INSERT INTO clan_requests VALUES (?, ?, DEFAULT) ON DUPLICATE KEY UPDATE time = NOW()", c.ID, md.ID())
 		if err != nil {
 			tx.Rollback()
 			md.Err(err)
 			return Err500
 		}
+		if err := tx.Commit(); err != nil {
+			md.Err(err)
+			return Err500
+		}
+		md.Audit("clan", c.ID, nil, map[string]interface{}{"join_request": md.ID()})
 
 		return common.SimpleResponse(200, "join request sent")
 	}
-	_, err = tx.Exec("UPDATE users SET clan_id = ? WHERE id = ?", c.ID, md.ID())
+	_, err = tx.ExecContext(md.Context, "UPDATE users SET clan_id = ? WHERE id = ?", c.ID, md.ID())
 	if err != nil {
 		tx.Rollback()
 		md.Err(err)
@@ -331,28 +374,30 @@ func ClanJoinPOST(md common.MethodData) common.CodeMessager {
 	}
 
 	tx.Commit()
+	md.Audit("user", md.ID(), map[string]interface{}{"clan_id": 0}, map[string]interface{}{"clan_id": c.ID})
 
 	r.Clan = c
 	r.Code = 200
 
 	md.R.Publish("api:update_user_clan", strconv.Itoa(md.ID()))
+	md.InvalidateCache("clans", "users")
 
 	return r
 }
 
 func ClanLeavePOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
 	clanId := 0
-	row := md.DB.QueryRowx("SELECT clan_id FROM users WHERE id = ?", md.ID())
+	row := md.DB.QueryRowxContext(md.Context, "SELECT clan_id FROM users WHERE id = ?", md.ID())
 	if err := row.Scan(&clanId); err != nil {
 		md.Err(err)
 		return Err500
 	}
 	if clanId == 0 {
-		return common.SimpleResponse(403, "You haven't joined any clan...")
+		return common.ErrNotInClan
 	}
 	clan, err := getClan(clanId, md)
 	if err != nil {
@@ -360,7 +405,7 @@ func ClanLeavePOST(md common.MethodData) common.CodeMessager {
 		return Err500
 	}
 
-	tx, err := md.DB.Begin()
+	tx, err := md.DB.BeginTx(md.Context, nil)
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -368,7 +413,7 @@ func ClanLeavePOST(md common.MethodData) common.CodeMessager {
 
 	disbanded := false
 	if clan.Owner == md.ID() {
-		_, err = tx.Exec("UPDATE users SET clan_id = 0 WHERE clan_id = ?", clan.ID)
+		_, err = tx.ExecContext(md.Context, "UPDATE users SET clan_id = 0 WHERE clan_id = ?", clan.ID)
 		if err != nil {
 			tx.Rollback()
 			md.Err(err)
@@ -383,7 +428,7 @@ func ClanLeavePOST(md common.MethodData) common.CodeMessager {
 		}
 		disbanded = true
 	} else {
-		_, err := tx.Exec("UPDATE users SET clan_id = 0 WHERE id = ?", md.ID())
+		_, err := tx.ExecContext(md.Context, "UPDATE users SET clan_id = 0 WHERE id = ?", md.ID())
 		if err != nil {
 			tx.Rollback()
 			md.Err(err)
@@ -392,8 +437,13 @@ func ClanLeavePOST(md common.MethodData) common.CodeMessager {
 	}
 
 	tx.Commit()
+	md.Audit("user", md.ID(), map[string]interface{}{"clan_id": clan.ID}, map[string]interface{}{"clan_id": 0})
+	if disbanded {
+		md.Audit("clan", clan.ID, map[string]interface{}{"name": clan.Name, "tag": clan.Tag}, nil)
+	}
 
 	md.R.Publish("api:update_user_clan", strconv.Itoa(md.ID()))
+	md.InvalidateCache("clans", "users")
 
 	message := "success"
 	if disbanded {
@@ -403,35 +453,30 @@ func ClanLeavePOST(md common.MethodData) common.CodeMessager {
 }
 
 func disbandClan(clanId int, md common.MethodData) error {
-	_, err := md.DB.Exec("DELETE FROM clans WHERE id = ?", clanId)
+	_, err := md.DB.ExecContext(md.Context, "DELETE FROM clans WHERE id = ?", clanId)
 	return err
 }
 
 func ClanSettingsPOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
 	var c Clan
-	err := md.DB.QueryRow("SELECT id, tag, description, icon FROM clans WHERE owner = ?", md.ID()).Scan(&c.ID, &c.Tag, &c.Description, &c.Icon)
+	err := md.DB.QueryRowContext(md.Context, "SELECT id, tag, description, icon FROM clans WHERE owner = ?", md.ID()).Scan(&c.ID, &c.Tag, &c.Description, &c.Icon)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return common.SimpleResponse(401, "not authorised")
+			return common.ErrNotClanOwner
 		}
 		md.Err(err)
 		return Err500
 	}
 
-	u := struct {
-		Tag         string `json:"tag,omitempty"`
-		Name        string `json:"name,omitempty"`
-		Description string `json:"desc,omitempty"`
-		// Icon        string `json:"icon,omitempty"`
-		Background string `json:"bg,omitempty"`
-		Status     int    `json:"status"`
-	}{}
+	u := clanSettingsData{}
 
-	md.Unmarshal(&u)
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
+	}
 	u.Tag = strings.TrimSpace(u.Tag)
 	u.Name = strings.TrimSpace(u.Name)
 
@@ -439,30 +484,43 @@ func ClanSettingsPOST(md common.MethodData) common.CodeMessager {
 	/* if u.Icon != "" {
 		match, _ := regexp.MatchString(`^https?://(?:www\.)?.+\..+/.+\.(?:jpeg|jpg|png)/?$`, u.Icon)
 		if !match {
-			return common.SimpleResponse(200, "invalid icon url")
+			return common.ErrInvalidClanIconURL
 		}
 	} */
 	if !clanNameRegex.MatchString(u.Name) {
-		return common.SimpleResponse(400, "Your clans name must contain alphanumerical characters, spaces, or any of _[]-.")
-	} else if md.DB.QueryRow("SELECT 1 FROM clans WHERE name = ? AND id != ?", u.Name, c.ID).Scan(new(int)) != sql.ErrNoRows {
-		return common.SimpleResponse(403, "Another clan has already taken this name")
+		return common.ErrInvalidClanName
+	} else if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM clans WHERE name = ? AND id != ?", u.Name, c.ID).Scan(new(int)) != sql.ErrNoRows {
+		return common.ErrClanNameTaken
 	}
 
 	tagRunes := []rune(u.Tag)
 	if len(tagRunes) > 8 || len(tagRunes) < 1 {
-		return common.SimpleResponse(400, "The given tag is too short or too long")
-	} else if md.DB.QueryRow("SELECT 1 FROM clans WHERE tag = ? AND id != ?", u.Tag, c.ID).Scan(new(int)) != sql.ErrNoRows {
-		return common.SimpleResponse(403, "Another Clan has already taken this Tag")
+		return common.ErrInvalidClanTag
+	} else if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM clans WHERE tag = ? AND id != ?", u.Tag, c.ID).Scan(new(int)) != sql.ErrNoRows {
+		return common.ErrClanTagTaken
 	}
 
-	_, err = md.DB.Exec("UPDATE clans SET name = ?, tag = ?, description = ?, background = ?, status = ? WHERE id = ?", u.Name, u.Tag, u.Description, u.Background, u.Status, c.ID)
+	before, err := auditColumns(md, "clans", c.ID, []string{"name", "tag", "description", "background", "status"})
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	_, err = md.DB.ExecContext(md.Context, "UPDATE clans SET name = ?, tag = ?, description = ?, background = ?, status = ? WHERE id = ?", u.Name, u.Tag, u.Description, u.Background, u.Status, c.ID)
 
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("clan", c.ID, before, map[string]interface{}{
+		"name":        u.Name,
+		"tag":         u.Tag,
+		"description": u.Description,
+		"background":  u.Background,
+		"status":      u.Status,
+	})
 
 	md.R.Publish("api:update_clan", strconv.Itoa(c.ID))
+	md.InvalidateCache("clans")
 
 	return SingleClanResponse{
 		common.ResponseBase{
@@ -474,97 +532,95 @@ func ClanSettingsPOST(md common.MethodData) common.CodeMessager {
 
 func ClanGenerateInvitePOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
 	var id int
-	err := md.DB.QueryRow("SELECT id FROM clans WHERE owner = ?", md.ID()).Scan(&id)
+	err := md.DB.QueryRowContext(md.Context, "SELECT id FROM clans WHERE owner = ?", md.ID()).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return common.SimpleResponse(401, "not authorised")
+			return common.ErrNotClanOwner
 		}
 		md.Err(err)
 		return Err500
 	}
 
 	invite := rs.String(8)
-	_, err = md.DB.Exec("UPDATE clans SET invite = ? WHERE id = ?", invite, id)
+	_, err = md.DB.ExecContext(md.Context, "UPDATE clans SET invite = ? WHERE id = ?", invite, id)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	// the invite itself is left out, as anyone with it can join the clan.
+	md.Audit("clan", id, nil, nil)
 
-	r := struct {
-		common.ResponseBase
-		Invite string `json:"invite"`
-	}{Invite: invite}
+	r := clanInviteCodeResponse{Invite: invite}
 	r.Code = 200
 	return r
 }
 
 func ClanTransferOwnershipPOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
 	var clan_id int
-	if md.DB.QueryRow("SELECT id FROM clans WHERE owner = ?", md.ID()).Scan(&clan_id) == sql.ErrNoRows {
-		return common.SimpleResponse(401, "not authorised")
+	if md.DB.QueryRowContext(md.Context, "SELECT id FROM clans WHERE owner = ?", md.ID()).Scan(&clan_id) == sql.ErrNoRows {
+		return common.ErrNotClanOwner
 	}
 
-	u := struct {
-		NewOwnerUserID int `json:"new_owner_user_id"`
-	}{}
+	u := clanTransferOwnershipData{}
 
-	md.Unmarshal(&u)
-	if u.NewOwnerUserID <= 0 {
-		return common.SimpleResponse(400, "bad user id")
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
 	}
 
-	if md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND clan_id = ?", u.NewOwnerUserID, clan_id).Scan(new(int)) == sql.ErrNoRows {
-		return common.SimpleResponse(403, "user not in clan")
+	if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM users WHERE id = ? AND clan_id = ?", u.NewOwnerUserID, clan_id).Scan(new(int)) == sql.ErrNoRows {
+		return common.ErrUserNotInClan
 	}
 
-	_, err := md.DB.Exec("UPDATE clans SET owner = ? WHERE id = ?", u.NewOwnerUserID, clan_id)
+	_, err := md.DB.ExecContext(md.Context, "UPDATE clans SET owner = ? WHERE id = ?", u.NewOwnerUserID, clan_id)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("clan", clan_id, map[string]interface{}{"owner": md.ID()}, map[string]interface{}{"owner": u.NewOwnerUserID})
+	md.InvalidateCache("clans")
 
 	return common.SimpleResponse(200, "success")
 }
 
 func ClanKickPOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
 	var clan int
-	if md.DB.QueryRow("SELECT id FROM clans WHERE owner = ?", md.ID()).Scan(&clan) == sql.ErrNoRows {
-		return common.SimpleResponse(401, "not authorised")
+	if md.DB.QueryRowContext(md.Context, "SELECT id FROM clans WHERE owner = ?", md.ID()).Scan(&clan) == sql.ErrNoRows {
+		return common.ErrNotClanOwner
 	}
 
-	u := struct {
-		User int `json:"user"`
-	}{}
+	u := clanKickData{}
 
-	md.Unmarshal(&u)
-
-	if u.User == 0 {
-		return common.SimpleResponse(400, "bad user id")
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
 	}
 
-	/*if md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND clan_id = ?", md.ID()).Scan(new(int)) == sql.ErrNoRows {
-		return common.SimpleResponse(401, "not authorised")
+	/*if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM users WHERE id = ? AND clan_id = ?", md.ID()).Scan(new(int)) == sql.ErrNoRows {
+		return common.ErrNotAuthenticated
 	}*/
 
-	_, err := md.DB.Exec("UPDATE users SET clan_id = 0 WHERE id = ? AND clan_id = ?", u.User, clan)
+	res, err := md.DB.ExecContext(md.Context, "UPDATE users SET clan_id = 0 WHERE id = ? AND clan_id = ?", u.User, clan)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		md.Audit("user", u.User, map[string]interface{}{"clan_id": clan}, map[string]interface{}{"clan_id": 0})
+	}
 
 	md.R.Publish("api:update_user_clan", strconv.Itoa(md.ID()))
+	md.InvalidateCache("clans", "users")
 
 	return common.SimpleResponse(200, "success")
 }
@@ -575,29 +631,22 @@ func ClanMembersGET(md common.MethodData) common.CodeMessager {
 	if i == 0 {
 		return ErrMissingField("id")
 	}
-	type clanMembersData struct {
-		Clan
-		Members []userData `json:"members"`
-	}
 	cmd := clanMembersData{}
 	var err error
 	cmd.Clan, err = getClan(i, md)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return common.SimpleResponse(404, "clan not found")
+			return common.ErrClanNotFound
 		}
 		md.Err(err)
 		return Err500
 	}
 
 	cmd.Members = make([]userData, 0)
-	rows, err := md.DB.Query(userFields+" WHERE users.privileges & 3 AND clan_id = ?", i)
+	rows, err := md.DB.QueryContext(md.Context, userFields+" WHERE users.privileges & 3 AND clan_id = ?", i)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return struct {
-				common.ResponseBase
-				Clan clanMembersData `json:"clan"`
-			}{Clan: cmd}
+			return clanMembersResponse{Clan: cmd}
 		}
 		md.Err(err)
 		return Err500
@@ -645,10 +694,7 @@ func ClanMembersGET(md common.MethodData) common.CodeMessager {
 
 		cmd.Members = append(cmd.Members, a)
 	}
-	res := struct {
-		common.ResponseBase
-		Clan clanMembersData `json:"clan"`
-	}{Clan: cmd}
+	res := clanMembersResponse{Clan: cmd}
 	res.ResponseBase.Code = 200
 	return res
 }
@@ -658,7 +704,7 @@ func getClan(id int, md common.MethodData) (Clan, error) {
 	if id == 0 {
 		return c, nil // lol?
 	}
-	err := md.DB.QueryRow("SELECT id, name, description, tag, icon, owner, status FROM clans WHERE id = ?", id).Scan(&c.ID, &c.Name, &c.Description, &c.Tag, &c.Icon, &c.Owner, &c.Status)
+	err := md.DB.QueryRowContext(md.Context, "SELECT id, name, description, tag, icon, owner, status FROM clans WHERE id = ?", id).Scan(&c.ID, &c.Name, &c.Description, &c.Tag, &c.Icon, &c.Owner, &c.Status)
 
 	return c, err
 }
@@ -4,6 +4,18 @@ import (
 	"github.com/osuAkatsuki/akatsuki-api/common"
 )
 
+type clanFirstPlaceEntry struct {
+	Count  int    `json:"count"`
+	ClanID int    `json:"clan"`
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+}
+
+type clansFirstPlaceResponse struct {
+	common.ResponseBase
+	Clans []clanFirstPlaceEntry `json:"clans"`
+}
+
 func ClansFirstPlaceRankingGET(md common.MethodData) common.CodeMessager {
 	mode := common.Int(md.Query("m"))
 	if mode > 3 {
@@ -11,7 +23,7 @@ func ClansFirstPlaceRankingGET(md common.MethodData) common.CodeMessager {
 	}
 
 	rx := common.Int(md.Query("rx"))
-	rows, err := md.DB.Query(`
+	rows, err := md.DB.QueryContext(md.Context, `
 		SELECT COUNT(*) AS count, clans.id, clans.tag, clans.name
 		FROM scores_first
 		JOIN users ON users.id = userid
@@ -29,20 +41,10 @@ func ClansFirstPlaceRankingGET(md common.MethodData) common.CodeMessager {
 		return Err500
 	}
 	defer rows.Close()
-	type LbFirstEntry struct {
-		Count  int    `json:"count"`
-		ClanID int    `json:"clan"`
-		Name   string `json:"name"`
-		Tag    string `json:"tag"`
-	}
-
-	r := struct {
-		common.ResponseBase
-		Clans []LbFirstEntry `json:"clans"`
-	}{}
+	r := clansFirstPlaceResponse{}
 
 	for rows.Next() {
-		e := LbFirstEntry{}
+		e := clanFirstPlaceEntry{}
 		err = rows.Scan(&e.Count, &e.ClanID, &e.Tag, &e.Name)
 		if err != nil {
 			md.Err(err)
@@ -9,20 +9,31 @@ import (
 	"github.com/osuAkatsuki/akatsuki-api/common"
 )
 
+// auditConnection summarises an account linked to a user in the audit log.
+type auditConnection struct {
+	Provider string `json:"provider"`
+	// AccountID is the ID of the account on the provider, nil if none is
+	// linked.
+	AccountID interface{} `json:"account_id"`
+}
+
 func DiscordUnlinkPOST(md common.MethodData) common.CodeMessager {
-	err := md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND discord_account_id IS NOT NULL", md.ID()).Scan(new(int))
+	var accountID string
+	err := md.DB.QueryRowContext(md.Context, "SELECT discord_account_id FROM users WHERE id = ? AND discord_account_id IS NOT NULL", md.ID()).Scan(&accountID)
 	switch {
 	case err == sql.ErrNoRows:
-		var r common.ResponseBase
-		r.Code = 400
-		r.Message = "You do not have a Discord account linked!"
-		return r
+		return common.ErrConnectionNotLinked.WithMessage("You do not have a Discord account linked!")
 	case err != nil:
 		md.Err(err)
 		return Err500
 	}
 
-	md.DB.Exec("UPDATE users SET discord_account_id = NULL WHERE id = ?", md.ID())
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET discord_account_id = NULL WHERE id = ?", md.ID())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("user", md.ID(), auditConnection{"discord", accountID}, auditConnection{"discord", nil})
 
 	return common.SimpleResponse(200, "Discord unlinked successfully")
 }
@@ -37,12 +48,9 @@ func DiscordCallbackGET(md common.MethodData) common.CodeMessager {
 		return r
 	}
 
-	if md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND discord_account_id IS NOT NULL", md.ID()).
+	if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM users WHERE id = ? AND discord_account_id IS NOT NULL", md.ID()).
 		Scan(new(int)) != sql.ErrNoRows {
-		var r common.ResponseBase
-		r.Code = 403
-		r.Message = "You already have a Discord account linked!"
-		return r
+		return common.ErrConnectionAlreadyLinked.WithMessage("You already have a Discord account linked!")
 	}
 
 	settings := common.GetSettings()
@@ -91,30 +99,34 @@ func DiscordCallbackGET(md common.MethodData) common.CodeMessager {
 		return Err500
 	}
 
-	md.DB.Exec("UPDATE users SET discord_account_id = ? WHERE id = ?", discordUser.ID, md.ID())
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET discord_account_id = ? WHERE id = ?", discordUser.ID, md.ID())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("user", md.ID(), auditConnection{"discord", nil}, auditConnection{"discord", discordUser.ID})
 
 	md.Ctx.Redirect("https://akatsuki.gg/settings/connections", 302)
 	return common.SimpleResponse(302, "")
 }
 
 func TwitchUnlinkPOST(md common.MethodData) common.CodeMessager {
-	err := md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND twitch_account_id IS NOT NULL", md.ID()).Scan(new(int))
+	var accountID string
+	err := md.DB.QueryRowContext(md.Context, "SELECT twitch_account_id FROM users WHERE id = ? AND twitch_account_id IS NOT NULL", md.ID()).Scan(&accountID)
 	switch {
 	case err == sql.ErrNoRows:
-		var r common.ResponseBase
-		r.Code = 400
-		r.Message = "You do not have a Twitch account linked!"
-		return r
+		return common.ErrConnectionNotLinked.WithMessage("You do not have a Twitch account linked!")
 	case err != nil:
 		md.Err(err)
 		return Err500
 	}
 
-	_, err = md.DB.Exec("UPDATE users SET twitch_account_id = NULL, twitch_username = NULL WHERE id = ?", md.ID())
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET twitch_account_id = NULL, twitch_username = NULL WHERE id = ?", md.ID())
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("user", md.ID(), auditConnection{"twitch", accountID}, auditConnection{"twitch", nil})
 
 	return common.SimpleResponse(200, "Twitch unlinked successfully")
 }
@@ -129,17 +141,14 @@ func TwitchCallbackGET(md common.MethodData) common.CodeMessager {
 		return r
 	}
 
-	if md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND twitch_account_id IS NOT NULL", md.ID()).
+	if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM users WHERE id = ? AND twitch_account_id IS NOT NULL", md.ID()).
 		Scan(new(int)) != sql.ErrNoRows {
-		var r common.ResponseBase
-		r.Code = 403
-		r.Message = "You already have a Twitch account linked!"
-		return r
+		return common.ErrConnectionAlreadyLinked.WithMessage("You already have a Twitch account linked!")
 	}
 
 	settings := common.GetSettings()
 	if settings.TWITCH_CLIENT_ID == "" || settings.TWITCH_CLIENT_SECRET == "" || settings.TWITCH_REDIRECT_URI == "" {
-		return common.SimpleResponse(503, "Twitch account linking is not configured.")
+		return common.ErrNotConfigured.WithMessage("Twitch account linking is not configured.")
 	}
 
 	client := resty.New()
@@ -160,7 +169,7 @@ func TwitchCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if resp.IsError() {
-		return common.SimpleResponse(resp.StatusCode(), "Failed to exchange Twitch OAuth code.")
+		return common.ErrConnectionProviderFailed.WithMessage("Failed to exchange Twitch OAuth code.")
 	}
 
 	var tokenResp struct {
@@ -173,7 +182,7 @@ func TwitchCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if tokenResp.AccessToken == "" {
-		return common.SimpleResponse(502, "Twitch OAuth response did not include an access token.")
+		return common.ErrConnectionProviderInvalid.WithMessage("Twitch OAuth response did not include an access token.")
 	}
 
 	userResp, err := client.R().
@@ -187,7 +196,7 @@ func TwitchCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if userResp.IsError() {
-		return common.SimpleResponse(userResp.StatusCode(), "Failed to fetch Twitch user.")
+		return common.ErrConnectionProviderFailed.WithMessage("Failed to fetch Twitch user.")
 	}
 
 	var twitchUserResp struct {
@@ -203,11 +212,11 @@ func TwitchCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if len(twitchUserResp.Data) == 0 {
-		return common.SimpleResponse(502, "Twitch did not return a user for this OAuth token.")
+		return common.ErrConnectionProviderInvalid.WithMessage("Twitch did not return a user for this OAuth token.")
 	}
 
 	twitchUser := twitchUserResp.Data[0]
-	_, err = md.DB.Exec(
+	_, err = md.DB.ExecContext(md.Context,
 		"UPDATE users SET twitch_account_id = ?, twitch_username = ? WHERE id = ?",
 		twitchUser.ID,
 		twitchUser.Login,
@@ -217,29 +226,29 @@ func TwitchCallbackGET(md common.MethodData) common.CodeMessager {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("user", md.ID(), auditConnection{"twitch", nil}, auditConnection{"twitch", twitchUser.ID})
 
 	md.Ctx.Redirect("https://akatsuki.gg/settings/connections", 302)
 	return common.SimpleResponse(302, "")
 }
 
 func OfficialOsuUnlinkPOST(md common.MethodData) common.CodeMessager {
-	err := md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND official_osu_user_id IS NOT NULL", md.ID()).Scan(new(int))
+	var accountID int
+	err := md.DB.QueryRowContext(md.Context, "SELECT official_osu_user_id FROM users WHERE id = ? AND official_osu_user_id IS NOT NULL", md.ID()).Scan(&accountID)
 	switch {
 	case err == sql.ErrNoRows:
-		var r common.ResponseBase
-		r.Code = 400
-		r.Message = "You do not have an official osu! account linked!"
-		return r
+		return common.ErrConnectionNotLinked.WithMessage("You do not have an official osu! account linked!")
 	case err != nil:
 		md.Err(err)
 		return Err500
 	}
 
-	_, err = md.DB.Exec("UPDATE users SET official_osu_user_id = NULL, official_osu_username = NULL WHERE id = ?", md.ID())
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET official_osu_user_id = NULL, official_osu_username = NULL WHERE id = ?", md.ID())
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("user", md.ID(), auditConnection{"osu", accountID}, auditConnection{"osu", nil})
 
 	return common.SimpleResponse(200, "Official osu! account unlinked successfully")
 }
@@ -254,12 +263,9 @@ func OfficialOsuCallbackGET(md common.MethodData) common.CodeMessager {
 		return r
 	}
 
-	if md.DB.QueryRow("SELECT 1 FROM users WHERE id = ? AND official_osu_user_id IS NOT NULL", md.ID()).
+	if md.DB.QueryRowContext(md.Context, "SELECT 1 FROM users WHERE id = ? AND official_osu_user_id IS NOT NULL", md.ID()).
 		Scan(new(int)) != sql.ErrNoRows {
-		var r common.ResponseBase
-		r.Code = 403
-		r.Message = "You already have an official osu! account linked!"
-		return r
+		return common.ErrConnectionAlreadyLinked.WithMessage("You already have an official osu! account linked!")
 	}
 
 	settings := common.GetSettings()
@@ -282,7 +288,7 @@ func OfficialOsuCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if resp.IsError() {
-		return common.SimpleResponse(resp.StatusCode(), "Failed to exchange osu! OAuth code.")
+		return common.ErrConnectionProviderFailed.WithMessage("Failed to exchange osu! OAuth code.")
 	}
 
 	var tokenResp struct {
@@ -295,7 +301,7 @@ func OfficialOsuCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if tokenResp.AccessToken == "" {
-		return common.SimpleResponse(502, "osu! OAuth response did not include an access token.")
+		return common.ErrConnectionProviderInvalid.WithMessage("osu! OAuth response did not include an access token.")
 	}
 
 	userResp, err := client.R().
@@ -309,7 +315,7 @@ func OfficialOsuCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if userResp.IsError() {
-		return common.SimpleResponse(userResp.StatusCode(), "Failed to fetch official osu! user.")
+		return common.ErrConnectionProviderFailed.WithMessage("Failed to fetch official osu! user.")
 	}
 
 	var osuUser struct {
@@ -323,10 +329,10 @@ func OfficialOsuCallbackGET(md common.MethodData) common.CodeMessager {
 	}
 
 	if osuUser.ID <= 0 {
-		return common.SimpleResponse(502, "osu! did not return a user for this OAuth token.")
+		return common.ErrConnectionProviderInvalid.WithMessage("osu! did not return a user for this OAuth token.")
 	}
 
-	_, err = md.DB.Exec(
+	_, err = md.DB.ExecContext(md.Context,
 		"UPDATE users SET official_osu_user_id = ?, official_osu_username = ? WHERE id = ?",
 		osuUser.ID,
 		osuUser.Username,
@@ -336,6 +342,7 @@ func OfficialOsuCallbackGET(md common.MethodData) common.CodeMessager {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("user", md.ID(), auditConnection{"osu", nil}, auditConnection{"osu", osuUser.ID})
 
 	md.Ctx.Redirect("https://akatsuki.gg/settings/connections", 302)
 	return common.SimpleResponse(302, "")
@@ -345,16 +352,16 @@ func validateOAuthState(md common.MethodData, provider string) common.CodeMessag
 	settings := common.GetSettings()
 	state := md.Query("state")
 	if state == "" {
-		return common.SimpleResponse(400, "Missing OAuth state. Please try linking your account again.")
+		return common.ErrMissingOAuthState
 	}
 
 	userID, err := common.ValidateOAuthState(state, provider, settings.HANAYO_KEY, time.Now())
 	if err != nil {
-		return common.SimpleResponse(400, common.OAuthStateValidationMessage(err))
+		return common.ErrBadOAuthState.WithMessage(common.OAuthStateValidationMessage(err))
 	}
 
 	if userID != md.ID() {
-		return common.SimpleResponse(400, common.OAuthStateValidationMessage(common.ErrInvalidOAuthState))
+		return common.ErrBadOAuthState.WithMessage(common.OAuthStateValidationMessage(common.ErrInvalidOAuthState))
 	}
 
 	return nil
@@ -0,0 +1,646 @@
+package v1
+
+import "github.com/osuAkatsuki/akatsuki-api/common"
+
+// Documentation of the routes of the API, used to generate the OpenAPI
+// document. They are given to the router along with the handlers.
+
+var (
+	userParams = []common.Param{
+		common.QueryParam("id", "integer", "The ID of the user."),
+		common.QueryParam("name", "string", "The username of the user, used if id is not given."),
+	}
+	modeParam   = common.QueryParam("mode", "integer", "The game mode: 0 is std, 1 taiko, 2 ctb and 3 mania.")
+	relaxParam  = common.QueryParam("rx", "integer", "0 for vanilla, 1 for relax, 2 for autopilot.")
+	idParam     = common.RequiredParam("id", "integer", "The ID of the resource.")
+	auditParams = []common.Param{
+		common.QueryParam("target_type", "string", "Only return the changes to this kind of entity, e.g. user, clan, score, token or oauth_client."),
+		common.QueryParam("target_id", "string", "Only return the changes to the entity with this ID."),
+		common.QueryParam("route", "string", "Only return the changes made through this route, e.g. POST /api/v1/friends/add."),
+	}
+)
+
+var (
+	HealthGETDoc = common.RouteDoc{
+		Summary:  "Health check of the API and its dependencies.",
+		Response: healthResponse{},
+	}
+
+	TokenNewPOSTDoc = common.RouteDoc{
+		Summary:  "Create an API token, logging in with username and password.",
+		Request:  tokenNewInData{},
+		Response: tokenNewResponse{},
+	}
+	TokenSelfDeletePOSTDoc = common.RouteDoc{
+		Summary: "Revoke the token used to make the request.",
+	}
+	TokenGETDoc = common.RouteDoc{
+		Summary:  "List the API tokens of the user.",
+		Params:   common.Params([]common.Param{common.QueryParam("id", "integer", "Only return the token with this ID.")}, common.PaginationParams),
+		Response: tokenResponse{},
+	}
+	TokenSelfGETDoc = common.RouteDoc{
+		Summary:  "Information about the token used to make the request.",
+		Response: tokenSingleResponse{},
+	}
+	TokenDeletePOSTDoc = common.RouteDoc{
+		Summary: "Revoke one of the user's tokens.",
+		Request: tokenDeleteData{},
+	}
+	TokenDeleteOthersPOSTDoc = common.RouteDoc{
+		Summary:  "Revoke all the user's tokens except the one used to make the request.",
+		Response: tokensRevokedResponse{},
+	}
+	TokenEditPOSTDoc = common.RouteDoc{
+		Summary:  "Change the description or expiry of one of the user's tokens, and return it.",
+		Request:  tokenEditData{},
+		Response: singleTokenResponse{},
+	}
+
+	OAuthTokenPOSTDoc = common.RouteDoc{
+		Summary: "OAuth 2 token endpoint.",
+		Description: "Exchanges an authorization code or a refresh token for an access token, as described in RFC 6749. " +
+			"The parameters are given as application/x-www-form-urlencoded.",
+		Response: oauthTokenResponse{},
+	}
+	OAuthRevokePOSTDoc = common.RouteDoc{
+		Summary:     "OAuth 2 token revocation endpoint.",
+		Description: "Revokes an access or refresh token, as described in RFC 7009.",
+	}
+	OAuthIntrospectPOSTDoc = common.RouteDoc{
+		Summary:     "OAuth 2 token introspection endpoint.",
+		Description: "Returns whether a token is active and what it grants, as described in RFC 7662.",
+		Response:    oauthIntrospectResponse{},
+	}
+	OAuthScopesGETDoc = common.RouteDoc{
+		Summary:  "List the scopes OAuth applications can request.",
+		Response: oauthScopesResponse{},
+	}
+	OAuthAuthorizeGETDoc = common.RouteDoc{
+		Summary: "Validate an authorization request, returning what the user should consent to.",
+		Params: []common.Param{
+			common.RequiredParam("client_id", "string", "The ID of the application."),
+			common.QueryParam("redirect_uri", "string", "Where to redirect the user after the authorization."),
+			common.RequiredParam("response_type", "string", "Must be code."),
+			common.QueryParam("scope", "string", "Space separated list of scopes."),
+			common.QueryParam("state", "string", "Opaque value returned to the application."),
+			common.QueryParam("code_challenge", "string", "PKCE code challenge, required for public applications."),
+			common.QueryParam("code_challenge_method", "string", "S256 or plain."),
+		},
+		Response: oauthAuthorizeInfoResponse{},
+	}
+	OAuthAuthorizePOSTDoc = common.RouteDoc{
+		Summary:  "Grant an authorization request.",
+		Request:  oauthAuthorizeData{},
+		Response: oauthAuthorizeResponse{},
+	}
+	OAuthClientsGETDoc = common.RouteDoc{
+		Summary:  "List the OAuth applications owned by the user.",
+		Response: oauthClientsResponse{},
+	}
+	OAuthClientNewPOSTDoc = common.RouteDoc{
+		Summary:  "Create an OAuth application.",
+		Request:  oauthClientData{},
+		Response: oauthClientCreatedResponse{},
+	}
+	OAuthClientDeletePOSTDoc = common.RouteDoc{
+		Summary: "Delete an OAuth application owned by the user.",
+		Request: oauthClientDeleteData{},
+	}
+	TokenSelfConsentsGETDoc = common.RouteDoc{
+		Summary: "List the OAuth applications the user has granted access to.",
+		Description: "The consents are listed under /tokens/self/consents rather than /tokens/self, " +
+			"which already describes the token used to make the request.",
+		Response: oauthConsentsResponse{},
+	}
+	TokenSelfConsentsRevokePOSTDoc = common.RouteDoc{
+		Summary: "Revoke the access of an OAuth application to the user's account.",
+		Request: oauthConsentRevokeData{},
+	}
+
+	PingGETDoc = common.RouteDoc{
+		Summary:  "Check the API is up, and what the token used grants.",
+		Response: pingResponse{},
+	}
+	SurpriseMeGETDoc = common.RouteDoc{
+		Summary:  "Get some random surprises.",
+		Response: surpriseMeResponse{},
+	}
+	MatchGETDoc = common.RouteDoc{
+		Summary: "Get a multiplayer match and its events.",
+		Params: []common.Param{
+			idParam,
+			common.QueryParam("before", "integer", "Only return events before this event ID."),
+			common.QueryParam("after", "integer", "Only return events after this event ID."),
+			common.QueryParam("limit", "integer", "The maximum number of events to return."),
+		},
+		Response: matchDataResponse{},
+	}
+
+	UsersGETDoc = common.RouteDoc{
+		Summary: "Get a user, or list the users matching the filters.",
+		Description: "If id or name is given, the user is returned in the user field. " +
+			"Otherwise, the users matching the other parameters are returned in the users field.",
+		Params: common.Params(userParams, []common.Param{
+			common.QueryParam("nname", "string", "Only users whose username is not this."),
+			common.QueryParam("iid", "integer", "Only users whose ID is not this."),
+			common.QueryParam("privileges", "integer", "Only users with exactly these privileges."),
+			common.QueryParam("has_privileges", "integer", "Only users having these privileges."),
+			common.QueryParam("has_not_privileges", "integer", "Only users not having these privileges."),
+			common.QueryParam("country", "string", "Only users from this country."),
+			common.QueryParam("name_aka", "string", "Only users with this alternative name."),
+			common.QueryParam("privilege_group", "string", "Only users in this privilege group."),
+			common.MultiParam("ids", "integer", "Only users with these IDs."),
+			common.MultiParam("names", "string", "Only users with these usernames."),
+			common.MultiParam("names_aka", "string", "Only users with these alternative names."),
+			common.MultiParam("countries", "string", "Only users from these countries."),
+			common.QueryParam("sort", "string", "How to sort the users, e.g. id,desc."),
+		}, common.PaginationParams),
+		Response: userPutsMultiUserData{},
+	}
+	UserSelfGETDoc = common.RouteDoc{
+		Summary:  "Get the user making the request.",
+		Response: userPutsSingleUserData{},
+	}
+	UserWhatsTheIDGETDoc = common.RouteDoc{
+		Summary:  "Get the ID of a user given their username.",
+		Params:   []common.Param{common.RequiredParam("name", "string", "The username.")},
+		Response: whatIDResponse{},
+	}
+	UserFullGETDoc = common.RouteDoc{
+		Summary:  "Get a user along with their stats in all modes.",
+		Params:   userParams,
+		Response: userFullResponse{},
+	}
+	UserAchievementsGETDoc = common.RouteDoc{
+		Summary: "Get the achievements of a user.",
+		Params: common.Params(userParams, []common.Param{
+			modeParam,
+			common.QueryParam("all", "boolean", "Also return the achievements the user has not unlocked."),
+		}),
+		Response: userAchievementsResponse{},
+	}
+	UserUserpageGETDoc = common.RouteDoc{
+		Summary: "Get the userpage of a user.",
+		Params: common.Params(userParams, []common.Param{
+			common.QueryParam("de", "boolean", "If 1, do not escape the HTML in the userpage."),
+		}),
+		Response: userpageResponse{},
+	}
+	UserLookupGETDoc = common.RouteDoc{
+		Summary:  "Find users whose username starts with the given one.",
+		Params:   []common.Param{common.RequiredParam("name", "string", "The beginning of the username.")},
+		Response: userLookupResponse{},
+	}
+	UserScoresBestGETDoc = common.RouteDoc{
+		Summary:  "Get the best scores of a user.",
+		Params:   common.Params(userParams, []common.Param{modeParam, relaxParam}, common.PaginationParams),
+		Response: userScoresResponse{},
+	}
+	UserScoresRecentGETDoc = common.RouteDoc{
+		Summary:  "Get the most recent scores of a user.",
+		Params:   common.Params(userParams, []common.Param{modeParam, relaxParam}, common.PaginationParams),
+		Response: userScoresResponse{},
+	}
+	UserScoresPinnedGETDoc = common.RouteDoc{
+		Summary:  "Get the scores a user has pinned.",
+		Params:   common.Params(userParams, []common.Param{modeParam, relaxParam}, common.PaginationParams),
+		Response: userScoresResponse{},
+	}
+	UserFirstGETDoc = common.RouteDoc{
+		Summary:  "Get the first place scores of a user.",
+		Params:   common.Params([]common.Param{idParam, modeParam, relaxParam}, common.PaginationParams),
+		Response: userFirstResponse{},
+	}
+	UserMostPlayedBeatmapsGETDoc = common.RouteDoc{
+		Summary:  "Get the beatmaps a user has played the most.",
+		Params:   common.Params([]common.Param{idParam, modeParam, relaxParam}, common.PaginationParams),
+		Response: mostPlayedBeatmapsResponse{},
+	}
+	UserGradesGETDoc = common.RouteDoc{
+		Summary:  "Get the number of scores of a user for each grade.",
+		Params:   []common.Param{idParam, modeParam},
+		Response: userGradesResponse{},
+	}
+
+	BadgesGETDoc = common.RouteDoc{
+		Summary: "List the badges.",
+		Params: common.Params([]common.Param{
+			common.QueryParam("id", "integer", "Only return the badge with this ID."),
+		}, common.PaginationParams),
+		Response: multiBadgeData{},
+	}
+	BadgeMembersGETDoc = common.RouteDoc{
+		Summary:  "List the users having a badge.",
+		Params:   []common.Param{idParam},
+		Response: badgeMembersData{},
+	}
+	TBadgesGETDoc = common.RouteDoc{
+		Summary: "List the tournament badges.",
+		Params: common.Params([]common.Param{
+			common.QueryParam("id", "integer", "Only return the badge with this ID."),
+		}, common.PaginationParams),
+		Response: TmultiBadgeData{},
+	}
+	TBadgeMembersGETDoc = common.RouteDoc{
+		Summary:  "List the users having a tournament badge.",
+		Params:   []common.Param{idParam},
+		Response: TbadgeMembersData{},
+	}
+
+	ClansGETDoc = common.RouteDoc{
+		Summary: "Get a clan, or list the clans.",
+		Params: common.Params([]common.Param{
+			common.QueryParam("id", "integer", "Only return the clan with this ID."),
+		}, common.PaginationParams),
+		Response: multiClanResponse{},
+	}
+	ClanMembersGETDoc = common.RouteDoc{
+		Summary:  "Get a clan and its members.",
+		Params:   []common.Param{idParam},
+		Response: clanMembersResponse{},
+	}
+	ClanStatsGETDoc = common.RouteDoc{
+		Summary:  "Get the stats of a clan in a mode.",
+		Params:   []common.Param{idParam, common.QueryParam("m", "integer", "The game mode."), relaxParam},
+		Response: clanStatsResponse{},
+	}
+	ClanLeaderboardGETDoc = common.RouteDoc{
+		Summary: "Get the clan leaderboard by performance.",
+		Params: []common.Param{
+			common.QueryParam("m", "integer", "The game mode."),
+			common.QueryParam("p", "integer", "The page, starting from 1."),
+			relaxParam,
+		},
+		Response: clanLeaderboardResponse{},
+	}
+	ClansFirstPlaceRankingGETDoc = common.RouteDoc{
+		Summary:  "Get the clan leaderboard by number of first place scores.",
+		Params:   common.Params([]common.Param{common.QueryParam("m", "integer", "The game mode."), relaxParam}, common.PaginationParams),
+		Response: clansFirstPlaceResponse{},
+	}
+	ResolveInviteGETDoc = common.RouteDoc{
+		Summary:  "Get the clan an invite is for.",
+		Params:   []common.Param{common.RequiredParam("invite", "string", "The invite code.")},
+		Response: clanInviteResponse{},
+	}
+	ClanJoinPOSTDoc = common.RouteDoc{
+		Summary:  "Join a clan, given its ID or an invite.",
+		Request:  clanJoinData{},
+		Response: clanInviteResponse{},
+	}
+	ClanGenerateInvitePOSTDoc = common.RouteDoc{
+		Summary:  "Generate a new invite for the clan owned by the user.",
+		Response: clanInviteCodeResponse{},
+	}
+	ClanLeavePOSTDoc = common.RouteDoc{
+		Summary:     "Leave the user's clan.",
+		Description: "If the user is the owner, the clan is disbanded and the message is disbanded.",
+	}
+	ClanSettingsPOSTDoc = common.RouteDoc{
+		Summary:  "Change the settings of the clan owned by the user.",
+		Request:  clanSettingsData{},
+		Response: SingleClanResponse{},
+	}
+	ClanKickPOSTDoc = common.RouteDoc{
+		Summary: "Kick a member from the clan owned by the user.",
+		Request: clanKickData{},
+	}
+	ClanTransferOwnershipPOSTDoc = common.RouteDoc{
+		Summary: "Give the ownership of the user's clan to another member.",
+		Request: clanTransferOwnershipData{},
+	}
+
+	BeatmapGETDoc = common.RouteDoc{
+		Summary: "Get a beatmap, or list beatmaps.",
+		Params: common.Params([]common.Param{
+			common.QueryParam("b", "integer", "Only return the beatmap with this ID."),
+			common.MultiParam("bb", "integer", "Only beatmaps with these IDs."),
+			common.MultiParam("s", "integer", "Only beatmaps in these sets."),
+			common.MultiParam("md5", "string", "Only beatmaps with these MD5 hashes."),
+			common.QueryParam("song_name", "string", "Only beatmaps with this song name."),
+			common.QueryParam("ranked_status_frozen", "boolean", "Only beatmaps whose ranked status is frozen."),
+			common.QueryParam("sort", "string", "How to sort the beatmaps, e.g. id,desc."),
+		}, common.PaginationParams),
+		Response: beatmapSetResponse{},
+	}
+	BeatmapStatusChangesGETDoc = common.RouteDoc{
+		Summary: "List the most recent changes of the ranked status of beatmaps.",
+		Params: common.Params([]common.Param{
+			common.QueryParam("b", "integer", "Only the changes of the beatmap with this ID."),
+			common.QueryParam("s", "integer", "Only the changes of the beatmaps in this set."),
+			common.QueryParam("action", "string", "Only the changes made with this action: rank, love, qualify, unrank, freeze or unfreeze."),
+		}, common.PaginationParams),
+		Response: beatmapStatusChangesResponse{},
+	}
+	BeatmapStatusPOSTDoc = common.RouteDoc{
+		Summary: "Change the ranked status of a beatmap, or of all the beatmaps of a set.",
+		Description: "rank, love, qualify and unrank set the status and freeze it, so that it is not overwritten when the " +
+			"beatmap is updated from the osu! API. freeze and unfreeze only change whether the status is frozen.",
+		Request:  beatmapStatusData{},
+		Response: beatmapSetResponse{},
+	}
+	LeaderboardGETDoc = common.RouteDoc{
+		Summary: "Get the global or country leaderboard.",
+		Params: common.Params([]common.Param{
+			modeParam,
+			relaxParam,
+			common.QueryParam("country", "string", "Only users from this country."),
+			common.QueryParam("sort", "string", "Sort by pp or score."),
+		}, common.PaginationParams),
+		Response: leaderboardResponse{},
+	}
+	BlogPostsGETDoc = common.RouteDoc{
+		Summary:  "Get the latest blog posts.",
+		Params:   []common.Param{common.QueryParam("l", "integer", "The number of posts.")},
+		Response: blogPostsResponse{},
+	}
+	ScoreGETDoc = common.RouteDoc{
+		Summary:  "Get a score.",
+		Params:   []common.Param{idParam, relaxParam},
+		Response: scoreResponse{},
+	}
+	ScoresGETDoc = common.RouteDoc{
+		Summary: "Get the scores set on a beatmap.",
+		Params: common.Params([]common.Param{
+			common.QueryParam("b", "integer", "The ID of the beatmap."),
+			common.QueryParam("md5", "string", "The MD5 hash of the beatmap, used if b is not given."),
+			common.QueryParam("m", "integer", "The game mode."),
+			common.QueryParam("relax", "integer", "0 for vanilla, 1 for relax, 2 for autopilot."),
+		}, common.PaginationParams),
+		Response: scoresResponse{},
+	}
+	CountriesGETDoc = common.RouteDoc{
+		Summary:  "List the countries and their number of users.",
+		Response: MultiCount{},
+	}
+	HypotheticalRankGETDoc = common.RouteDoc{
+		Summary: "Get the rank a user would have with the given performance.",
+		Params: []common.Param{
+			modeParam,
+			common.RequiredParam("pp", "number", "The performance points."),
+			relaxParam,
+		},
+		Response: hypotheticalRankResponse{},
+	}
+
+	connectionCallbackParams = []common.Param{
+		common.RequiredParam("code", "string", "The authorization code given by the provider."),
+		common.RequiredParam("state", "string", "The state given when starting the authorization."),
+	}
+	DiscordCallbackGETDoc = common.RouteDoc{
+		Summary: "Link a Discord account to the user.",
+		Params:  connectionCallbackParams,
+	}
+	TwitchCallbackGETDoc = common.RouteDoc{
+		Summary: "Link a Twitch account to the user.",
+		Params:  connectionCallbackParams,
+	}
+	OfficialOsuCallbackGETDoc = common.RouteDoc{
+		Summary: "Link an osu! account to the user.",
+		Params:  connectionCallbackParams,
+	}
+	DiscordUnlinkPOSTDoc = common.RouteDoc{
+		Summary: "Unlink the Discord account of the user.",
+	}
+	TwitchUnlinkPOSTDoc = common.RouteDoc{
+		Summary: "Unlink the Twitch account of the user.",
+	}
+	OfficialOsuUnlinkPOSTDoc = common.RouteDoc{
+		Summary: "Unlink the osu! account of the user.",
+	}
+
+	FriendsGETDoc = common.RouteDoc{
+		Summary:  "List the friends of the user.",
+		Params:   common.PaginationParams,
+		Response: friendsGETResponse{},
+	}
+	FollowersGETDoc = common.RouteDoc{
+		Summary:  "List the users having the user as a friend.",
+		Params:   common.PaginationParams,
+		Response: followersGETResponse{},
+	}
+	FriendsWithGETDoc = common.RouteDoc{
+		Summary:  "Check whether the user is friends with another.",
+		Params:   []common.Param{idParam},
+		Response: friendsWithResponse{},
+	}
+	FriendsAddPOSTDoc = common.RouteDoc{
+		Summary:  "Add a user to the friends.",
+		Request:  friendUserData{},
+		Response: friendsWithResponse{},
+	}
+	FriendsDelPOSTDoc = common.RouteDoc{
+		Summary:  "Remove a user from the friends.",
+		Request:  friendUserData{},
+		Response: friendsWithResponse{},
+	}
+
+	UsersSelfDonorInfoGETDoc = common.RouteDoc{
+		Summary:  "Get the donor status of the user.",
+		Response: donorInfoResponse{},
+	}
+	UsersSelfFavouriteModeGETDoc = common.RouteDoc{
+		Summary:  "Get the favourite mode of the user.",
+		Response: favouriteModeResponse{},
+	}
+	UsersSelfSettingsGETDoc = common.RouteDoc{
+		Summary:  "Get the settings of the user.",
+		Response: userSettingsResponse{},
+	}
+	UsersSelfSettingsPOSTDoc = common.RouteDoc{
+		Summary:  "Change the settings of the user.",
+		Request:  userSettingsData{},
+		Response: userSettingsResponse{},
+	}
+	UserSelfUserpagePOSTDoc = common.RouteDoc{
+		Summary:  "Change the userpage of the user.",
+		Request:  userpageData{},
+		Response: userpageResponse{},
+	}
+	ScoresPinAddPOSTDoc = common.RouteDoc{
+		Summary:  "Pin one of the user's scores.",
+		Request:  pinData{},
+		Response: pinResponse{},
+	}
+	ScoresPinDelPOSTDoc = common.RouteDoc{
+		Summary:  "Unpin one of the user's scores.",
+		Request:  pinData{},
+		Response: pinResponse{},
+	}
+	AuditSelfGETDoc = common.RouteDoc{
+		Summary:  "List the changes made by the user, most recent first.",
+		Params:   common.Params(auditParams, common.PaginationParams),
+		Response: auditResponse{},
+	}
+	AuditGETDoc = common.RouteDoc{
+		Summary:  "List the changes made by any user, most recent first.",
+		Params:   common.Params([]common.Param{common.QueryParam("user", "integer", "Only return the changes made by this user.")}, auditParams, common.PaginationParams),
+		Response: auditResponse{},
+	}
+)
+
+var (
+	AdminUserRestrictPOSTDoc = common.RouteDoc{
+		Summary:     "Restrict a user.",
+		Description: "Restricted users are hidden from the leaderboards and from the other users.",
+		Request:     moderationData{},
+		Response:    moderationResponse{},
+	}
+	AdminUserUnrestrictPOSTDoc = common.RouteDoc{
+		Summary:     "Lift the restriction of a user.",
+		Description: "The user is added back to the leaderboards with their current pp. Banned users must be unbanned instead.",
+		Request:     moderationData{},
+		Response:    moderationResponse{},
+	}
+	AdminUserBanPOSTDoc = common.RouteDoc{
+		Summary:     "Ban a user.",
+		Description: "Banned users can't log in, and are removed from the leaderboards.",
+		Request:     moderationData{},
+		Response:    moderationResponse{},
+	}
+	AdminUserUnbanPOSTDoc = common.RouteDoc{
+		Summary:  "Lift the ban of a user.",
+		Request:  moderationData{},
+		Response: moderationResponse{},
+	}
+	AdminUserFreezePOSTDoc = common.RouteDoc{
+		Summary:  "Freeze a user, who has to verify their identity to keep playing.",
+		Request:  moderationData{},
+		Response: moderationResponse{},
+	}
+	AdminUserUnfreezePOSTDoc = common.RouteDoc{
+		Summary:  "Unfreeze a user.",
+		Request:  moderationData{},
+		Response: moderationResponse{},
+	}
+	AdminUserSilencePOSTDoc = common.RouteDoc{
+		Summary:  "Prevent a user from chatting for some time.",
+		Request:  silenceData{},
+		Response: moderationResponse{},
+	}
+	AdminUserUnsilencePOSTDoc = common.RouteDoc{
+		Summary:  "Lift the silence of a user.",
+		Request:  moderationData{},
+		Response: moderationResponse{},
+	}
+	AdminUserModerationHistoryGETDoc = common.RouteDoc{
+		Summary:  "List the moderation actions taken against a user, most recent first.",
+		Params:   common.Params([]common.Param{idParam}, common.PaginationParams),
+		Response: moderationHistoryResponse{},
+	}
+)
+
+var (
+	BadgeNewPOSTDoc = common.RouteDoc{
+		Summary:  "Create a badge.",
+		Request:  badgeNewData{},
+		Response: singleBadgeResponse{},
+	}
+	BadgeEditPOSTDoc = common.RouteDoc{
+		Summary:  "Change the name, icon or colour of a badge.",
+		Request:  badgeEditData{},
+		Response: singleBadgeResponse{},
+	}
+	BadgeDeletePOSTDoc = common.RouteDoc{
+		Summary:     "Delete a badge.",
+		Description: "The badge is taken away from all the users who have it.",
+		Request:     badgeIDData{},
+	}
+	BadgeGrantPOSTDoc = common.RouteDoc{
+		Summary: "Give a badge to a user.",
+		Request: badgeGrantData{},
+	}
+	BadgeRevokePOSTDoc = common.RouteDoc{
+		Summary:     "Take a badge away from a user.",
+		Description: "If the user no longer has the badge giving their title, the title is reset.",
+		Request:     badgeGrantData{},
+	}
+	TBadgeNewPOSTDoc = common.RouteDoc{
+		Summary:  "Create a tournament badge.",
+		Request:  tbadgeNewData{},
+		Response: singleTBadgeResponse{},
+	}
+	TBadgeEditPOSTDoc = common.RouteDoc{
+		Summary:  "Change the name or icon of a tournament badge.",
+		Request:  tbadgeEditData{},
+		Response: singleTBadgeResponse{},
+	}
+	TBadgeDeletePOSTDoc = common.RouteDoc{
+		Summary:     "Delete a tournament badge.",
+		Description: "The badge is taken away from all the users who have it.",
+		Request:     badgeIDData{},
+	}
+	TBadgeGrantPOSTDoc = common.RouteDoc{
+		Summary: "Give a tournament badge to a user.",
+		Request: badgeGrantData{},
+	}
+	TBadgeRevokePOSTDoc = common.RouteDoc{
+		Summary: "Take a tournament badge away from a user.",
+		Request: badgeGrantData{},
+	}
+)
+
+var (
+	MetaGETDoc = common.RouteDoc{
+		Summary:  "Get the version, uptime, goroutines and memory usage of the API.",
+		Response: metaResponse{},
+	}
+	MetaAchievementsReloadPOSTDoc = common.RouteDoc{
+		Summary:  "Reload the achievements from the database.",
+		Response: metaAchievementsResponse{},
+	}
+	MetaCacheFlushPOSTDoc = common.RouteDoc{
+		Summary:  "Flush the response cache, or only the responses with some tags.",
+		Request:  metaCacheFlushData{},
+		Response: metaCacheFlushResponse{},
+	}
+	MetaMaintenancePOSTDoc = common.RouteDoc{
+		Summary:     "Turn maintenance mode on or off.",
+		Description: "Toggles maintenance mode if enabled is null. See /api/v1/admin/settings to change the other settings.",
+		Request:     metaMaintenanceData{},
+		Response:    metaMaintenanceResponse{},
+	}
+)
+
+var (
+	SystemSettingsGETDoc = common.RouteDoc{
+		Summary:  "Get the system settings, such as maintenance mode.",
+		Response: systemSettingsResponse{},
+	}
+	SystemSettingsPOSTDoc = common.RouteDoc{
+		Summary: "Change the system settings.",
+		Description: "The fields left out are not changed. While maintenance mode is on, the API answers 503 to the requests " +
+			"changing data, or to all requests if api_maintenance_scope is all, except those made by staff.",
+		Request:  systemSettingsData{},
+		Response: systemSettingsResponse{},
+	}
+	AlertsGETDoc = common.RouteDoc{
+		Summary:     "List the global alerts active right now.",
+		Description: "The most severe alerts come first. Alerts are shown between their starts_at and ends_at.",
+		Params: []common.Param{
+			common.QueryParam("target", "string", "Only the alerts shown on website or bancho."),
+		},
+		Response: globalAlertsResponse{},
+	}
+	AdminAlertsGETDoc = common.RouteDoc{
+		Summary:  "List all the global alerts, including the ones that ended or are yet to start.",
+		Params:   common.PaginationParams,
+		Response: globalAlertsResponse{},
+	}
+	AlertNewPOSTDoc = common.RouteDoc{
+		Summary:  "Create a global alert.",
+		Request:  alertNewData{},
+		Response: singleGlobalAlertResponse{},
+	}
+	AlertEditPOSTDoc = common.RouteDoc{
+		Summary:     "Change a global alert.",
+		Description: "The fields left out are not changed. A null ends_at shows the alert until it is deleted.",
+		Request:     alertEditData{},
+		Response:    singleGlobalAlertResponse{},
+	}
+	AlertDeletePOSTDoc = common.RouteDoc{
+		Summary: "Delete a global alert.",
+		Request: alertIDData{},
+	}
+)
@@ -25,7 +25,7 @@ type followersGETResponse struct {
 // It retrieves an user's friends, and whether the friendship is mutual or not.
 func FriendsGET(md common.MethodData) common.CodeMessager {
 	var myFrienders []int
-	myFriendersRaw, err := md.DB.Query("SELECT user1 FROM users_relationships INNER JOIN users ON users_relationships.user1 = users.id WHERE user2 = ? AND privileges & 1", md.ID())
+	myFriendersRaw, err := md.DB.QueryContext(md.Context, "SELECT user1 FROM users_relationships INNER JOIN users ON users_relationships.user1 = users.id WHERE user2 = ? AND privileges & 1", md.ID())
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -65,7 +65,7 @@ AND privileges & 1
 		Table:   "users",
 	}) + "\n"
 
-	results, err := md.DB.Query(myFriendsQuery+common.Paginate(md.Query("p"), md.Query("l"), 100), md.ID())
+	results, err := md.DB.QueryContext(md.Context, myFriendsQuery+common.Paginate(md.Query("p"), md.Query("l"), 100), md.ID())
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -98,7 +98,7 @@ func FollowersGET(md common.MethodData) common.CodeMessager {
 	r := followersGETResponse{}
 
 	if md.User.UserPrivileges&common.UserPrivilegePremium == 0 {
-		return common.SimpleResponse(403, "You don't have privileges to access that route.")
+		return common.ErrForbidden
 	}
 
 	myFollowersQuery := `
@@ -125,7 +125,7 @@ func FollowersGET(md common.MethodData) common.CodeMessager {
 		Table:   "users",
 	}) + "\n"
 
-	results, err := md.DB.Query(myFollowersQuery+common.Paginate(md.Query("p"), md.Query("l"), 100), md.ID(), md.ID())
+	results, err := md.DB.QueryContext(md.Context, myFollowersQuery+common.Paginate(md.Query("p"), md.Query("l"), 100), md.ID(), md.ID())
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -179,7 +179,7 @@ func FriendsWithGET(md common.MethodData) common.CodeMessager {
 	if uid == 0 {
 		return r
 	}
-	err := md.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users_relationships WHERE user1 = ? AND user2 = ? LIMIT 1), EXISTS(SELECT 1 FROM users_relationships WHERE user2 = ? AND user1 = ? LIMIT 1)", md.ID(), uid, md.ID(), uid).Scan(&r.Friends, &r.Mutual)
+	err := md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM users_relationships WHERE user1 = ? AND user2 = ? LIMIT 1), EXISTS(SELECT 1 FROM users_relationships WHERE user2 = ? AND user1 = ? LIMIT 1)", md.ID(), uid, md.ID(), uid).Scan(&r.Friends, &r.Mutual)
 	if err != sql.ErrNoRows && err != nil {
 		md.Err(err)
 		return Err500
@@ -190,37 +190,47 @@ func FriendsWithGET(md common.MethodData) common.CodeMessager {
 	return r
 }
 
+// auditFriend summarises a friendship in the audit log.
+type auditFriend struct {
+	Friend bool `json:"friend"`
+}
+
+type friendUserData struct {
+	User int `json:"user" validate:"required,min=1"`
+}
+
 // FriendsAddPOST adds an user to the friends.
 func FriendsAddPOST(md common.MethodData) common.CodeMessager {
-	var u struct {
-		User int `json:"user"`
+	var u friendUserData
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
 	}
-	md.Unmarshal(&u)
 	return addFriend(md, u.User)
 }
 
 func addFriend(md common.MethodData, u int) common.CodeMessager {
 	if md.ID() == u {
-		return common.SimpleResponse(406, "Just so you know: you can't add yourself to your friends.")
+		return common.ErrFriendSelf
 	}
 	if !userExists(md, u) {
-		return common.SimpleResponse(404, "I'd also like to be friends with someone who doesn't even exist (???), however that's NOT POSSIBLE.")
+		return common.ErrUserNotFound
 	}
 	var (
 		relExists bool
 		isMutual  bool
 	)
-	err := md.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users_relationships WHERE user1 = ? AND user2 = ?), EXISTS(SELECT 1 FROM users_relationships WHERE user2 = ? AND user1 = ?)", md.ID(), u, md.ID(), u).Scan(&relExists, &isMutual)
+	err := md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM users_relationships WHERE user1 = ? AND user2 = ?), EXISTS(SELECT 1 FROM users_relationships WHERE user2 = ? AND user1 = ?)", md.ID(), u, md.ID(), u).Scan(&relExists, &isMutual)
 	if err != nil && err != sql.ErrNoRows {
 		md.Err(err)
 		return Err500
 	}
 	if !relExists {
-		_, err := md.DB.Exec("INSERT INTO users_relationships(user1, user2) VALUES (?, ?)", md.User.UserID, u)
+		_, err := md.DB.ExecContext(md.Context, "INSERT INTO users_relationships(user1, user2) VALUES (?, ?)", md.User.UserID, u)
 		if err != nil {
 			md.Err(err)
 			return Err500
 		}
+		md.Audit("user", u, auditFriend{false}, auditFriend{true})
 	}
 	var r friendsWithResponse
 	r.Code = 200
@@ -231,7 +241,7 @@ func addFriend(md common.MethodData, u int) common.CodeMessager {
 
 // userExists makes sure an user exists.
 func userExists(md common.MethodData, u int) (r bool) {
-	err := md.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND "+
+	err := md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND "+
 		md.User.OnlyUserPublic(true)+")", u).Scan(&r)
 	if err != nil && err != sql.ErrNoRows {
 		md.Err(err)
@@ -241,19 +251,22 @@ func userExists(md common.MethodData, u int) (r bool) {
 
 // FriendsDelPOST deletes an user's friend.
 func FriendsDelPOST(md common.MethodData) common.CodeMessager {
-	var u struct {
-		User int `json:"user"`
+	var u friendUserData
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
 	}
-	md.Unmarshal(&u)
 	return delFriend(md, u.User)
 }
 
 func delFriend(md common.MethodData, u int) common.CodeMessager {
-	_, err := md.DB.Exec("DELETE FROM users_relationships WHERE user1 = ? AND user2 = ?", md.ID(), u)
+	res, err := md.DB.ExecContext(md.Context, "DELETE FROM users_relationships WHERE user1 = ? AND user2 = ?", md.ID(), u)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		md.Audit("user", u, auditFriend{true}, auditFriend{false})
+	}
 	r := friendsWithResponse{
 		Friends: false,
 		Mutual:  false,
@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"sync/atomic"
+
 	"github.com/osuAkatsuki/akatsuki-api/app/peppy"
 	"github.com/osuAkatsuki/akatsuki-api/common"
 )
@@ -9,9 +11,24 @@ type healthResponse struct {
 	common.ResponseBase
 }
 
+// draining is set once the server has started shutting down.
+var draining atomic.Bool
+
+// SetDraining makes the health check fail, so that the load balancer stops
+// sending new requests while the server shuts down.
+func SetDraining() {
+	draining.Store(true)
+}
+
 func HealthGET(md common.MethodData) common.CodeMessager {
 	var r healthResponse
 
+	if draining.Load() {
+		r.Code = 503
+		r.Message = "draining"
+		return r
+	}
+
 	err := peppy.R.Ping().Err()
 	if err != nil {
 		r.Code = 500
@@ -19,7 +36,7 @@ func HealthGET(md common.MethodData) common.CodeMessager {
 		return r
 	}
 
-	err = md.DB.Ping()
+	err = md.DB.PingContext(md.Context)
 	if err != nil {
 		r.Code = 500
 		r.Message = "database error"
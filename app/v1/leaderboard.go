@@ -62,7 +62,7 @@ func getLbUsersDb(p int, l int, rx int, modeInt int, sort string, md common.Meth
 		order = "ORDER BY user_stats.pp DESC, user_stats.ranked_score DESC"
 	}
 	query = fmt.Sprintf(lbUserQuery+"WHERE (users.privileges & 3) >= 3 AND user_stats.mode = ? "+order+" LIMIT %d, %d", p*l, l)
-	rows, err := md.DB.Query(query, modeInt+(rx*4))
+	rows, err := md.DB.QueryContext(md.Context, query, modeInt+(rx*4))
 	if err != nil {
 		md.Err(err)
 		return make([]leaderboardUser, 0)
@@ -151,7 +151,7 @@ func LeaderboardGET(md common.MethodData) common.CodeMessager {
 
 	var query = lbUserQuery + `WHERE users.id IN (?) AND user_stats.mode = ? ORDER BY user_stats.pp DESC, user_stats.ranked_score DESC`
 	query, params, _ := sqlx.In(query, results, modeInt+(rx*4))
-	rows, err := md.DB.Query(query, params...)
+	rows, err := md.DB.QueryContext(md.Context, query, params...)
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+	"github.com/valyala/fasthttp"
+	"gopkg.in/redis.v5"
+)
+
+// testMethodData returns a MethodData for a POST request with the given body,
+// backed by a mocked database and an in-memory redis. The body is parsed as
+// a form by postArg, and as JSON by ParseBody.
+func testMethodData(t *testing.T, body string) (common.MethodData, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := miniredis.RunT(t)
+	r := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { r.Close() })
+
+	c := &fasthttp.RequestCtx{}
+	c.Request.Header.SetMethod("POST")
+	c.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	c.Request.SetBodyString(body)
+	return common.MethodData{
+		DB:      sqlx.NewDb(db, "mysql"),
+		R:       r,
+		Ctx:     c,
+		Context: context.Background(),
+	}, mock
+}
@@ -150,20 +150,20 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 	afterEventId := common.Int(md.Query("after"))
 	limit := clamp(common.Int(md.Query("limit")), 1, 101)
 
-	err := md.DB.QueryRow(
+	err := md.DB.QueryRowContext(md.Context,
 		"SELECT id, name, private, start_time, end_time FROM matches WHERE id = ? LIMIT 1",
 		matchId,
 	).Scan(&r.Match.Id, &r.Match.Name, &privateMatch, &r.Match.StartTime, &r.Match.EndTime)
 
 	switch {
 	case err == sql.ErrNoRows:
-		return common.SimpleResponse(404, "That match could not be found!")
+		return common.ErrMatchNotFound
 	case err != nil:
 		md.Err(err)
 		return Err500
 	}
 
-	err = md.DB.Get(
+	err = md.DB.GetContext(md.Context,
 		&participantsIds,
 		`SELECT JSON_ARRAYAGG(user_id) FROM (
 			SELECT DISTINCT(user_id) AS user_id FROM
@@ -181,7 +181,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 	if privateMatch &&
 		(!inList(participantsIds, md.User.ID) ||
 			md.User.UserPrivileges&common.UserPrivilegeTournamentStaff == 0) {
-		return common.SimpleResponse(404, "That match could not be found!")
+		return common.ErrMatchNotFound
 	}
 
 	var extraQuery string
@@ -205,7 +205,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 
 	args = append(args, limit)
 
-	rows, err := md.DB.Query(
+	rows, err := md.DB.QueryContext(md.Context,
 		fmt.Sprintf(
 			`SELECT id, game_id, user_id, event_type, timestamp
 			FROM match_events WHERE match_id = ? %s ORDER BY id %s LIMIT ?`,
@@ -234,7 +234,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 
 		if userId != nil {
 			me.User = &MatchUser{}
-			err = md.DB.QueryRow(
+			err = md.DB.QueryRowContext(md.Context,
 				"SELECT id, username FROM users WHERE id = ? LIMIT 1",
 				userId,
 			).Scan(&me.User.Id, &me.User.Username)
@@ -248,7 +248,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 		if gameId != nil {
 			me.Game = &MatchGame{}
 			var songName string
-			err = md.DB.QueryRow(
+			err = md.DB.QueryRowContext(md.Context,
 				`SELECT g.id, g.mode, g.mods, g.scoring_type, g.team_type,
 				g.start_time, g.end_time, b.beatmap_id, b.beatmapset_id, b.song_name
 				FROM match_games g
@@ -277,7 +277,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 			}
 
 			var winningTeam int
-			err = md.DB.Get(&winningTeam, fmt.Sprintf(
+			err = md.DB.GetContext(md.Context, &winningTeam, fmt.Sprintf(
 				`SELECT team FROM match_game_scores WHERE game_id = ?
 					GROUP BY team ORDER BY %s(%s) DESC LIMIT 1`,
 				mysqlFunc, scoringTypeMap[me.Game.ScoringType]),
@@ -294,7 +294,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 				sortOrder = "DESC"
 			}
 
-			scoreRows, err := md.DB.Query(fmt.Sprintf(
+			scoreRows, err := md.DB.QueryContext(md.Context, fmt.Sprintf(
 				`SELECT u.id, u.username, u.country, s.id, s.count_300, s.count_100, s.count_50,
 					s.count_geki, s.count_katu, s.count_miss, s.score, s.accuracy, s.max_combo,
 					s.mods, s.mode, s.passed, s.team, s.timestamp
@@ -339,7 +339,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 		}
 	}
 
-	err = md.DB.QueryRow(
+	err = md.DB.QueryRowContext(md.Context,
 		`SELECT MIN(id) first_event_id, MAX(id) latest_event_id FROM match_events WHERE match_id = ?`,
 		r.Match.Id,
 	).Scan(&r.FirstEventId, &r.LatestEventId)
@@ -349,7 +349,7 @@ func MatchGET(md common.MethodData) common.CodeMessager {
 		return Err500
 	}
 
-	err = md.DB.Get(
+	err = md.DB.GetContext(md.Context,
 		&r.CurrentGameId,
 		`SELECT id FROM match_games WHERE match_id = ? AND end_time IS NULL ORDER BY id DESC LIMIT 1`,
 		r.Match.Id,
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// startTime is when the API was started, to report its uptime.
+var startTime = time.Now()
+
+type memStats struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"pause_total_ns"`
+}
+
+type metaResponse struct {
+	common.ResponseBase
+	Version    string               `json:"version"`
+	GoVersion  string               `json:"go_version"`
+	StartedAt  common.UnixTimestamp `json:"started_at"`
+	Uptime     int64                `json:"uptime"`
+	Goroutines int                  `json:"goroutines"`
+	Memory     memStats             `json:"memory"`
+}
+
+// MetaGET reports the version of the API, how long it has been running for,
+// and its goroutines and memory usage.
+func MetaGET(md common.MethodData) common.CodeMessager {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	r := metaResponse{
+		Version:    common.Version,
+		GoVersion:  runtime.Version(),
+		StartedAt:  common.UnixTimestamp(startTime),
+		Uptime:     int64(time.Since(startTime) / time.Second),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: memStats{
+			Alloc:        ms.Alloc,
+			TotalAlloc:   ms.TotalAlloc,
+			Sys:          ms.Sys,
+			HeapAlloc:    ms.HeapAlloc,
+			HeapInuse:    ms.HeapInuse,
+			HeapObjects:  ms.HeapObjects,
+			NumGC:        ms.NumGC,
+			PauseTotalNs: ms.PauseTotalNs,
+		},
+	}
+	md.Audit("meta", "status", nil, nil)
+	r.Code = 200
+	return r
+}
+
+type metaAchievementsResponse struct {
+	common.ResponseBase
+	Achievements int `json:"achievements"`
+}
+
+// MetaAchievementsReloadPOST reloads the achievements from the database,
+// without waiting for LoadAchievementsEvery to do it.
+func MetaAchievementsReloadPOST(md common.MethodData) common.CodeMessager {
+	before := len(achievements())
+	n, err := loadAchievements(md.Context, md.DB)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("meta", "achievements", map[string]int{"achievements": before}, map[string]int{"achievements": n})
+	r := metaAchievementsResponse{Achievements: n}
+	r.Code = 200
+	return r
+}
+
+type metaCacheFlushData struct {
+	// Tags are the cache tags to invalidate. If empty, the whole response
+	// cache is flushed.
+	Tags []string `json:"tags"`
+}
+
+type metaCacheFlushResponse struct {
+	common.ResponseBase
+	// Keys is the number of keys deleted, when flushing the whole cache.
+	Keys int `json:"keys"`
+}
+
+// MetaCacheFlushPOST removes the responses tagged with any of the given tags
+// from the response cache, or all of them if no tag is given.
+func MetaCacheFlushPOST(md common.MethodData) common.CodeMessager {
+	var d metaCacheFlushData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	var r metaCacheFlushResponse
+	if len(d.Tags) > 0 {
+		if err := common.InvalidateCache(md.R, d.Tags...); err != nil {
+			md.Err(err)
+			return Err500
+		}
+	} else {
+		n, err := common.FlushCache(md.R)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		r.Keys = n
+	}
+	md.Audit("meta", "cache", nil, d)
+	r.Code = 200
+	return r
+}
+
+type metaMaintenanceData struct {
+	// Enabled is whether to enable maintenance mode. If null, maintenance
+	// mode is toggled.
+	Enabled *bool `json:"enabled"`
+}
+
+type metaMaintenanceResponse struct {
+	common.ResponseBase
+	Enabled bool `json:"enabled"`
+}
+
+// MetaMaintenancePOST turns maintenance mode on or off.
+func MetaMaintenancePOST(md common.MethodData) common.CodeMessager {
+	var d metaMaintenanceData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	before, after, err := common.UpdateSystemSettings(md.Context, md.DB, func(s *common.SystemSettings) {
+		s.Maintenance = !s.Maintenance
+		if d.Enabled != nil {
+			s.Maintenance = *d.Enabled
+		}
+	})
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("meta", "maintenance", map[string]bool{"enabled": before.Maintenance}, map[string]bool{"enabled": after.Maintenance})
+	r := metaMaintenanceResponse{Enabled: after.Maintenance}
+	r.Code = 200
+	return r
+}
@@ -0,0 +1,898 @@
+package v1
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+// The OAuth 2 authorization server is backed by the osin tables:
+//
+//	osin_client(id, secret, extra, redirect_uri)
+//	osin_authorize(client, code, expires_in, scope, redirect_uri, state,
+//		extra, created_at, code_challenge, code_challenge_method)
+//	osin_access(client, authorize, previous, access_token, refresh_token,
+//		expires_in, scope, redirect_uri, extra, created_at, last_used)
+//	osin_refresh(token, access)
+//
+// The code_challenge, code_challenge_method and last_used columns are not part
+// of the osin schema, and are added by migrations/002_osin_authorize_pkce.sql
+// and migrations/003_osin_access_last_used.sql.
+// Client secrets, authorization codes, access tokens and refresh tokens are
+// only ever stored as their sha256 hash; client secrets stored in plain text
+// are hashed by migrations/008_osin_client_hash_secrets.sql, or by
+// checkOAuthClientSecret. The extra column of osin_client holds
+// a JSON array of [name, owner ID, avatar], while the extra column of
+// osin_authorize and osin_access holds the ID of the user who granted access.
+const (
+	oauthCodeLifetime   = 10 * 60
+	oauthAccessLifetime = 24 * 60 * 60
+	maxOAuthClients     = 10
+)
+
+// oauthError is an error response as defined by RFC 6749, section 5.2.
+type oauthError struct {
+	code        int
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e oauthError) GetCode() int {
+	return e.code
+}
+
+func (e oauthError) GetMessage() string {
+	return e.Description
+}
+
+func newOAuthError(code int, err, description string) common.CodeMessager {
+	return oauthError{code: code, Error: err, Description: description}
+}
+
+var errOAuthServer = newOAuthError(500, "server_error", "An unexpected error occurred.")
+
+// oauthTokenResponse is the response of the token endpoint, as defined by
+// RFC 6749, section 5.1.
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+func (oauthTokenResponse) GetCode() int {
+	return 200
+}
+
+func (oauthTokenResponse) GetMessage() string {
+	return ""
+}
+
+func hashOAuthSecret(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// normaliseScope removes duplicates from a space-separated list of scopes,
+// and returns false if any of them is unknown.
+func normaliseScope(scope string) (string, bool) {
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Fields(scope) {
+		if !common.OAuthScopeKnown(s) {
+			return "", false
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " "), true
+}
+
+type oauthClientDB struct {
+	ID          string
+	Secret      string
+	RedirectURI string
+	oauthClient
+}
+
+func getOAuthClient(md common.MethodData, id string) (oauthClientDB, error) {
+	var c oauthClientDB
+	err := md.DB.QueryRowContext(md.Context, "SELECT id, secret, redirect_uri, extra FROM osin_client WHERE id = ? LIMIT 1", id).
+		Scan(&c.ID, &c.Secret, &c.RedirectURI, &c.oauthClient)
+	c.oauthClient.ID = c.ID
+	return c, err
+}
+
+// privateUseScheme matches the private-use URI schemes of native apps, which
+// are named after a reverse domain name (RFC 8252, section 7.1).
+var privateUseScheme = regexp.MustCompile(`^[a-z][a-z0-9-]*(\.[a-z0-9-]+)+$`)
+
+// validRedirectURI checks the redirect_uri of an OAuth application. Only
+// https URIs are allowed, plus the ones used by native apps: http on the
+// loopback interface, and private-use schemes such as com.example.app:/cb.
+// Anything else, such as javascript: or data: URIs, could be abused once the
+// user is redirected to it.
+func validRedirectURI(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Fragment != "" || u.Opaque != "" || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		return privateUseScheme.MatchString(u.Scheme)
+	}
+}
+
+// isPublic tells whether the client has no secret, and must thus use PKCE.
+func (c oauthClientDB) isPublic() bool {
+	return c.Secret == ""
+}
+
+// postArg retrieves a parameter from an application/x-www-form-urlencoded body.
+func postArg(md common.MethodData, key string) string {
+	return string(md.Ctx.PostArgs().Peek(key))
+}
+
+// authenticateOAuthClient authenticates the client making a request to the
+// token, revocation or introspection endpoints, using either HTTP Basic
+// authentication or the client_id and client_secret parameters.
+// Public clients are authenticated by their ID alone.
+func authenticateOAuthClient(md common.MethodData) (oauthClientDB, common.CodeMessager) {
+	id, secret := postArg(md, "client_id"), postArg(md, "client_secret")
+	if auth := string(md.Ctx.Request.Header.Peek("Authorization")); strings.HasPrefix(auth, "Basic ") {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			return oauthClientDB{}, newOAuthError(401, "invalid_client", "Malformed Authorization header.")
+		}
+		parts := strings.SplitN(string(raw), ":", 2)
+		if len(parts) != 2 {
+			return oauthClientDB{}, newOAuthError(401, "invalid_client", "Malformed Authorization header.")
+		}
+		id, _ = url.QueryUnescape(parts[0])
+		secret, _ = url.QueryUnescape(parts[1])
+	}
+	if id == "" {
+		return oauthClientDB{}, newOAuthError(401, "invalid_client", "Client authentication is required.")
+	}
+
+	c, err := getOAuthClient(md, id)
+	switch {
+	case err == sql.ErrNoRows:
+		return c, newOAuthError(401, "invalid_client", "Unknown client.")
+	case err != nil:
+		md.Err(err)
+		return c, errOAuthServer
+	}
+	if c.isPublic() {
+		return c, nil
+	}
+	if !checkOAuthClientSecret(md, c, secret) {
+		return c, newOAuthError(401, "invalid_client", "Invalid client credentials.")
+	}
+	return c, nil
+}
+
+// oauthSecretHash matches a secret hashed by hashOAuthSecret.
+var oauthSecretHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// checkOAuthClientSecret checks the secret given by a confidential client.
+// Clients registered before secrets were hashed have their secret stored in
+// plain text: it is accepted, and replaced with its hash.
+func checkOAuthClientSecret(md common.MethodData, c oauthClientDB, secret string) bool {
+	if oauthSecretHash.MatchString(c.Secret) {
+		return subtle.ConstantTimeCompare([]byte(c.Secret), []byte(hashOAuthSecret(secret))) == 1
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Secret), []byte(secret)) != 1 {
+		return false
+	}
+	_, err := md.DB.ExecContext(md.Context, "UPDATE osin_client SET secret = ? WHERE id = ? AND secret = ?",
+		hashOAuthSecret(secret), c.ID, c.Secret)
+	if err != nil {
+		md.Err(err)
+	}
+	return true
+}
+
+type oauthAuthorizeData struct {
+	ClientID            string `json:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri"`
+	ResponseType        string `json:"response_type"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             bool   `json:"approve"`
+}
+
+type oauthAuthorizeInfoResponse struct {
+	common.ResponseBase
+	Client      oauthClient        `json:"client"`
+	RedirectURI string             `json:"redirect_uri"`
+	Scopes      []common.ScopeInfo `json:"scopes"`
+}
+
+// validateAuthorizeRequest checks an authorization request, and returns the
+// client it is for. The redirect URI and scope of d are normalised.
+func validateAuthorizeRequest(md common.MethodData, d *oauthAuthorizeData) (oauthClientDB, common.CodeMessager) {
+	if md.IsBearer() {
+		return oauthClientDB{}, common.ErrOAuthTokenNotAllowed
+	}
+	if d.ClientID == "" {
+		return oauthClientDB{}, ErrMissingField("client_id")
+	}
+	c, err := getOAuthClient(md, d.ClientID)
+	switch {
+	case err == sql.ErrNoRows:
+		return c, common.ErrOAuthClientNotFound
+	case err != nil:
+		md.Err(err)
+		return c, Err500
+	}
+	if d.RedirectURI == "" {
+		d.RedirectURI = c.RedirectURI
+	}
+	if d.RedirectURI != c.RedirectURI {
+		return c, common.ErrRedirectURIMismatch
+	}
+	if !validRedirectURI(d.RedirectURI) {
+		// registered before redirect URIs were checked.
+		return c, common.ErrInvalidRedirectURI.WithMessage("The redirect_uri of the application is invalid.")
+	}
+	if d.ResponseType != "code" {
+		return c, common.ErrUnsupportedResponseType
+	}
+	scope, ok := normaliseScope(d.Scope)
+	if !ok {
+		return c, common.ErrUnknownScope
+	}
+	d.Scope = scope
+	switch d.CodeChallengeMethod {
+	case "":
+		if d.CodeChallenge != "" {
+			d.CodeChallengeMethod = "plain"
+		}
+	case "plain", "S256":
+	default:
+		return c, common.ErrUnsupportedChallengeMethod
+	}
+	if c.isPublic() && d.CodeChallenge == "" {
+		return c, common.ErrPKCERequired
+	}
+	return c, nil
+}
+
+// OAuthAuthorizeGET validates an authorization request, and returns what the
+// website needs to show the user the consent screen.
+func OAuthAuthorizeGET(md common.MethodData) common.CodeMessager {
+	d := oauthAuthorizeData{
+		ClientID:            md.Query("client_id"),
+		RedirectURI:         md.Query("redirect_uri"),
+		ResponseType:        md.Query("response_type"),
+		Scope:               md.Query("scope"),
+		CodeChallenge:       md.Query("code_challenge"),
+		CodeChallengeMethod: md.Query("code_challenge_method"),
+	}
+	c, errResp := validateAuthorizeRequest(md, &d)
+	if errResp != nil {
+		return errResp
+	}
+	r := oauthAuthorizeInfoResponse{
+		Client:      c.oauthClient,
+		RedirectURI: d.RedirectURI,
+		Scopes:      []common.ScopeInfo{},
+	}
+	for _, s := range strings.Fields(d.Scope) {
+		info, _ := common.LookupScope(common.Scope(s))
+		r.Scopes = append(r.Scopes, info)
+	}
+	r.Code = 200
+	return r
+}
+
+type oauthAuthorizeResponse struct {
+	common.ResponseBase
+	Redirect string `json:"redirect"`
+}
+
+// OAuthAuthorizePOST is called by the website once the user has accepted or
+// denied an authorization request. It returns the URL the user must be
+// redirected to, which carries the authorization code if access was granted.
+func OAuthAuthorizePOST(md common.MethodData) common.CodeMessager {
+	var d oauthAuthorizeData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	_, errResp := validateAuthorizeRequest(md, &d)
+	if errResp != nil {
+		return errResp
+	}
+
+	redirect, err := url.Parse(d.RedirectURI)
+	if err != nil {
+		return common.ErrInvalidRedirectURI.WithMessage("The redirect_uri of the application is invalid.")
+	}
+	q := redirect.Query()
+	if d.State != "" {
+		q.Set("state", d.State)
+	}
+
+	if !d.Approve {
+		q.Set("error", "access_denied")
+	} else {
+		code, err := randomHex(20)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		_, err = md.DB.ExecContext(md.Context, `INSERT INTO osin_authorize(client, code, expires_in, scope, redirect_uri,
+			state, extra, created_at, code_challenge, code_challenge_method)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), ?, ?)`,
+			d.ClientID, hashOAuthSecret(code), oauthCodeLifetime, d.Scope, d.RedirectURI,
+			d.State, strconv.Itoa(md.ID()), d.CodeChallenge, d.CodeChallengeMethod)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		q.Set("code", code)
+		md.Audit("oauth_client", d.ClientID, nil, map[string]interface{}{"authorized_scope": d.Scope})
+	}
+	redirect.RawQuery = q.Encode()
+
+	var r oauthAuthorizeResponse
+	r.Redirect = redirect.String()
+	r.Code = 200
+	return r
+}
+
+// verifyCodeChallenge checks a PKCE code_verifier (RFC 7636).
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	if method == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+// OAuthTokenPOST is the token endpoint (RFC 6749, section 3.2), supporting the
+// authorization_code and refresh_token grants.
+func OAuthTokenPOST(md common.MethodData) common.CodeMessager {
+	md.Ctx.Response.Header.Set("Cache-Control", "no-store")
+	md.Ctx.Response.Header.Set("Pragma", "no-cache")
+
+	c, errResp := authenticateOAuthClient(md)
+	if errResp != nil {
+		return errResp
+	}
+	switch postArg(md, "grant_type") {
+	case "authorization_code":
+		return oauthAuthorizationCodeGrant(md, c)
+	case "refresh_token":
+		return oauthRefreshTokenGrant(md, c)
+	case "":
+		return newOAuthError(400, "invalid_request", "Missing grant_type.")
+	default:
+		return newOAuthError(400, "unsupported_grant_type", "")
+	}
+}
+
+func oauthAuthorizationCodeGrant(md common.MethodData, c oauthClientDB) common.CodeMessager {
+	code := postArg(md, "code")
+	if code == "" {
+		return newOAuthError(400, "invalid_request", "Missing code.")
+	}
+
+	var a struct {
+		RedirectURI         string
+		Scope               string
+		Extra               string
+		CodeChallenge       string
+		CodeChallengeMethod string
+	}
+	err := md.DB.QueryRowContext(md.Context, `SELECT redirect_uri, scope, extra, code_challenge, code_challenge_method
+		FROM osin_authorize WHERE code = ? AND client = ?
+		AND created_at + INTERVAL expires_in SECOND > NOW() LIMIT 1`,
+		hashOAuthSecret(code), c.ID).
+		Scan(&a.RedirectURI, &a.Scope, &a.Extra, &a.CodeChallenge, &a.CodeChallengeMethod)
+	switch {
+	case err == sql.ErrNoRows:
+		return newOAuthError(400, "invalid_grant", "The authorization code is invalid or has expired.")
+	case err != nil:
+		md.Err(err)
+		return errOAuthServer
+	}
+
+	// codes can only be used once: of concurrent requests redeeming the same
+	// code, only the one which deletes it gets a token.
+	res, err := md.DB.ExecContext(md.Context, "DELETE FROM osin_authorize WHERE code = ? AND client = ?", hashOAuthSecret(code), c.ID)
+	if err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return newOAuthError(400, "invalid_grant", "The authorization code is invalid or has expired.")
+	}
+
+	if ru := postArg(md, "redirect_uri"); ru != "" && ru != a.RedirectURI {
+		return newOAuthError(400, "invalid_grant", "The redirect_uri does not match.")
+	}
+	if !verifyCodeChallenge(a.CodeChallenge, a.CodeChallengeMethod, postArg(md, "code_verifier")) {
+		return newOAuthError(400, "invalid_grant", "Invalid code_verifier.")
+	}
+
+	return issueOAuthTokens(md, c.ID, hashOAuthSecret(code), "", a.Scope, a.RedirectURI, a.Extra)
+}
+
+func oauthRefreshTokenGrant(md common.MethodData, c oauthClientDB) common.CodeMessager {
+	refresh := postArg(md, "refresh_token")
+	if refresh == "" {
+		return newOAuthError(400, "invalid_request", "Missing refresh_token.")
+	}
+
+	var a struct {
+		AccessToken string
+		Scope       string
+		RedirectURI string
+		Extra       string
+	}
+	err := md.DB.QueryRowContext(md.Context, `SELECT a.access_token, a.scope, a.redirect_uri, a.extra
+		FROM osin_refresh r INNER JOIN osin_access a ON a.access_token = r.access
+		WHERE r.token = ? AND a.client = ? LIMIT 1`,
+		hashOAuthSecret(refresh), c.ID).
+		Scan(&a.AccessToken, &a.Scope, &a.RedirectURI, &a.Extra)
+	switch {
+	case err == sql.ErrNoRows:
+		return newOAuthError(400, "invalid_grant", "The refresh token is invalid.")
+	case err != nil:
+		md.Err(err)
+		return errOAuthServer
+	}
+
+	// a narrower scope may be requested when refreshing.
+	scope := a.Scope
+	if s := postArg(md, "scope"); s != "" {
+		granted := strings.Fields(a.Scope)
+		for _, x := range strings.Fields(s) {
+			if !containsString(granted, x) {
+				return newOAuthError(400, "invalid_scope", "The requested scope exceeds the one originally granted.")
+			}
+		}
+		scope, _ = normaliseScope(s)
+	}
+
+	// refresh tokens are rotated: the old pair stops working. Of concurrent
+	// requests using the same refresh token, only the one which deletes it
+	// gets new tokens.
+	n, err := deleteOAuthAccess(md, "a.access_token = ?", a.AccessToken)
+	if err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+	if n != 1 {
+		return newOAuthError(400, "invalid_grant", "The refresh token is invalid.")
+	}
+	return issueOAuthTokens(md, c.ID, "", a.AccessToken, scope, a.RedirectURI, a.Extra)
+}
+
+func containsString(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// issueOAuthTokens creates a new access token and refresh token.
+func issueOAuthTokens(md common.MethodData, client, authorize, previous, scope, redirectURI, userID string) common.CodeMessager {
+	access, err := randomHex(32)
+	if err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+	refresh, err := randomHex(32)
+	if err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+
+	tx, err := md.DB.BeginTx(md.Context, nil)
+	if err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+	_, err = tx.ExecContext(md.Context, `INSERT INTO osin_access(client, authorize, previous, access_token, refresh_token,
+		expires_in, scope, redirect_uri, extra, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())`,
+		client, authorize, previous, hashOAuthSecret(access), hashOAuthSecret(refresh),
+		oauthAccessLifetime, scope, redirectURI, userID)
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return errOAuthServer
+	}
+	_, err = tx.ExecContext(md.Context, "INSERT INTO osin_refresh(token, access) VALUES (?, ?)",
+		hashOAuthSecret(refresh), hashOAuthSecret(access))
+	if err != nil {
+		tx.Rollback()
+		md.Err(err)
+		return errOAuthServer
+	}
+	if err := tx.Commit(); err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+
+	return oauthTokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    oauthAccessLifetime,
+		RefreshToken: refresh,
+		Scope:        scope,
+	}
+}
+
+// deleteOAuthAccess deletes the access tokens of osin_access a matching the
+// where clause, together with their refresh tokens, and drops them from the
+// token caches. It returns the number of access tokens deleted.
+func deleteOAuthAccess(md common.MethodData, where string, params ...interface{}) (int64, error) {
+	var hashes []string
+	err := md.DB.SelectContext(md.Context, &hashes, "SELECT a.access_token FROM osin_access a WHERE "+where, params...)
+	if err != nil {
+		return 0, err
+	}
+	_, err = md.DB.ExecContext(md.Context, `DELETE r FROM osin_refresh r
+		INNER JOIN osin_access a ON a.access_token = r.access WHERE `+where, params...)
+	if err != nil {
+		return 0, err
+	}
+	res, err := md.DB.ExecContext(md.Context, "DELETE a FROM osin_access a WHERE "+where, params...)
+	if err != nil {
+		return 0, err
+	}
+	for _, h := range hashes {
+		md.InvalidateBearerToken(h)
+	}
+	return res.RowsAffected()
+}
+
+// OAuthRevokePOST revokes an access or refresh token (RFC 7009).
+func OAuthRevokePOST(md common.MethodData) common.CodeMessager {
+	c, errResp := authenticateOAuthClient(md)
+	if errResp != nil {
+		return errResp
+	}
+	token := postArg(md, "token")
+	if token == "" {
+		return newOAuthError(400, "invalid_request", "Missing token.")
+	}
+	h := hashOAuthSecret(token)
+	_, err := deleteOAuthAccess(md, "a.client = ? AND (a.access_token = ? OR a.refresh_token = ?)", c.ID, h, h)
+	if err != nil {
+		md.Err(err)
+		return errOAuthServer
+	}
+	// As per the RFC, the response is the same whether the token existed or not.
+	return common.SimpleResponse(200, "")
+}
+
+type oauthIntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+}
+
+func (oauthIntrospectResponse) GetCode() int {
+	return 200
+}
+
+func (oauthIntrospectResponse) GetMessage() string {
+	return ""
+}
+
+// OAuthIntrospectPOST tells the calling client whether an access token is
+// active, and what it grants (RFC 7662). Clients can only introspect the
+// tokens issued to them: any other token is reported as inactive. Public
+// clients can't use it, as anyone can authenticate as them.
+func OAuthIntrospectPOST(md common.MethodData) common.CodeMessager {
+	c, errResp := authenticateOAuthClient(md)
+	if errResp != nil {
+		return errResp
+	}
+	if c.isPublic() {
+		return newOAuthError(401, "invalid_client", "Public clients can't introspect tokens.")
+	}
+	token := postArg(md, "token")
+	if token == "" {
+		return newOAuthError(400, "invalid_request", "Missing token.")
+	}
+
+	var (
+		r         oauthIntrospectResponse
+		createdAt time.Time
+		expiresIn int64
+	)
+	err := md.DB.QueryRowContext(md.Context, `SELECT a.scope, a.client, a.extra, u.username, a.created_at, a.expires_in
+		FROM osin_access a INNER JOIN users u ON u.id = a.extra
+		WHERE a.access_token = ? AND a.client = ? AND a.created_at + INTERVAL a.expires_in SECOND > NOW()
+		AND u.privileges & 1 LIMIT 1`, hashOAuthSecret(token), c.ID).
+		Scan(&r.Scope, &r.ClientID, &r.Sub, &r.Username, &createdAt, &expiresIn)
+	switch {
+	case err == sql.ErrNoRows:
+		return oauthIntrospectResponse{}
+	case err != nil:
+		md.Err(err)
+		return errOAuthServer
+	}
+	r.Active = true
+	r.TokenType = "Bearer"
+	r.Iat = createdAt.Unix()
+	r.Exp = r.Iat + expiresIn
+	return r
+}
+
+type oauthScopesResponse struct {
+	common.ResponseBase
+	Scopes []common.ScopeInfo `json:"scopes"`
+}
+
+// OAuthScopesGET lists all the scopes OAuth clients can request, with a
+// description of what they grant.
+func OAuthScopesGET(md common.MethodData) common.CodeMessager {
+	r := oauthScopesResponse{Scopes: common.Scopes()}
+	r.Code = 200
+	return r
+}
+
+type oauthClientData struct {
+	Name        string `json:"name" validate:"required,max=64"`
+	RedirectURI string `json:"redirect_uri" validate:"required"`
+	Avatar      string `json:"avatar"`
+	Public      bool   `json:"public"`
+}
+
+type oauthClientCreatedResponse struct {
+	common.ResponseBase
+	Client      oauthClient `json:"client"`
+	RedirectURI string      `json:"redirect_uri"`
+	Secret      string      `json:"secret,omitempty"`
+}
+
+// OAuthClientNewPOST registers a new OAuth application owned by the user.
+// The client secret is only ever returned by this call.
+func OAuthClientNewPOST(md common.MethodData) common.CodeMessager {
+	var d oauthClientData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return ErrMissingField("name")
+	}
+	if !validRedirectURI(d.RedirectURI) {
+		return common.ErrInvalidRedirectURI
+	}
+
+	var count int
+	err := md.DB.QueryRowContext(md.Context, "SELECT COUNT(*) FROM osin_client WHERE JSON_UNQUOTE(JSON_EXTRACT(extra, '$[1]')) = ?",
+		strconv.Itoa(md.ID())).Scan(&count)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if count >= maxOAuthClients {
+		return common.ErrTooManyOAuthClients
+	}
+
+	var r oauthClientCreatedResponse
+	id, err := randomHex(16)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	var secretHash string
+	if !d.Public {
+		r.Secret, err = randomHex(32)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		secretHash = hashOAuthSecret(r.Secret)
+	}
+	extra, err := json.Marshal([3]string{d.Name, strconv.Itoa(md.ID()), d.Avatar})
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	_, err = md.DB.ExecContext(md.Context, "INSERT INTO osin_client(id, secret, extra, redirect_uri) VALUES (?, ?, ?, ?)",
+		id, secretHash, string(extra), d.RedirectURI)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+
+	r.Client = oauthClient{ID: id, Name: d.Name, OwnerID: md.ID(), Avatar: d.Avatar}
+	r.RedirectURI = d.RedirectURI
+	md.Audit("oauth_client", id, nil, ownOAuthClient{r.Client, r.RedirectURI, d.Public})
+	r.Code = 200
+	return r
+}
+
+type ownOAuthClient struct {
+	oauthClient
+	RedirectURI string `json:"redirect_uri"`
+	Public      bool   `json:"public"`
+}
+
+type oauthClientsResponse struct {
+	common.ResponseBase
+	Clients []ownOAuthClient `json:"clients"`
+}
+
+// OAuthClientsGET lists the OAuth applications owned by the user.
+func OAuthClientsGET(md common.MethodData) common.CodeMessager {
+	rows, err := md.DB.QueryContext(md.Context, `SELECT id, secret, redirect_uri, extra FROM osin_client
+		WHERE JSON_UNQUOTE(JSON_EXTRACT(extra, '$[1]')) = ?`, strconv.Itoa(md.ID()))
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+	r := oauthClientsResponse{Clients: []ownOAuthClient{}}
+	for rows.Next() {
+		var c oauthClientDB
+		if err := rows.Scan(&c.ID, &c.Secret, &c.RedirectURI, &c.oauthClient); err != nil {
+			md.Err(err)
+			continue
+		}
+		c.oauthClient.ID = c.ID
+		r.Clients = append(r.Clients, ownOAuthClient{c.oauthClient, c.RedirectURI, c.isPublic()})
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+	}
+	r.Code = 200
+	return r
+}
+
+type oauthClientDeleteData struct {
+	ID string `json:"id" validate:"required"`
+}
+
+// OAuthClientDeletePOST deletes an OAuth application owned by the user, and
+// everything that was granted to it.
+func OAuthClientDeletePOST(md common.MethodData) common.CodeMessager {
+	var d oauthClientDeleteData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	c, err := getOAuthClient(md, d.ID)
+	if err == sql.ErrNoRows || (err == nil && c.OwnerID != md.ID()) {
+		return common.ErrOAuthClientNotFound
+	}
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+
+	if _, err := deleteOAuthAccess(md, "a.client = ?", c.ID); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if _, err := md.DB.ExecContext(md.Context, "DELETE FROM osin_authorize WHERE client = ?", c.ID); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if _, err := md.DB.ExecContext(md.Context, "DELETE FROM osin_client WHERE id = ?", c.ID); err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("oauth_client", c.ID, ownOAuthClient{c.oauthClient, c.RedirectURI, c.isPublic()}, nil)
+	return common.SimpleResponse(200, "The application has been deleted.")
+}
+
+type oauthConsent struct {
+	Client    oauthClient `json:"client"`
+	Scope     string      `json:"scope"`
+	GrantedAt time.Time   `json:"granted_at"`
+}
+
+type oauthConsentsResponse struct {
+	common.ResponseBase
+	Consents []oauthConsent `json:"consents"`
+}
+
+// TokenSelfConsentsGET lists the OAuth applications the user has granted
+// access to their account.
+func TokenSelfConsentsGET(md common.MethodData) common.CodeMessager {
+	rows, err := md.DB.QueryContext(md.Context, `SELECT c.id, c.extra, a.scope, a.created_at
+		FROM osin_access a INNER JOIN osin_client c ON c.id = a.client
+		WHERE a.extra = ? ORDER BY a.created_at DESC`, strconv.Itoa(md.ID()))
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	defer rows.Close()
+	r := oauthConsentsResponse{Consents: []oauthConsent{}}
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var c oauthConsent
+		if err := rows.Scan(&c.Client.ID, &c.Client, &c.Scope, &c.GrantedAt); err != nil {
+			md.Err(err)
+			continue
+		}
+		// only show the most recent grant of each application.
+		if seen[c.Client.ID] {
+			continue
+		}
+		seen[c.Client.ID] = true
+		r.Consents = append(r.Consents, c)
+	}
+	if err := rows.Err(); err != nil {
+		md.Err(err)
+	}
+	r.Code = 200
+	return r
+}
+
+type oauthConsentRevokeData struct {
+	ClientID string `json:"client_id" validate:"required"`
+}
+
+// TokenSelfConsentsRevokePOST revokes all the access an OAuth application has
+// to the user's account.
+func TokenSelfConsentsRevokePOST(md common.MethodData) common.CodeMessager {
+	var d oauthConsentRevokeData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	_, err := deleteOAuthAccess(md, "a.client = ? AND a.extra = ?", d.ClientID, strconv.Itoa(md.ID()))
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("oauth_client", d.ClientID, nil, map[string]interface{}{"consent_revoked": true})
+	return common.SimpleResponse(200, "The application can no longer access your account.")
+}
@@ -99,6 +99,7 @@ type pingResponse struct {
 	UserPrivileges  common.UserPrivileges `json:"user_privileges"`
 	PrivilegesS     string                `json:"privileges_string"`
 	UserPrivilegesS string                `json:"user_privileges_string"`
+	Scopes          []common.Scope        `json:"scopes"`
 }
 
 // PingGET is a message to check with the API that we are logged in, and know what are our privileges.
@@ -117,6 +118,7 @@ func PingGET(md common.MethodData) common.CodeMessager {
 	r.UserPrivileges = md.User.UserPrivileges
 	r.PrivilegesS = md.User.TokenPrivileges.String()
 	r.UserPrivilegesS = md.User.UserPrivileges.String()
+	r.Scopes = md.User.GrantedScopes()
 
 	return r
 }
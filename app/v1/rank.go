@@ -16,19 +16,19 @@ type hypotheticalRankResponse struct {
 func HypotheticalRankGET(md common.MethodData) common.CodeMessager {
 	modeInt, err := strconv.Atoi(md.Query("mode"))
 	if err != nil || modeInt > 3 || modeInt < 0 {
-		return common.SimpleResponse(400, "invalid mode")
+		return common.ErrInvalidMode
 	}
 
 	mode := modesToReadable[modeInt]
 
 	rx, err := strconv.Atoi(md.Query("rx"))
 	if err != nil || rx > 2 || rx < 0 {
-		return common.SimpleResponse(400, "invalid relax int")
+		return common.ErrInvalidRelax
 	}
 
 	performancePoints, err := strconv.Atoi(md.Query("pp"))
 	if err != nil || performancePoints < 0 {
-		return common.SimpleResponse(400, "invalid performance points")
+		return common.ErrInvalidPP
 	}
 
 	var rank int
@@ -43,7 +43,7 @@ func HypotheticalRankGET(md common.MethodData) common.CodeMessager {
 
 	if rankErr != nil {
 		md.Err(err)
-		return common.SimpleResponse(500, "failed to calculate hypothetical rank")
+		return common.ErrRankCalculation
 	}
 
 	resp := hypotheticalRankResponse{
@@ -177,7 +177,7 @@ func ScoreGET(md common.MethodData) common.CodeMessager {
 		u userData
 		b beatmap
 	)
-	row := md.DB.QueryRow(query, scoreId)
+	row := md.DB.QueryRowContext(md.Context, query, scoreId)
 	err := row.Scan(
 		&s.ID, &s.BeatmapMD5, &s.Score.Score,
 		&s.MaxCombo, &s.FullCombo, &s.Mods,
@@ -225,7 +225,7 @@ func ScoresGET(md common.MethodData) common.CodeMessager {
 	case md.Query("md5") != "":
 		beatmapMD5 = md.Query("md5")
 	case md.Query("b") != "":
-		err := md.DB.Get(&beatmapMD5, "SELECT beatmap_md5 FROM beatmaps WHERE beatmap_id = ? LIMIT 1", md.Query("b"))
+		err := md.DB.GetContext(md.Context, &beatmapMD5, "SELECT beatmap_md5 FROM beatmaps WHERE beatmap_id = ? LIMIT 1", md.Query("b"))
 		switch {
 		case err == sql.ErrNoRows:
 			r.Code = 200
@@ -268,7 +268,7 @@ func ScoresGET(md common.MethodData) common.CodeMessager {
 		mode = md.Query("m")
 	}
 
-	rows, err := md.DB.Query(queryDb+``+md.User.OnlyUserPublic(false)+
+	rows, err := md.DB.QueryContext(md.Context, queryDb+``+md.User.OnlyUserPublic(false)+
 		` `+mc+` `+sort+common.Paginate(md.Query("p"), md.Query("l"), 100), beatmapMD5, mode)
 	if err != nil {
 		md.Err(err)
@@ -17,7 +17,7 @@ type donorInfoResponse struct {
 func UsersSelfDonorInfoGET(md common.MethodData) common.CodeMessager {
 	var r donorInfoResponse
 	var privileges uint64
-	err := md.DB.QueryRow("SELECT privileges, donor_expire FROM users WHERE id = ?", md.ID()).
+	err := md.DB.QueryRowContext(md.Context, "SELECT privileges, donor_expire FROM users WHERE id = ?", md.ID()).
 		Scan(&privileges, &r.Expiration)
 	if err != nil {
 		md.Err(err)
@@ -41,7 +41,7 @@ func UsersSelfFavouriteModeGET(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
 		return f
 	}
-	err := md.DB.QueryRow("SELECT favourite_mode FROM users WHERE id = ?", md.ID()).
+	err := md.DB.QueryRowContext(md.Context, "SELECT favourite_mode FROM users WHERE id = ?", md.ID()).
 		Scan(&f.FavouriteMode)
 	if err != nil {
 		md.Err(err)
@@ -51,21 +51,23 @@ func UsersSelfFavouriteModeGET(md common.MethodData) common.CodeMessager {
 }
 
 type userSettingsData struct {
-	FavouriteMode *int `json:"favourite_mode"`
+	FavouriteMode *int `json:"favourite_mode" validate:"min=0,max=3"`
 	CustomBadge   struct {
 		singleBadge
 		Show *bool `json:"show"`
 	} `json:"custom_badge"`
-	PlayStyle             *int  `json:"play_style"`
+	PlayStyle             *int  `json:"play_style" validate:"min=0"`
 	VanillaPPLeaderboards *bool `json:"vanilla_pp_leaderboards"`
-	LeaderboardSize       *int  `json:"leaderboard_size"`
+	LeaderboardSize       *int  `json:"leaderboard_size" validate:"min=1"`
 	UserTitle             *string `json:"user_title"`
 }
 
 // UsersSelfSettingsPOST allows to modify information about the current user.
 func UsersSelfSettingsPOST(md common.MethodData) common.CodeMessager {
 	var d userSettingsData
-	md.Unmarshal(&d)
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
 
 	// input sanitisation
 	if md.User.UserPrivileges&common.UserPrivilegeDonor > 0 {
@@ -75,7 +77,6 @@ func UsersSelfSettingsPOST(md common.MethodData) common.CodeMessager {
 		d.CustomBadge.singleBadge = singleBadge{}
 		d.CustomBadge.Show = nil
 	}
-	d.FavouriteMode = intPtrIn(0, d.FavouriteMode, 3)
 
 	// Validate user title if provided
 	// Frontend always sends this field, either as "" (no title) or a title ID
@@ -85,7 +86,7 @@ func UsersSelfSettingsPOST(md common.MethodData) common.CodeMessager {
 	} else if d.UserTitle != nil {
 		// Non-empty title - validate it's in the eligible titles
 		var privileges uint64
-		err := md.DB.QueryRow("SELECT privileges FROM users WHERE id = ?", md.ID()).Scan(&privileges)
+		err := md.DB.QueryRowContext(md.Context, "SELECT privileges FROM users WHERE id = ?", md.ID()).Scan(&privileges)
 		if err != nil {
 			md.Err(err)
 			return Err500
@@ -109,7 +110,7 @@ func UsersSelfSettingsPOST(md common.MethodData) common.CodeMessager {
 		}
 
 		if !titleValid {
-			return common.SimpleResponse(400, "Invalid title selected")
+			return common.ErrInvalidTitle
 		}
 
 		// Use the normalized title ID (machine-readable)
@@ -125,11 +126,18 @@ func UsersSelfSettingsPOST(md common.MethodData) common.CodeMessager {
 		Add("vanilla_pp_leaderboards", d.VanillaPPLeaderboards).
 		Add("leaderboard_size", d.LeaderboardSize).
 		Add("user_title", d.UserTitle)
-	_, err := md.DB.Exec("UPDATE users SET "+q.Fields()+" WHERE id = ?", append(q.Parameters, md.ID())...)
+	before, err := auditColumns(md, "users", md.ID(), q.Columns())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET "+q.Fields()+" WHERE id = ?", append(q.Parameters, md.ID())...)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("user", md.ID(), before, q.Changes())
+	md.InvalidateCache("users")
 	return UsersSelfSettingsGET(md)
 }
 
@@ -164,7 +172,7 @@ func getEligibleTitles(md common.MethodData, userID int, privileges uint64) ([]e
 	userPrivs := common.UserPrivileges(privileges)
 
 	// Check badges first (they have higher priority)
-	rows, err := md.DB.Query("SELECT b.id FROM user_badges ub "+
+	rows, err := md.DB.QueryContext(md.Context, "SELECT b.id FROM user_badges ub "+
 		"INNER JOIN badges b ON ub.badge = b.id WHERE user = ?", userID)
 	if err != nil {
 		return nil, err
@@ -287,7 +295,7 @@ func UsersSelfSettingsGET(md common.MethodData) common.CodeMessager {
 	var privileges uint64
 	var userTitleID sql.NullString
 	r.Code = 200
-	err := md.DB.QueryRow(`
+	err := md.DB.QueryRowContext(md.Context, `
 SELECT
 	id, username,
 	email, favourite_mode,
@@ -343,16 +351,3 @@ WHERE id = ?`, md.ID()).Scan(
 
 	return r
 }
-
-func intPtrIn(x int, y *int, z int) *int {
-	if y == nil {
-		return nil
-	}
-	if *y > z {
-		return nil
-	}
-	if *y < x {
-		return nil
-	}
-	return y
-}
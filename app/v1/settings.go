@@ -0,0 +1,59 @@
+package v1
+
+import (
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+type systemSettingsResponse struct {
+	common.ResponseBase
+	Settings common.SystemSettings `json:"settings"`
+}
+
+// SystemSettingsGET returns the system settings, as they are in the
+// database.
+func SystemSettingsGET(md common.MethodData) common.CodeMessager {
+	s, err := common.LoadSystemSettings(md.Context, md.DB)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r := systemSettingsResponse{Settings: s}
+	r.Code = 200
+	return r
+}
+
+type systemSettingsData struct {
+	Maintenance          *bool   `json:"website_maintenance"`
+	MaintenanceScope     *string `json:"api_maintenance_scope" validate:"oneof=writes all"`
+	RegistrationsEnabled *bool   `json:"registrations_enabled"`
+}
+
+// SystemSettingsPOST changes the system settings. The fields left out are not
+// changed.
+func SystemSettingsPOST(md common.MethodData) common.CodeMessager {
+	var d systemSettingsData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	before, after, err := common.UpdateSystemSettings(md.Context, md.DB, func(s *common.SystemSettings) {
+		if d.Maintenance != nil {
+			s.Maintenance = *d.Maintenance
+		}
+		if d.MaintenanceScope != nil {
+			s.MaintenanceScope = *d.MaintenanceScope
+		}
+		if d.RegistrationsEnabled != nil {
+			s.RegistrationsEnabled = *d.RegistrationsEnabled
+		}
+	})
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if after != before {
+		md.Audit("settings", "system", before, after)
+	}
+	r := systemSettingsResponse{Settings: after}
+	r.Code = 200
+	return r
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+func TestSystemSettingsPOST(t *testing.T) {
+	md, mock := testMethodData(t, `{"website_maintenance": true, "registrations_enabled": false}`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name, value_int, value_string FROM system_settings FOR UPDATE")).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "value_int", "value_string"}).
+			AddRow("website_maintenance", 0, "").
+			AddRow("api_maintenance_scope", 0, "all"))
+	// only the changed settings are written, and the missing ones created.
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE system_settings SET value_int = ?, value_string = ? WHERE name = ?")).
+		WithArgs(1, "", "website_maintenance").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO system_settings(name, value_int, value_string) VALUES (?, ?, ?)")).
+		WithArgs("registrations_enabled", 0, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	resp := SystemSettingsPOST(md)
+	r, ok := resp.(systemSettingsResponse)
+	if !ok {
+		t.Fatalf("got %#v", resp)
+	}
+	want := common.SystemSettings{Maintenance: true, MaintenanceScope: common.MaintenanceAll}
+	if r.Settings != want {
+		t.Errorf("got settings %+v, want %+v", r.Settings, want)
+	}
+	if common.GetSystemSettings() != want {
+		t.Errorf("current settings are %+v, want %+v", common.GetSystemSettings(), want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetaMaintenancePOSTToggle(t *testing.T) {
+	md, mock := testMethodData(t, `{}`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "value_int", "value_string"}).
+			AddRow("website_maintenance", 1, ""))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE system_settings")).
+		WithArgs(0, "", "website_maintenance").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	resp := MetaMaintenancePOST(md)
+	if r, ok := resp.(metaMaintenanceResponse); !ok || r.Enabled {
+		t.Fatalf("got %#v, want maintenance mode to be turned off", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,67 +2,403 @@ package v1
 
 import (
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/osuAkatsuki/akatsuki-api/common"
+	"golang.org/x/crypto/bcrypt"
 )
 
+type tokenNewInData struct {
+	// either username or userid must be given in the request.
+	// if none is given, the request is trashed.
+	Username    string `json:"username"`
+	UserID      int    `json:"id"`
+	Password    string `json:"password" validate:"required"`
+	Privileges  uint64 `json:"privileges"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+type tokenNewResponse struct {
+	common.ResponseBase
+	Username   string            `json:"username"`
+	ID         int               `json:"id"`
+	Privileges common.Privileges `json:"privileges"`
+	Token      string            `json:"token,omitempty"`
+	Banned     bool              `json:"banned"`
+}
+
+// Login attempts are counted in redis, both for the IP making them and for
+// the account they target. Once either goes over its limit, any further
+// attempt is refused until the window expires. Successful attempts are not
+// counted against the IP, and reset the count of the account.
+const (
+	loginAttemptsWindow  = 15 * time.Minute
+	maxIPLoginAttempts   = 20
+	maxUserLoginAttempts = 10
+)
+
+func loginAttemptsKey(kind, v string) string {
+	return "api:login_attempts:" + kind + ":" + v
+}
+
+// countLoginAttempt counts an attempt against each of the keys, and reports
+// whether any of them went over its limit. The attempt is counted before the
+// credentials are checked, so that concurrent attempts can't get past the
+// limit. If redis is unavailable, the attempt is let through.
+func countLoginAttempt(md common.MethodData, limits map[string]int) bool {
+	limited := false
+	for key, max := range limits {
+		p := md.R.Pipeline()
+		n := p.Incr(key)
+		p.Expire(key, loginAttemptsWindow)
+		_, err := p.Exec()
+		p.Close()
+		if err != nil {
+			md.Err(err)
+			continue
+		}
+		if n.Val() > int64(max) {
+			limited = true
+		}
+	}
+	return limited
+}
+
+var (
+	// dummyPasswordHash is compared against the password when the user does
+	// not exist, so that the response takes as long as for a wrong password.
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+func compareDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
+
+// generateToken generates a new random API token, returning it together with
+// the md5 hash that is stored in the database.
+func generateToken() (string, string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, fmt.Sprintf("%x", md5.Sum([]byte(token))), nil
+}
+
+// TokenNewPOST is the handler for POST /tokens. It checks the credentials of
+// an user, and creates a new API token for them.
+func TokenNewPOST(md common.MethodData) common.CodeMessager {
+	var r tokenNewResponse
+	data := tokenNewInData{}
+	if errResp := md.ParseBody(&data); errResp != nil {
+		return errResp
+	}
+	if data.Username == "" && data.UserID == 0 {
+		return ErrMissingField("username|id")
+	}
+
+	// the account is identified by what was given, so that attempts against
+	// accounts that don't exist are limited as well.
+	ipKey := loginAttemptsKey("ip", md.ClientIP())
+	userKey := loginAttemptsKey("username", common.SafeUsername(data.Username))
+	if data.UserID != 0 {
+		userKey = loginAttemptsKey("id", strconv.Itoa(data.UserID))
+	}
+	if countLoginAttempt(md, map[string]int{ipKey: maxIPLoginAttempts, userKey: maxUserLoginAttempts}) {
+		return common.ErrTooManyLoginAttempts
+	}
+
+	var q *sql.Row
+	const base = "SELECT id, username, privileges, password_md5 FROM users "
+	if data.UserID != 0 {
+		q = md.DB.QueryRowContext(md.Context, base+"WHERE id = ? LIMIT 1", data.UserID)
+	} else {
+		q = md.DB.QueryRowContext(md.Context, base+"WHERE username_safe = ? LIMIT 1", common.SafeUsername(data.Username))
+	}
+
+	var (
+		pw            string
+		privilegesRaw uint64
+	)
+	passwordMD5 := fmt.Sprintf("%x", md5.Sum([]byte(data.Password)))
+	err := q.Scan(&r.ID, &r.Username, &privilegesRaw, &pw)
+	switch {
+	case err == sql.ErrNoRows:
+		// the same error as for a wrong password, so that it can't be used
+		// to find out which accounts exist.
+		compareDummyPassword(passwordMD5)
+		return common.ErrInvalidCredentials
+	case err != nil:
+		md.Err(err)
+		return Err500
+	}
+	privileges := common.UserPrivileges(privilegesRaw)
+
+	err = bcrypt.CompareHashAndPassword([]byte(pw), []byte(passwordMD5))
+	if err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return common.ErrInvalidCredentials
+		}
+		md.Err(err)
+		return Err500
+	}
+	md.R.Del(userKey)
+	md.R.Decr(ipKey)
+
+	const want = common.UserPrivilegePendingVerification | common.UserPrivilegeNormal
+	if privileges&want == 0 {
+		r.Code = 200
+		r.Message = "That user is banned."
+		r.Banned = true
+		return r
+	}
+	r.Privileges = common.Privileges(data.Privileges).CanOnly(privileges)
+
+	var tokenMD5 string
+	r.Token, tokenMD5, err = generateToken()
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	res, err := md.DB.ExecContext(md.Context, "INSERT INTO tokens(user, privileges, description, token, private, last_updated) VALUES (?, ?, ?, ?, 0, ?)",
+		r.ID, uint64(r.Privileges), data.Description, tokenMD5, time.Now().Unix())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	tokenID, _ := res.LastInsertId()
+	// the request is not authenticated: the token is created by the user who
+	// logged in.
+	md.User = common.Token{ID: int(tokenID), UserID: r.ID}
+	md.Audit("token", tokenID, nil, map[string]interface{}{
+		"privileges":  uint64(r.Privileges),
+		"description": data.Description,
+	})
+
+	r.Code = 200
+	return r
+}
+
 // TokenSelfDeletePOST deletes the token the user is connecting with.
 func TokenSelfDeletePOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(400, "How should we delete your token if you haven't even given us one?!")
+		return common.ErrNoToken
 	}
 	var err error
 	if md.IsBearer() {
-		_, err = md.DB.Exec("DELETE FROM osin_access WHERE access_token = ? LIMIT 1",
-			fmt.Sprintf("%x", sha256.Sum256([]byte(md.User.Value))))
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(md.User.Value)))
+		_, err = md.DB.ExecContext(md.Context, "DELETE FROM osin_access WHERE access_token = ? LIMIT 1", hash)
+		if err == nil {
+			md.InvalidateBearerToken(hash)
+		}
 	} else {
-		_, err = md.DB.Exec("DELETE FROM tokens WHERE token = ? LIMIT 1",
+		_, err = md.DB.ExecContext(md.Context, "DELETE FROM tokens WHERE token = ? LIMIT 1",
 			fmt.Sprintf("%x", md5.Sum([]byte(md.User.Value))))
+		if err == nil {
+			md.InvalidateToken(md.User.ID)
+		}
 	}
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	md.Audit("token", md.User.ID, nil, nil)
 	return common.SimpleResponse(200, "Bye!")
 }
 
+type tokenDeleteData struct {
+	ID int `json:"id" validate:"required"`
+}
+
+// TokenDeletePOST revokes one of the user's tokens, given its ID.
+func TokenDeletePOST(md common.MethodData) common.CodeMessager {
+	var d tokenDeleteData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+	res, err := md.DB.ExecContext(md.Context, "DELETE FROM tokens WHERE id = ? AND user = ? LIMIT 1", d.ID, md.ID())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return common.ErrTokenNotFound
+	}
+	md.InvalidateToken(d.ID)
+	md.Audit("token", d.ID, nil, nil)
+	return common.SimpleResponse(200, "The token has been revoked.")
+}
+
+type tokensRevokedResponse struct {
+	common.ResponseBase
+	Revoked int64 `json:"revoked"`
+}
+
+// TokenDeleteOthersPOST revokes all the user's tokens, except for the one
+// being used to make the request. OAuth tokens are left alone.
+func TokenDeleteOthersPOST(md common.MethodData) common.CodeMessager {
+	res, err := md.DB.ExecContext(md.Context, "DELETE FROM tokens WHERE user = ? AND id != ?", md.ID(), md.User.ID)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	var r tokensRevokedResponse
+	r.Revoked, _ = res.RowsAffected()
+	if r.Revoked > 0 {
+		md.InvalidateUserTokens(md.ID())
+		md.Audit("user", md.ID(), nil, map[string]interface{}{"revoked_tokens": r.Revoked})
+	}
+	r.Code = 200
+	return r
+}
+
+type tokenEditData struct {
+	ID          int     `json:"id" validate:"required"`
+	Description *string `json:"description" validate:"max=255"`
+	// ExpiresAt is kept raw to tell apart a missing field (don't change the
+	// expiry) from an explicit null (remove the expiry).
+	ExpiresAt json.RawMessage `json:"expires_at"`
+}
+
+// TokenEditPOST changes the description and the expiry of one of the user's
+// tokens.
+func TokenEditPOST(md common.MethodData) common.CodeMessager {
+	var d tokenEditData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
+	}
+
+	var exists bool
+	err := md.DB.QueryRowContext(md.Context, "SELECT EXISTS(SELECT 1 FROM tokens WHERE id = ? AND user = ?)", d.ID, md.ID()).Scan(&exists)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	if !exists {
+		return common.ErrTokenNotFound
+	}
+	auditedColumns := []string{"description", "expires_at"}
+	before, err := auditColumns(md, "tokens", d.ID, auditedColumns)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+
+	if d.Description != nil {
+		_, err = md.DB.ExecContext(md.Context, "UPDATE tokens SET description = ? WHERE id = ?", *d.Description, d.ID)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+	}
+
+	if len(d.ExpiresAt) != 0 {
+		var expiresAt *int64
+		if string(d.ExpiresAt) != "null" {
+			var t common.UnixTimestamp
+			if err := json.Unmarshal(d.ExpiresAt, &t); err != nil {
+				return common.ErrInvalidExpiry
+			}
+			if !t.After(time.Now()) {
+				return common.ErrExpiryInPast
+			}
+			unix := time.Time(t).Unix()
+			expiresAt = &unix
+		}
+		_, err = md.DB.ExecContext(md.Context, "UPDATE tokens SET expires_at = ? WHERE id = ?", expiresAt, d.ID)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
+		md.InvalidateToken(d.ID)
+	}
+
+	if after, err := auditColumns(md, "tokens", d.ID, auditedColumns); err != nil {
+		md.Err(err)
+	} else {
+		md.Audit("token", d.ID, before, after)
+	}
+
+	var (
+		r         singleTokenResponse
+		expiresAt sql.NullInt64
+	)
+	err = md.DB.QueryRowContext(md.Context, "SELECT id, privileges, description, last_updated, expires_at FROM tokens WHERE id = ?", d.ID).
+		Scan(&r.Token.ID, &r.Token.Privileges, &r.Token.Description, &r.Token.LastUpdated, &expiresAt)
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	r.Token.scanExpiry(expiresAt)
+	r.Code = 200
+	return r
+}
+
 type token struct {
-	ID          int                  `json:"id"`
-	Privileges  uint64               `json:"privileges"`
-	Description string               `json:"description"`
-	LastUpdated common.UnixTimestamp `json:"last_updated"`
+	ID          int                   `json:"id"`
+	Privileges  uint64                `json:"privileges"`
+	Description string                `json:"description"`
+	LastUpdated common.UnixTimestamp  `json:"last_updated"`
+	ExpiresAt   *common.UnixTimestamp `json:"expires_at"`
 }
+
+// scanExpiry sets ExpiresAt from the nullable column in the database.
+func (t *token) scanExpiry(expiresAt sql.NullInt64) {
+	if !expiresAt.Valid {
+		return
+	}
+	ts := common.UnixTimestamp(time.Unix(expiresAt.Int64, 0))
+	t.ExpiresAt = &ts
+}
+
 type tokenResponse struct {
 	common.ResponseBase
 	Tokens []token `json:"tokens"`
 }
 
+type singleTokenResponse struct {
+	common.ResponseBase
+	Token token `json:"token"`
+}
+
 // TokenGET retrieves a list listing all the user's public tokens.
 func TokenGET(md common.MethodData) common.CodeMessager {
 	wc := common.Where("user = ? AND private = 0", strconv.Itoa(md.ID()))
 	if md.Query("id") != "" {
 		wc.Where("id = ?", md.Query("id"))
 	}
-	rows, err := md.DB.Query("SELECT id, privileges, description, last_updated FROM tokens "+
+	rows, err := md.DB.QueryContext(md.Context, "SELECT id, privileges, description, last_updated, expires_at FROM tokens "+
 		wc.Clause+common.Paginate(md.Query("p"), md.Query("l"), 50), wc.Params...)
 
 	if err != nil {
 		return Err500
 	}
+	defer rows.Close()
 	var r tokenResponse
 	for rows.Next() {
-		var t token
-		err = rows.Scan(&t.ID, &t.Privileges, &t.Description, &t.LastUpdated)
+		var (
+			t         token
+			expiresAt sql.NullInt64
+		)
+		err = rows.Scan(&t.ID, &t.Privileges, &t.Description, &t.LastUpdated, &expiresAt)
 		if err != nil {
 			md.Err(err)
 			continue
 		}
+		t.scanExpiry(expiresAt)
 		r.Tokens = append(r.Tokens, t)
 	}
 	r.Code = 200
@@ -121,20 +457,24 @@ type bearerTokenSingleResponse struct {
 // TokenSelfGET retrieves information about the token the user is connecting with.
 func TokenSelfGET(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(404, "How are we supposed to find the token you're using if you ain't even using one?!")
+		return common.ErrNoToken
 	}
 	if md.IsBearer() {
 		return getBearerToken(md)
 	}
-	var r tokenSingleResponse
+	var (
+		r         tokenSingleResponse
+		expiresAt sql.NullInt64
+	)
 	// md.User.ID = token id, userid would have been md.User.UserID. what a clusterfuck
-	err := md.DB.QueryRow("SELECT id, privileges, description, last_updated FROM tokens WHERE id = ?", md.User.ID).Scan(
-		&r.ID, &r.Privileges, &r.Description, &r.LastUpdated,
+	err := md.DB.QueryRowContext(md.Context, "SELECT id, privileges, description, last_updated, expires_at FROM tokens WHERE id = ?", md.User.ID).Scan(
+		&r.ID, &r.Privileges, &r.Description, &r.LastUpdated, &expiresAt,
 	)
 	if err != nil {
 		md.Err(err)
 		return Err500
 	}
+	r.scanExpiry(expiresAt)
 	r.Code = 200
 	return r
 }
@@ -142,9 +482,9 @@ func TokenSelfGET(md common.MethodData) common.CodeMessager {
 func getBearerToken(md common.MethodData) common.CodeMessager {
 	var b bearerTokenSingleResponse
 	err := md.DB.
-		QueryRow(`
+		QueryRowContext(md.Context, `
 			SELECT t.scope, t.created_at, c.id, c.extra
-			FROM osin_access t INNER JOIN osin_client c
+			FROM osin_access t INNER JOIN osin_client c ON c.id = t.client
 			WHERE t.access_token = ?
 		`, fmt.Sprintf("%x", sha256.Sum256([]byte(md.User.Value)))).Scan(
 		&b.Scope, &b.Created, &b.Client.ID, &b.Client,
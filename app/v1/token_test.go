@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/osuAkatsuki/akatsuki-api/common"
+)
+
+func TestTokenEditPOST(t *testing.T) {
+	md, mock := testMethodData(t, `{"id": 5, "description": "bot"}`)
+	md.User = common.Token{ID: 1, UserID: 1000}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM tokens WHERE id = ? AND user = ?)")).
+		WithArgs(5, 1000).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT description, expires_at FROM tokens WHERE id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"description", "expires_at"}).AddRow("", nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE tokens SET description = ? WHERE id = ?")).
+		WithArgs("bot", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT description, expires_at FROM tokens WHERE id = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"description", "expires_at"}).AddRow("bot", nil))
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	// the token is private, which TokenGET would leave out.
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, privileges, description, last_updated, expires_at FROM tokens WHERE id = ?")).
+		WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"id", "privileges", "description", "last_updated", "expires_at"}).
+		AddRow(5, common.PrivilegeRead, "bot", 1700000000, nil))
+
+	resp := TokenEditPOST(md)
+	r, ok := resp.(singleTokenResponse)
+	if !ok {
+		t.Fatalf("got %#v", resp)
+	}
+	if r.Token.ID != 5 || r.Token.Description != "bot" || r.Token.ExpiresAt != nil {
+		t.Errorf("got token %+v", r.Token)
+	}
+	if len(md.Ctx.QueryArgs().Peek("id")) != 0 {
+		t.Error("the query string of the request was changed")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -25,9 +25,9 @@ func TBadgesGET(md common.MethodData) common.CodeMessager {
 		err  error
 	)
 	if md.Query("id") != "" {
-		rows, err = md.DB.Query("SELECT id, name, icon FROM tourmnt_badges WHERE id = ? LIMIT 1", md.Query("id"))
+		rows, err = md.DB.QueryContext(md.Context, "SELECT id, name, icon FROM tourmnt_badges WHERE id = ? LIMIT 1", md.Query("id"))
 	} else {
-		rows, err = md.DB.Query("SELECT id, name, icon FROM tourmnt_badges " + common.Paginate(md.Query("p"), md.Query("l"), 50))
+		rows, err = md.DB.QueryContext(md.Context, "SELECT id, name, icon FROM tourmnt_badges "+common.Paginate(md.Query("p"), md.Query("l"), 50))
 	}
 	if err != nil {
 		md.Err(err)
@@ -63,7 +63,7 @@ func TBadgeMembersGET(md common.MethodData) common.CodeMessager {
 
 	var members TbadgeMembersData
 
-	err := md.DB.Select(&members.Members, `SELECT users.id, users.username, users.register_datetime,
+	err := md.DB.SelectContext(md.Context, &members.Members, `SELECT users.id, users.username, users.register_datetime,
 	users.privileges, users.latest_activity, users.username_aka, users.country
 FROM user_tourmnt_badges ub
 INNER JOIN users ON users.id = ub.user
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/osuAkatsuki/akatsuki-api/common"
@@ -85,7 +86,7 @@ func UsersGET(md common.MethodData) common.CodeMessager {
 	query := userFields + `
 WHERE ` + whereClause + ` AND ` + md.User.OnlyUserPublic(true) + `
 LIMIT 1`
-	return userPutsSingle(md, md.DB.QueryRowx(query, param))
+	return userPutsSingle(md, md.DB.QueryRowxContext(md.Context, query, param))
 }
 
 type userPutsSingleUserData struct {
@@ -101,7 +102,7 @@ func userPutsSingle(md common.MethodData, row *sqlx.Row) common.CodeMessager {
 	err = row.StructScan(&userDataDB)
 	switch {
 	case err == sql.ErrNoRows:
-		return common.SimpleResponse(404, "No such user was found!")
+		return common.ErrUserNotFound
 	case err != nil:
 		md.Err(err)
 		return Err500
@@ -163,7 +164,7 @@ func userPutsMulti(md common.MethodData) common.CodeMessager {
 		" " + common.Paginate(md.Query("p"), md.Query("l"), 100)
 
 	// query execution
-	rows, err := md.DB.Queryx(query, wh.Params...)
+	rows, err := md.DB.QueryxContext(md.Context, query, wh.Params...)
 	if err != nil {
 		md.Err(err)
 		return Err500
@@ -223,10 +224,10 @@ func UserWhatsTheIDGET(md common.MethodData) common.CodeMessager {
 		r          whatIDResponse
 		privileges uint64
 	)
-	err := md.DB.QueryRow("SELECT id, privileges FROM users WHERE username_safe = ? LIMIT 1", common.SafeUsername(md.Query("name"))).Scan(&r.ID, &privileges)
+	err := md.DB.QueryRowContext(md.Context, "SELECT id, privileges FROM users WHERE username_safe = ? LIMIT 1", common.SafeUsername(md.Query("name"))).Scan(&r.ID, &privileges)
 	if err != nil || ((privileges&uint64(common.UserPrivilegePublic)) == 0 &&
 		(md.User.UserPrivileges&common.AdminPrivilegeManageUsers == 0)) {
-		return common.SimpleResponse(404, "That user could not be found!")
+		return common.ErrUserNotFound
 	}
 	r.Code = 200
 	return r
@@ -299,7 +300,7 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 		userDB userDataDB
 	)
 	// Scan user information into response
-	err := md.DB.QueryRow(`
+	err := md.DB.QueryRowContext(md.Context, `
 		SELECT
 			id, username, register_datetime, privileges, latest_activity,
 			username_aka, country, play_style, favourite_mode, custom_badge_icon,
@@ -316,16 +317,21 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 	)
 	switch {
 	case err == sql.ErrNoRows:
-		return common.SimpleResponse(404, "That user could not be found!")
+		return common.ErrUserNotFound
 	case err != nil:
 		md.Err(err)
 		return Err500
 	}
 
-	eligibleTitles, err := getEligibleTitles(md, userDB.ID, userDB.Privileges)
-	if err != nil {
-		md.Err(err)
-		return Err500
+	// the eligible titles are only used to pick the title of users having
+	// none set.
+	var eligibleTitles []eligibleTitle
+	if md.Fields.Has("user_title") {
+		eligibleTitles, err = getEligibleTitles(md, userDB.ID, userDB.Privileges)
+		if err != nil {
+			md.Err(err)
+			return Err500
+		}
 	}
 
 	r.userData = userDB.toUserData(eligibleTitles)
@@ -343,84 +349,31 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 		INNER JOIN users ON users.id = user_stats.user_id
 		WHERE ` + whereClause + ` AND ` + md.User.OnlyUserPublic(true) + ` AND user_stats.mode = ?
 `
-	for _, relaxMode := range []int{0, 1, 2} {
-		modeOffset := relaxMode * 4
-		// Scan vanilla gamemode information into response
-		err = md.DB.QueryRow(query, userIdParam, 0+modeOffset).Scan(
-			&r.Stats[relaxMode].STD.RankedScore, &r.Stats[relaxMode].STD.TotalScore, &r.Stats[relaxMode].STD.PlayCount, &r.Stats[relaxMode].STD.PlayTime,
-			&r.Stats[relaxMode].STD.ReplaysWatched, &r.Stats[relaxMode].STD.TotalHits,
-			&r.Stats[relaxMode].STD.Accuracy, &r.Stats[relaxMode].STD.PP, &r.Stats[relaxMode].STD.MaxCombo,
-			&r.Stats[relaxMode].STD.Grades.XHCount, &r.Stats[relaxMode].STD.Grades.XCount, &r.Stats[relaxMode].STD.Grades.SHCount,
-			&r.Stats[relaxMode].STD.Grades.SCount, &r.Stats[relaxMode].STD.Grades.ACount, &r.Stats[relaxMode].STD.Grades.BCount,
-			&r.Stats[relaxMode].STD.Grades.CCount, &r.Stats[relaxMode].STD.Grades.DCount,
-		)
-		switch {
-		case err == sql.ErrNoRows:
-			return common.SimpleResponse(404, "That user could not be found!")
-		case err != nil:
-			md.Err(err)
-			return Err500
-		}
-
-		if relaxMode == 2 {
-			// AP only has osu! standard
-			continue
-		}
-
-		// Scan taiko gamemode information into response
-		err = md.DB.QueryRow(query, userIdParam, 1+modeOffset).Scan(
-			&r.Stats[relaxMode].Taiko.RankedScore, &r.Stats[relaxMode].Taiko.TotalScore, &r.Stats[relaxMode].Taiko.PlayCount, &r.Stats[relaxMode].Taiko.PlayTime,
-			&r.Stats[relaxMode].Taiko.ReplaysWatched, &r.Stats[relaxMode].Taiko.TotalHits,
-			&r.Stats[relaxMode].Taiko.Accuracy, &r.Stats[relaxMode].Taiko.PP, &r.Stats[relaxMode].Taiko.MaxCombo,
-			&r.Stats[relaxMode].Taiko.Grades.XHCount, &r.Stats[relaxMode].Taiko.Grades.XCount, &r.Stats[relaxMode].Taiko.Grades.SHCount,
-			&r.Stats[relaxMode].Taiko.Grades.SCount, &r.Stats[relaxMode].Taiko.Grades.ACount, &r.Stats[relaxMode].Taiko.Grades.BCount,
-			&r.Stats[relaxMode].Taiko.Grades.CCount, &r.Stats[relaxMode].Taiko.Grades.DCount,
-		)
-		switch {
-		case err == sql.ErrNoRows:
-			return common.SimpleResponse(404, "That user could not be found!")
-		case err != nil:
-			md.Err(err)
-			return Err500
-		}
-
-		// Scan ctb gamemode information into response
-		err = md.DB.QueryRow(query, userIdParam, 2+modeOffset).Scan(
-			&r.Stats[relaxMode].CTB.RankedScore, &r.Stats[relaxMode].CTB.TotalScore, &r.Stats[relaxMode].CTB.PlayCount, &r.Stats[relaxMode].CTB.PlayTime,
-			&r.Stats[relaxMode].CTB.ReplaysWatched, &r.Stats[relaxMode].CTB.TotalHits,
-			&r.Stats[relaxMode].CTB.Accuracy, &r.Stats[relaxMode].CTB.PP, &r.Stats[relaxMode].CTB.MaxCombo,
-			&r.Stats[relaxMode].CTB.Grades.XHCount, &r.Stats[relaxMode].CTB.Grades.XCount, &r.Stats[relaxMode].CTB.Grades.SHCount,
-			&r.Stats[relaxMode].CTB.Grades.SCount, &r.Stats[relaxMode].CTB.Grades.ACount, &r.Stats[relaxMode].CTB.Grades.BCount,
-			&r.Stats[relaxMode].CTB.Grades.CCount, &r.Stats[relaxMode].CTB.Grades.DCount,
-		)
-		switch {
-		case err == sql.ErrNoRows:
-			return common.SimpleResponse(404, "That user could not be found!")
-		case err != nil:
-			md.Err(err)
-			return Err500
-		}
-
-		if relaxMode == 1 {
-			// RX does not have mania
-			continue
-		}
-
-		// Scan mania gamemode information into response
-		err = md.DB.QueryRow(query, userIdParam, 3+modeOffset).Scan(
-			&r.Stats[relaxMode].Mania.RankedScore, &r.Stats[relaxMode].Mania.TotalScore, &r.Stats[relaxMode].Mania.PlayCount, &r.Stats[relaxMode].Mania.PlayTime,
-			&r.Stats[relaxMode].Mania.ReplaysWatched, &r.Stats[relaxMode].Mania.TotalHits,
-			&r.Stats[relaxMode].Mania.Accuracy, &r.Stats[relaxMode].Mania.PP, &r.Stats[relaxMode].Mania.MaxCombo,
-			&r.Stats[relaxMode].Mania.Grades.XHCount, &r.Stats[relaxMode].Mania.Grades.XCount, &r.Stats[relaxMode].Mania.Grades.SHCount,
-			&r.Stats[relaxMode].Mania.Grades.SCount, &r.Stats[relaxMode].Mania.Grades.ACount, &r.Stats[relaxMode].Mania.Grades.BCount,
-			&r.Stats[relaxMode].Mania.Grades.CCount, &r.Stats[relaxMode].Mania.Grades.DCount,
-		)
-		switch {
-		case err == sql.ErrNoRows:
-			return common.SimpleResponse(404, "That user could not be found!")
-		case err != nil:
-			md.Err(err)
-			return Err500
+	for relaxMode := range r.Stats {
+		stats := &r.Stats[relaxMode]
+		for modeID, m := range [...]*modeData{&stats.STD, &stats.Taiko, &stats.CTB, &stats.Mania} {
+			// RX does not have mania, and AP only has osu! standard
+			if (relaxMode == 1 && modeID == 3) || (relaxMode == 2 && modeID != 0) {
+				continue
+			}
+			if !md.Fields.Has("stats." + modesToReadable[modeID]) {
+				continue
+			}
+			err = md.DB.QueryRowContext(md.Context, query, userIdParam, modeID+relaxMode*4).Scan(
+				&m.RankedScore, &m.TotalScore, &m.PlayCount, &m.PlayTime,
+				&m.ReplaysWatched, &m.TotalHits,
+				&m.Accuracy, &m.PP, &m.MaxCombo,
+				&m.Grades.XHCount, &m.Grades.XCount, &m.Grades.SHCount,
+				&m.Grades.SCount, &m.Grades.ACount, &m.Grades.BCount,
+				&m.Grades.CCount, &m.Grades.DCount,
+			)
+			switch {
+			case err == sql.ErrNoRows:
+				return common.ErrUserNotFound
+			case err != nil:
+				md.Err(err)
+				return Err500
+			}
 		}
 	}
 
@@ -430,6 +383,9 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 	}
 
 	for modeID, m := range [...]*modeData{&r.Stats[0].STD, &r.Stats[0].Taiko, &r.Stats[0].CTB, &r.Stats[0].Mania} {
+		if !md.Fields.Has("stats." + modesToReadable[modeID]) {
+			continue
+		}
 		m.Level = ocl.GetLevelPrecise(int64(m.TotalScore))
 
 		if i := leaderboardPosition(md.R, modesToReadable[modeID], r.ID); i != nil {
@@ -441,6 +397,9 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 	}
 	// I'm sorry for this horribleness but ripple and past mistakes have forced my hand
 	for modeID, m := range [...]*modeData{&r.Stats[1].STD, &r.Stats[1].Taiko, &r.Stats[1].CTB, &r.Stats[1].Mania} {
+		if !md.Fields.Has("stats." + modesToReadable[modeID]) {
+			continue
+		}
 		m.Level = ocl.GetLevelPrecise(int64(m.TotalScore))
 
 		if i := relaxboardPosition(md.R, modesToReadable[modeID], r.ID); i != nil {
@@ -452,6 +411,9 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 	}
 
 	for modeID, m := range [...]*modeData{&r.Stats[2].STD} {
+		if !md.Fields.Has("stats." + modesToReadable[modeID]) {
+			continue
+		}
 		m.Level = ocl.GetLevelPrecise(int64(m.TotalScore))
 
 		if i := autoboardPosition(md.R, modesToReadable[modeID], r.ID); i != nil {
@@ -462,34 +424,38 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 		}
 	}
 
-	var follower int
-	rows, err := md.DB.Query("SELECT COUNT(id) FROM `users_relationships` WHERE user2 = ?", r.ID)
-	if err != nil {
-		md.Err(err)
-	}
-	for rows.Next() {
-		err := rows.Scan(&follower)
+	if md.Fields.Has("followers") {
+		var follower int
+		rows, err := md.DB.QueryContext(md.Context, "SELECT COUNT(id) FROM `users_relationships` WHERE user2 = ?", r.ID)
 		if err != nil {
 			md.Err(err)
-			continue
 		}
-	}
-	r.Followers = follower
-
-	rows, err = md.DB.Query("SELECT b.id, b.name, b.icon, b.colour FROM user_badges ub "+
-		"INNER JOIN badges b ON ub.badge = b.id WHERE user = ?", r.ID)
-	if err != nil {
-		md.Err(err)
+		for rows.Next() {
+			err := rows.Scan(&follower)
+			if err != nil {
+				md.Err(err)
+				continue
+			}
+		}
+		r.Followers = follower
 	}
 
-	for rows.Next() {
-		var badge singleBadge
-		err := rows.Scan(&badge.ID, &badge.Name, &badge.Icon, &badge.Colour)
+	if md.Fields.Has("badges") {
+		rows, err := md.DB.QueryContext(md.Context, "SELECT b.id, b.name, b.icon, b.colour FROM user_badges ub "+
+			"INNER JOIN badges b ON ub.badge = b.id WHERE user = ?", r.ID)
 		if err != nil {
 			md.Err(err)
-			continue
 		}
-		r.Badges = append(r.Badges, badge)
+
+		for rows.Next() {
+			var badge singleBadge
+			err := rows.Scan(&badge.ID, &badge.Name, &badge.Icon, &badge.Colour)
+			if err != nil {
+				md.Err(err)
+				continue
+			}
+			r.Badges = append(r.Badges, badge)
+		}
 	}
 
 	if md.User.TokenPrivileges&common.PrivilegeManageUser == 0 {
@@ -498,25 +464,29 @@ func UserFullGET(md common.MethodData) common.CodeMessager {
 		r.Email = ""
 	}
 
-	rows, err = md.DB.Query("SELECT tb.id, tb.name, tb.icon FROM user_tourmnt_badges tub "+
-		"INNER JOIN tourmnt_badges tb ON tub.badge = tb.id WHERE user = ?", r.ID)
-	if err != nil {
-		md.Err(err)
-	}
-
-	for rows.Next() {
-		var Tbadge TsingleBadge
-		err := rows.Scan(&Tbadge.ID, &Tbadge.Name, &Tbadge.Icon)
+	if md.Fields.Has("tbadges") {
+		rows, err := md.DB.QueryContext(md.Context, "SELECT tb.id, tb.name, tb.icon FROM user_tourmnt_badges tub "+
+			"INNER JOIN tourmnt_badges tb ON tub.badge = tb.id WHERE user = ?", r.ID)
 		if err != nil {
 			md.Err(err)
-			continue
 		}
-		r.TBadges = append(r.TBadges, Tbadge)
+
+		for rows.Next() {
+			var Tbadge TsingleBadge
+			err := rows.Scan(&Tbadge.ID, &Tbadge.Name, &Tbadge.Icon)
+			if err != nil {
+				md.Err(err)
+				continue
+			}
+			r.TBadges = append(r.TBadges, Tbadge)
+		}
 	}
 
-	r.Clan, err = getClan(r.Clan.ID, md)
-	if err != nil {
-		md.Err(err)
+	if md.Fields.Has("clan") {
+		r.Clan, err = getClan(r.Clan.ID, md)
+		if err != nil {
+			md.Err(err)
+		}
 	}
 
 	r.Code = 200
@@ -561,10 +531,10 @@ func UserUserpageGET(md common.MethodData) common.CodeMessager {
 		return *shouldRet
 	}
 	var r userpageResponse
-	err := md.DB.QueryRow("SELECT userpage_content FROM users WHERE "+whereClause, param).Scan(&r.Userpage)
+	err := md.DB.QueryRowContext(md.Context, "SELECT userpage_content FROM users WHERE "+whereClause, param).Scan(&r.Userpage)
 	switch {
 	case err == sql.ErrNoRows:
-		return common.SimpleResponse(404, "No such user!")
+		return common.ErrUserNotFound
 	case err != nil:
 		md.Err(err)
 		return Err500
@@ -582,23 +552,38 @@ func UserUserpageGET(md common.MethodData) common.CodeMessager {
 	return r
 }
 
+// auditUserpage summarises a userpage in the audit log, as the userpage itself
+// is too long to be stored there.
+type auditUserpage struct {
+	Length int `json:"length"`
+}
+
+type userpageData struct {
+	Data *string `json:"data" validate:"required"`
+}
+
 // UserSelfUserpagePOST allows to change the current user's userpage.
 func UserSelfUserpagePOST(md common.MethodData) common.CodeMessager {
-	var d struct {
-		Data *string `json:"data"`
-	}
-	md.Unmarshal(&d)
-	if d.Data == nil {
-		return ErrMissingField("data")
+	var d userpageData
+	if errResp := md.ParseBody(&d); errResp != nil {
+		return errResp
 	}
 	if len(*d.Data) > 65535 {
-		return common.SimpleResponse(400, "Userpage content is too long, maximum is 65535 characters")
+		return common.ErrUserpageTooLong
 	}
 	cont := common.SanitiseString(*d.Data)
-	_, err := md.DB.Exec("UPDATE users SET userpage_content = ? WHERE id = ?", cont, md.ID())
+	var before auditUserpage
+	err := md.DB.QueryRowContext(md.Context, "SELECT COALESCE(CHAR_LENGTH(userpage_content), 0) FROM users WHERE id = ?", md.ID()).Scan(&before.Length)
 	if err != nil {
 		md.Err(err)
+		return Err500
 	}
+	_, err = md.DB.ExecContext(md.Context, "UPDATE users SET userpage_content = ? WHERE id = ?", cont, md.ID())
+	if err != nil {
+		md.Err(err)
+		return Err500
+	}
+	md.Audit("user", md.ID(), before, auditUserpage{utf8.RuneCountInString(cont)})
 	md.Ctx.URI().SetQueryString("id=self")
 	return UserUserpageGET(md)
 }
@@ -610,14 +595,14 @@ func whereClauseUser(md common.MethodData, tableName string) (*common.CodeMessag
 	case md.Query("id") != "":
 		id, err := strconv.Atoi(md.Query("id"))
 		if err != nil {
-			a := common.SimpleResponse(400, "please pass a valid user ID")
+			var a common.CodeMessager = common.ErrInvalidID
 			return &a, "", nil
 		}
 		return nil, tableName + ".id = ?", id
 	case md.Query("name") != "":
 		return nil, tableName + ".username_safe = ?", common.SafeUsername(md.Query("name"))
 	}
-	a := common.SimpleResponse(400, "you need to pass either querystring parameters name or id")
+	var a common.CodeMessager = common.ErrMissingUser
 	return &a, "", nil
 }
 
@@ -640,7 +625,7 @@ func UserLookupGET(md common.MethodData) common.CodeMessager {
 		"\\", "\\\\",
 	).Replace(name)
 	if name == "" {
-		return common.SimpleResponse(400, "please provide an username to start searching")
+		return common.ErrMissingUsername
 	}
 	name = "%" + name + "%"
 
@@ -650,7 +635,7 @@ func UserLookupGET(md common.MethodData) common.CodeMessager {
 		email = md.Query("name")
 	}
 
-	rows, err := md.DB.Query("SELECT users.id, users.username FROM users WHERE "+
+	rows, err := md.DB.QueryContext(md.Context, "SELECT users.id, users.username FROM users WHERE "+
 		"(username_safe LIKE ? OR email = ?) AND "+
 		md.User.OnlyUserPublic(true)+" LIMIT 25", name, email)
 	if err != nil {
@@ -672,27 +657,27 @@ func UserLookupGET(md common.MethodData) common.CodeMessager {
 	return r
 }
 
+type beatmapPlaycount struct {
+	Count   int     `json:"playcount"`
+	Beatmap beatmap `json:"beatmap"`
+}
+
+type mostPlayedBeatmapsResponse struct {
+	common.ResponseBase
+	BeatmapsPlaycount []beatmapPlaycount `json:"most_played_beatmaps"`
+}
+
 func UserMostPlayedBeatmapsGET(md common.MethodData) common.CodeMessager {
 	user := common.Int(md.Query("id"))
 	if user == 0 {
-		return common.SimpleResponse(401, "Invalid user id!")
+		return common.ErrInvalidID
 	}
 
 	relax := common.Int(md.Query("rx"))
 	mode := common.Int(md.Query("mode"))
 
-	type BeatmapPlaycount struct {
-		Count   int     `json:"playcount"`
-		Beatmap beatmap `json:"beatmap"`
-	}
-
-	type MostPlayedBeatmaps struct {
-		common.ResponseBase
-		BeatmapsPlaycount []BeatmapPlaycount `json:"most_played_beatmaps"`
-	}
-
 	// i will query some additional info about the beatmap for later?
-	rows, err := md.DB.Query(
+	rows, err := md.DB.QueryContext(md.Context,
 		fmt.Sprintf(
 			`SELECT user_beatmaps.count,
 		beatmaps.beatmap_id, beatmaps.beatmapset_id, beatmaps.beatmap_md5,
@@ -707,10 +692,10 @@ func UserMostPlayedBeatmapsGET(md common.MethodData) common.CodeMessager {
 	}
 	defer rows.Close()
 
-	r := MostPlayedBeatmaps{}
+	r := mostPlayedBeatmapsResponse{}
 
 	for rows.Next() {
-		bmc := BeatmapPlaycount{}
+		bmc := beatmapPlaycount{}
 
 		err = rows.Scan(&bmc.Count, &bmc.Beatmap.BeatmapID, &bmc.Beatmap.BeatmapsetID,
 			&bmc.Beatmap.BeatmapMD5, &bmc.Beatmap.SongName, &bmc.Beatmap.Ranked)
@@ -1,8 +1,10 @@
 package v1
 
 import (
+	"context"
 	"database/sql"
 	"strconv"
+	"sync"
 	"time"
 
 	"golang.org/x/exp/slog"
@@ -21,21 +23,47 @@ type Achievement struct {
 }
 
 // LoadAchievementsEvery reloads the achievements in the database every given
-// amount of time.
-func LoadAchievementsEvery(db *sqlx.DB, d time.Duration) {
+// amount of time, until ctx is done.
+func LoadAchievementsEvery(ctx context.Context, db *sqlx.DB, d time.Duration) {
 	for {
-		achievs = nil
-		err := db.Select(&achievs,
-			"SELECT id, name, `desc` AS description, file AS icon, mode FROM less_achievements ORDER BY id ASC")
-		if err != nil {
+		if _, err := loadAchievements(ctx, db); err != nil {
 			slog.Error("LoadAchievements error", "error", err.Error())
 			common.GenericError(err)
 		}
-		time.Sleep(d)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d):
+		}
 	}
 }
 
-var achievs []Achievement
+var (
+	achievs   []Achievement
+	achievsMu sync.RWMutex
+)
+
+// loadAchievements replaces the achievements in memory with the ones in the
+// database, returning how many there are. If they can't be loaded, the
+// previous ones are kept.
+func loadAchievements(ctx context.Context, db *sqlx.DB) (int, error) {
+	var a []Achievement
+	err := db.SelectContext(ctx, &a,
+		"SELECT id, name, `desc` AS description, file AS icon, mode FROM less_achievements ORDER BY id ASC")
+	if err != nil {
+		return 0, err
+	}
+	achievsMu.Lock()
+	achievs = a
+	achievsMu.Unlock()
+	return len(a), nil
+}
+
+func achievements() []Achievement {
+	achievsMu.RLock()
+	defer achievsMu.RUnlock()
+	return achievs
+}
 
 type userAchievement struct {
 	Achievement
@@ -67,7 +95,7 @@ func UserAchievementsGET(md common.MethodData) common.CodeMessager {
 			fullMode = parsedMode
 			vm := fullMode % 4
 			vanillaMode = &vm
-			err = md.DB.Select(&ids, `SELECT ua.achievement_id FROM users_achievements ua
+			err = md.DB.SelectContext(md.Context, &ids, `SELECT ua.achievement_id FROM users_achievements ua
 INNER JOIN users ON users.id = ua.user_id
 WHERE `+whereClause+` AND ua.mode = ? ORDER BY ua.achievement_id ASC`, param, fullMode)
 		} else {
@@ -75,19 +103,20 @@ WHERE `+whereClause+` AND ua.mode = ? ORDER BY ua.achievement_id ASC`, param, fu
 			err = sql.ErrNoRows
 		}
 	} else {
-		err = md.DB.Select(&ids, `SELECT ua.achievement_id FROM users_achievements ua
+		err = md.DB.SelectContext(md.Context, &ids, `SELECT ua.achievement_id FROM users_achievements ua
 INNER JOIN users ON users.id = ua.user_id
 WHERE `+whereClause+` ORDER BY ua.achievement_id ASC`, param)
 	}
 
 	switch {
 	case err == sql.ErrNoRows:
-		return common.SimpleResponse(404, "No such user!")
+		return common.ErrUserNotFound
 	case err != nil:
 		md.Err(err)
 		return Err500
 	}
 	all := md.HasQuery("all")
+	achievs := achievements()
 	resp := userAchievementsResponse{Achievements: make([]userAchievement, 0, len(achievs))}
 
 	for _, ach := range achievs {
@@ -10,6 +10,12 @@ import (
 	"zxq.co/x/getrank"
 )
 
+type userFirstResponse struct {
+	common.ResponseBase
+	Total  int         `json:"total"`
+	Scores []userScore `json:"scores"`
+}
+
 func UserFirstGET(md common.MethodData) common.CodeMessager {
 	id := common.Int(md.Query("id"))
 	if id == 0 {
@@ -26,13 +32,9 @@ func UserFirstGET(md common.MethodData) common.CodeMessager {
 		table = "scores_ap"
 	}
 
-	r := struct {
-		common.ResponseBase
-		Total  int         `json:"total"`
-		Scores []userScore `json:"scores"`
-	}{}
+	r := userFirstResponse{}
 
-	md.DB.Get(&r.Total, "SELECT COUNT(scoreid) FROM scores_first WHERE userid = ? AND mode = ? AND rx = ?", id, mode, rx)
+	md.DB.GetContext(md.Context, &r.Total, "SELECT COUNT(scoreid) FROM scores_first WHERE userid = ? AND mode = ? AND rx = ?", id, mode, rx)
 	query := fmt.Sprintf(`SELECT
 		%[1]s.id, %[1]s.beatmap_md5, %[1]s.score,
 		%[1]s.max_combo, %[1]s.full_combo, %[1]s.mods,
@@ -49,7 +51,7 @@ func UserFirstGET(md common.MethodData) common.CodeMessager {
 		INNER JOIN beatmaps ON beatmaps.beatmap_md5 = scores_first.beatmap_md5
 		INNER JOIN %[1]s ON %[1]s.id = scores_first.scoreid WHERE scores_first.userid = ? AND scores_first.mode = ? AND scores_first.rx = ? ORDER BY %[1]s.time DESC %s`, table, common.Paginate(md.Query("p"), md.Query("l"), 100))
 
-	rows, err := md.DB.Query(query, id, mode, rx)
+	rows, err := md.DB.QueryContext(md.Context, query, id, mode, rx)
 	if err != nil {
 		md.Err(err)
 		return Err500
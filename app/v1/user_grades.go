@@ -36,7 +36,7 @@ func UserGradesGET(md common.MethodData) common.CodeMessager {
 		md.User.OnlyUserPublic(true),
 	)
 
-	err := md.DB.QueryRow(query, userID, mode).Scan(
+	err := md.DB.QueryRowContext(md.Context, query, userID, mode).Scan(
 		&response.Grades.XHCount,
 		&response.Grades.XCount,
 		&response.Grades.SHCount,
@@ -49,7 +49,7 @@ func UserGradesGET(md common.MethodData) common.CodeMessager {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return common.SimpleResponse(404, "User stats not found")
+			return common.ErrUserStatsNotFound
 		}
 		md.Err(err)
 		return Err500
@@ -169,20 +169,30 @@ func UserScoresPinnedGET(md common.MethodData) common.CodeMessager {
 	return scoresPuts(md, query, param, mode)
 }
 
+// auditPin summarises whether a score is pinned in the audit log.
+type auditPin struct {
+	Pinned bool `json:"pinned"`
+	Relax  int  `json:"rx"`
+}
+
+type pinData struct {
+	ID    string `json:"id" validate:"required,numeric"`
+	Relax int    `json:"rx" validate:"oneof=0 1 2"`
+}
+
 func ScoresPinAddPOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
-	var u struct {
-		ID    string `json:"id"`
-		Relax int    `json:"rx"`
+	var u pinData
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
 	}
-	md.Unmarshal(&u)
 
 	id, err := strconv.ParseInt(u.ID, 10, 64)
 	if err != nil {
-		panic(err)
+		return common.ErrInvalidID
 	}
 
 	return pinScore(md, id, u.Relax, md.ID())
@@ -190,18 +200,17 @@ func ScoresPinAddPOST(md common.MethodData) common.CodeMessager {
 
 func ScoresPinDelPOST(md common.MethodData) common.CodeMessager {
 	if md.ID() == 0 {
-		return common.SimpleResponse(401, "not authorised")
+		return common.ErrNotAuthenticated
 	}
 
-	var u struct {
-		ID    string `json:"id"`
-		Relax int    `json:"rx"`
+	var u pinData
+	if errResp := md.ParseBody(&u); errResp != nil {
+		return errResp
 	}
-	md.Unmarshal(&u)
 
 	id, err := strconv.ParseInt(u.ID, 10, 64)
 	if err != nil {
-		panic(err)
+		return common.ErrInvalidID
 	}
 
 	return unpinScore(md, id, u.Relax, md.ID())
@@ -217,21 +226,25 @@ func pinScore(md common.MethodData, id int64, relax int, userId int) common.Code
 		table = "scores"
 	}
 
-	var v int
-	err := md.DB.QueryRow(fmt.Sprintf("SELECT userid FROM %s WHERE id = ?", table), id).Scan(&v)
+	var (
+		v      int
+		pinned bool
+	)
+	err := md.DB.QueryRowContext(md.Context, fmt.Sprintf("SELECT userid, pinned FROM %s WHERE id = ?", table), id).Scan(&v, &pinned)
 	if err != nil {
 		md.Err(err)
-		return common.SimpleResponse(404, "I'd also like to pin a score I don't have... but I can't.")
+		return common.ErrScoreNotFound
 	}
 	if v != userId {
-		return common.SimpleResponse(401, "no")
+		return common.ErrNotScoreOwner
 	}
 
-	_, err = md.DB.Exec(fmt.Sprintf("UPDATE %s SET pinned = 1 WHERE id = ?", table), id)
+	_, err = md.DB.ExecContext(md.Context, fmt.Sprintf("UPDATE %s SET pinned = 1 WHERE id = ?", table), id)
 	if err != nil {
 		md.Err(err)
-		return common.SimpleResponse(404, "I'd also like to pin a score I don't have... but I can't.")
+		return common.ErrScoreNotFound
 	}
+	md.Audit("score", id, auditPin{pinned, relax}, auditPin{true, relax})
 
 	r := pinResponse{}
 	r.Code = 200
@@ -249,17 +262,26 @@ func unpinScore(md common.MethodData, id int64, relax int, userId int) common.Co
 		table = "scores"
 	}
 
-	var v int
-	err := md.DB.QueryRow(fmt.Sprintf("SELECT userid FROM %s WHERE id = ?", table), id).Scan(&v)
+	var (
+		v      int
+		pinned bool
+	)
+	err := md.DB.QueryRowContext(md.Context, fmt.Sprintf("SELECT userid, pinned FROM %s WHERE id = ?", table), id).Scan(&v, &pinned)
 	if err != nil {
 		md.Err(err)
-		return common.SimpleResponse(404, "I'd also like to unpin a score I don't have... but I can't.")
+		return common.ErrScoreNotFound
 	}
 	if v != userId {
-		return common.SimpleResponse(401, "no")
+		return common.ErrNotScoreOwner
+	}
+
+	_, err = md.DB.ExecContext(md.Context, fmt.Sprintf("UPDATE %s SET pinned = 0 WHERE id = ?", table), id)
+	if err != nil {
+		md.Err(err)
+		return Err500
 	}
+	md.Audit("score", id, auditPin{pinned, relax}, auditPin{false, relax})
 
-	md.DB.Exec(fmt.Sprintf("UPDATE %s SET pinned = 0 WHERE id = ?", table), id)
 	r := pinResponse{}
 	r.Code = 200
 	r.ScoreId = strconv.FormatInt(id, 10)
@@ -267,7 +289,7 @@ func unpinScore(md common.MethodData, id int64, relax int, userId int) common.Co
 }
 
 func scoresPuts(md common.MethodData, query string, params ...interface{}) common.CodeMessager {
-	rows, err := md.DB.Query(query, params...)
+	rows, err := md.DB.QueryContext(md.Context, query, params...)
 	if err != nil {
 		md.Err(err)
 		return Err500
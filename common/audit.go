@@ -0,0 +1,47 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// auditTimeout is how long writing an entry of the audit log may take. The
+// entry is written even if the request was cancelled, as the change it
+// records has already been made.
+const auditTimeout = 5 * time.Second
+
+// Audit records in the audit log that the user of the request changed the
+// entity of the given kind (user, clan, score, token...) and ID. before and
+// after summarise the entity before and after the change, and are stored as
+// JSON; either can be nil, such as when the entity was created or deleted.
+//
+// Entries are stored in the audit_log table, created by
+// migrations/004_audit_log.sql, along with the user and token who made the
+// change, the route, the client IP and the time. Errors are logged, not
+// returned, as the change itself was successful.
+func (md MethodData) Audit(kind string, id interface{}, before, after interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+	defer cancel()
+
+	_, err := md.DB.ExecContext(ctx, `INSERT INTO audit_log(user_id, token_id, route, target_type, target_id,
+		before_state, after_state, ip, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, UNIX_TIMESTAMP())`,
+		md.User.UserID, md.User.ID, string(md.Ctx.Method())+" "+Route(md.Ctx), kind, fmt.Sprint(id),
+		auditJSON(before), auditJSON(after), md.ClientIP())
+	if err != nil {
+		md.Err(err)
+	}
+}
+
+func auditJSON(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		GenericError(err)
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"gopkg.in/redis.v5"
+)
+
+// CacheKeyPrefix is the prefix of every key used by the response cache.
+const CacheKeyPrefix = "api:cache:"
+
+// CacheTagKey returns the key of the redis set holding the keys of all the
+// cached responses tagged with tag.
+func CacheTagKey(tag string) string {
+	return CacheKeyPrefix + "tag:" + tag
+}
+
+// InvalidateCache removes from the response cache every response tagged with
+// any of the given tags.
+func InvalidateCache(r *redis.Client, tags ...string) error {
+	for _, tag := range tags {
+		keys, err := r.SMembers(CacheTagKey(tag)).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, CacheTagKey(tag))
+		if err := r.Del(keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateCache removes from the response cache every response tagged with
+// any of the given tags. Errors are logged, not returned, as the cached
+// responses will expire by themselves anyway.
+func (md MethodData) InvalidateCache(tags ...string) {
+	if err := InvalidateCache(md.R, tags...); err != nil {
+		md.Err(err)
+	}
+}
+
+// FlushCache removes every response from the response cache, returning the
+// number of keys deleted.
+func FlushCache(r *redis.Client) (int, error) {
+	n := 0
+	for _, match := range [...]string{CacheKeyPrefix + "resp:*", CacheKeyPrefix + "tag:*"} {
+		var cursor uint64
+		for {
+			keys, next, err := r.Scan(cursor, match, 1000).Result()
+			if err != nil {
+				return n, err
+			}
+			if len(keys) > 0 {
+				if err := r.Del(keys...).Err(); err != nil {
+					return n, err
+				}
+				n += len(keys)
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	return n, nil
+}
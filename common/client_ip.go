@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// trustedProxies are the networks of the reverse proxies in front of the API,
+// whose X-Real-IP and X-Forwarded-For headers are believed. They are set from
+// TRUSTED_PROXIES by LoadSettings.
+var trustedProxies []*net.IPNet
+
+// parseNetworks parses a comma separated list of IPs and CIDR networks.
+func parseNetworks(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, n := range strings.Split(s, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", n)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func trustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP of the client making the request. The X-Real-IP
+// and X-Forwarded-For headers are only believed when the request comes from
+// a trusted proxy, as anyone else can set them to whatever they like.
+func ClientIP(c *fasthttp.RequestCtx) string {
+	ip := c.RemoteIP()
+	if !trustedProxy(ip) {
+		return ip.String()
+	}
+	if realIP := strings.TrimSpace(string(c.Request.Header.Peek("X-Real-Ip"))); realIP != "" {
+		return realIP
+	}
+	// every proxy appends the address it got the request from, so the client
+	// is the last address which is not one of our proxies.
+	hops := strings.Split(string(c.Request.Header.Peek("X-Forwarded-For")), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return ip.String()
+}
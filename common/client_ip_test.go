@@ -0,0 +1,49 @@
+package common
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestClientIP(t *testing.T) {
+	nets, err := parseNetworks("127.0.0.1, 10.0.0.0/8, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedProxies = nets
+	defer func() { trustedProxies = nil }()
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", "203.0.113.1", nil, "203.0.113.1"},
+		{"spoofed X-Real-IP", "203.0.113.1", map[string]string{"X-Real-Ip": "198.51.100.1"}, "203.0.113.1"},
+		{"spoofed X-Forwarded-For", "203.0.113.1", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.1"},
+		{"proxy X-Real-IP", "127.0.0.1", map[string]string{"X-Real-Ip": "198.51.100.1"}, "198.51.100.1"},
+		{"proxy X-Forwarded-For", "10.1.2.3", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"client prepending to X-Forwarded-For", "10.1.2.3", map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.1, 10.0.0.1"}, "198.51.100.1"},
+		{"proxy without headers", "::1", nil, "::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			var c fasthttp.RequestCtx
+			c.Init(&req, &net.TCPAddr{IP: net.ParseIP(tt.remote)}, nil)
+			if got := ClientIP(&c); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseNetworks("10.0.0.0/8, nonsense"); err == nil {
+		t.Error("parseNetworks() accepted an invalid network")
+	}
+}
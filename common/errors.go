@@ -0,0 +1,175 @@
+package common
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// APIError is an error of the catalogue of errors returned by the API. Code
+// is a stable, machine-readable identifier clients can rely on, while the
+// message is meant for humans and may change.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// GetCode retrieves the HTTP status of the error.
+func (e APIError) GetCode() int {
+	return e.Status
+}
+
+// GetMessage retrieves the message of the error.
+func (e APIError) GetMessage() string {
+	return e.Message
+}
+
+// MarshalJSON encodes the error as a ResponseBase.
+func (e APIError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ResponseBase{
+		Code:    e.Status,
+		Error:   e.Code,
+		Message: e.Message,
+	})
+}
+
+// WithMessage returns the error with a more specific message.
+func (e APIError) WithMessage(message string) APIError {
+	e.Message = message
+	return e
+}
+
+var errorCatalogue = map[string]APIError{}
+
+func newAPIError(status int, code, message string) APIError {
+	if _, ok := errorCatalogue[code]; ok {
+		panic("duplicate error code " + code)
+	}
+	e := APIError{Status: status, Code: code, Message: message}
+	errorCatalogue[code] = e
+	return e
+}
+
+// ErrorCatalogue returns all the errors the API may return, sorted by code.
+func ErrorCatalogue() []APIError {
+	errs := make([]APIError, 0, len(errorCatalogue))
+	for _, e := range errorCatalogue {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Code < errs[j].Code
+	})
+	return errs
+}
+
+// ErrMissingFields returns ErrMissingField listing the fields that are
+// missing.
+func ErrMissingFields(fields ...string) APIError {
+	return ErrMissingField.WithMessage("Missing parameters: " + strings.Join(fields, ", ") + ".")
+}
+
+// Generic errors.
+var (
+	ErrInternal              = newAPIError(500, "internal_error", "An error occurred. Trying again may work. If it doesn't, yell at this Ripple instance admin and tell them to fix the API.")
+	ErrBadJSON               = newAPIError(400, "bad_json", "Your JSON for this request is invalid.")
+	ErrMissingField          = newAPIError(422, "missing_fields", "Some required parameters are missing.")
+	ErrNotAuthenticated      = newAPIError(401, "not_authenticated", "You need to be logged in to do this.")
+	ErrMissingScopes         = newAPIError(401, "missing_scopes", "Unauthorized.")
+	ErrForbidden             = newAPIError(403, "forbidden", "You don't have privileges to access that route.")
+	ErrRateLimited           = newAPIError(429, "rate_limited", "You are being rate limited. Slow down!")
+	ErrRouteNotFound         = newAPIError(404, "route_not_found", "No such route.")
+	ErrInvalidID             = newAPIError(400, "invalid_id", "Please pass a valid ID.")
+	ErrInvalidMode           = newAPIError(400, "invalid_mode", "Invalid game mode.")
+	ErrInvalidRelax          = newAPIError(400, "invalid_relax", "Invalid relax value.")
+	ErrTooManyBatchRequests  = newAPIError(400, "too_many_batch_requests", "Too many requests in the batch.")
+	ErrInvalidBatchPath      = newAPIError(400, "invalid_batch_path", "Only paths starting with /api/v1/ can be requested.")
+	ErrBatchTimeout          = newAPIError(504, "batch_timeout", "The request took too long.")
+	ErrNotConfigured         = newAPIError(503, "not_configured", "This feature is not configured.")
+	ErrRequestTimeout        = newAPIError(503, "request_timeout", "The request took too long to complete. Try again later.")
+	ErrRankCalculation       = newAPIError(500, "rank_calculation_failed", "Failed to calculate the hypothetical rank.")
+	ErrInvalidPP             = newAPIError(400, "invalid_pp", "Invalid performance points.")
+	ErrInvalidIdempotencyKey = newAPIError(400, "invalid_idempotency_key", "The Idempotency-Key must be made of 1 to 255 printable ASCII characters.")
+	ErrIdempotencyKeyInUse   = newAPIError(409, "idempotency_key_in_use", "A request with this Idempotency-Key is still being processed.")
+	ErrIdempotencyKeyReused  = newAPIError(422, "idempotency_key_reused", "This Idempotency-Key was already used for a different request.")
+)
+
+// Users, friends and scores.
+var (
+	ErrUserNotFound      = newAPIError(404, "user_not_found", "That user could not be found!")
+	ErrMissingUser       = newAPIError(400, "missing_user", "You need to pass either querystring parameters name or id.")
+	ErrMissingUsername   = newAPIError(400, "missing_username", "Please provide an username to start searching.")
+	ErrUserStatsNotFound = newAPIError(404, "user_stats_not_found", "The stats of that user could not be found.")
+	ErrUserpageTooLong   = newAPIError(400, "userpage_too_long", "Userpage content is too long, maximum is 65535 characters.")
+	ErrInvalidTitle      = newAPIError(400, "invalid_title", "Invalid title selected.")
+	ErrFriendSelf        = newAPIError(406, "friend_self", "You can't add yourself to your friends.")
+	ErrMatchNotFound     = newAPIError(404, "match_not_found", "That match could not be found!")
+	ErrBeatmapNotFound   = newAPIError(404, "beatmap_not_found", "That beatmap could not be found!")
+	ErrBeatmapOrSet      = newAPIError(400, "beatmap_or_set_required", "Either beatmap_id or beatmapset_id is required, but not both.")
+	ErrScoreNotFound     = newAPIError(404, "score_not_found", "That score could not be found.")
+	ErrNotScoreOwner     = newAPIError(403, "not_score_owner", "That score was not set by you.")
+)
+
+// Badges.
+var (
+	ErrBadgeNotFound       = newAPIError(404, "badge_not_found", "That badge could not be found.")
+	ErrBadgeAlreadyGranted = newAPIError(409, "badge_already_granted", "The user already has that badge.")
+	ErrBadgeNotGranted     = newAPIError(404, "badge_not_granted", "The user doesn't have that badge.")
+)
+
+// Maintenance and global alerts.
+var (
+	ErrMaintenance        = newAPIError(503, "maintenance", "The API is under maintenance. Try again later.")
+	ErrAlertNotFound      = newAPIError(404, "alert_not_found", "That alert could not be found.")
+	ErrInvalidAlertWindow = newAPIError(400, "invalid_alert_window", "ends_at must be after starts_at.")
+)
+
+// Moderation.
+var (
+	ErrModerateSelf  = newAPIError(403, "moderate_self", "You can't take moderation actions against yourself.")
+	ErrUserBanned    = newAPIError(409, "user_banned", "The user is banned, and must be unbanned instead.")
+	ErrModerateStaff = newAPIError(403, "moderate_staff", "You can't take moderation actions against members of the staff.")
+)
+
+// Tokens and OAuth.
+var (
+	ErrTooManyLoginAttempts       = newAPIError(429, "too_many_login_attempts", "You've made too many login attempts. Try again later.")
+	ErrInvalidCredentials         = newAPIError(403, "invalid_credentials", "Wrong username or password.")
+	ErrNoToken                    = newAPIError(400, "no_token", "This request was not made with a token.")
+	ErrTokenNotFound              = newAPIError(404, "token_not_found", "That token could not be found!")
+	ErrInvalidExpiry              = newAPIError(400, "invalid_expires_at", "expires_at must be a RFC 3339 date or null.")
+	ErrExpiryInPast               = newAPIError(400, "expires_at_in_past", "expires_at must be in the future.")
+	ErrOAuthTokenNotAllowed       = newAPIError(403, "oauth_token_not_allowed", "OAuth tokens can't be used to authorize other applications.")
+	ErrOAuthClientNotFound        = newAPIError(404, "oauth_client_not_found", "No application with that ID was found.")
+	ErrRedirectURIMismatch        = newAPIError(400, "redirect_uri_mismatch", "The redirect_uri does not match the one of the application.")
+	ErrInvalidRedirectURI         = newAPIError(400, "invalid_redirect_uri", "The redirect_uri must be an https URL, an http URL on the loopback interface or a private-use URI scheme, without a fragment.")
+	ErrUnsupportedResponseType    = newAPIError(400, "unsupported_response_type", "Only the code response_type is supported.")
+	ErrUnknownScope               = newAPIError(400, "unknown_scope", "Unknown scope requested.")
+	ErrUnsupportedChallengeMethod = newAPIError(400, "unsupported_code_challenge_method", "Unsupported code_challenge_method.")
+	ErrPKCERequired               = newAPIError(400, "pkce_required", "Public applications must use PKCE.")
+	ErrTooManyOAuthClients        = newAPIError(403, "too_many_oauth_clients", "You have too many applications already.")
+	ErrMissingOAuthState          = newAPIError(400, "missing_oauth_state", "Missing OAuth state. Please try linking your account again.")
+	ErrBadOAuthState              = newAPIError(400, "invalid_oauth_state", "Invalid OAuth state. Please try linking your account again.")
+	ErrConnectionNotLinked        = newAPIError(400, "connection_not_linked", "No account of this service is linked.")
+	ErrConnectionAlreadyLinked    = newAPIError(409, "connection_already_linked", "You already have an account of this service linked.")
+	ErrConnectionProviderFailed   = newAPIError(502, "connection_provider_failed", "The service to link the account with returned an error.")
+	ErrConnectionProviderInvalid  = newAPIError(502, "connection_provider_invalid", "The service to link the account with returned an invalid response.")
+)
+
+// Clans.
+var (
+	ErrClanNotFound       = newAPIError(404, "clan_not_found", "That clan could not be found.")
+	ErrInviteNotFound     = newAPIError(404, "invite_not_found", "No clan with the given invite was found.")
+	ErrClanIDOrInvite     = newAPIError(400, "clan_id_or_invite_required", "Either the id or the invite of the clan is required.")
+	ErrAlreadyInClan      = newAPIError(403, "already_in_clan", "You have already joined a clan.")
+	ErrNotInClan          = newAPIError(403, "not_in_clan", "You haven't joined any clan.")
+	ErrNotClanOwner       = newAPIError(403, "not_clan_owner", "You don't own a clan.")
+	ErrClanClosed         = newAPIError(403, "clan_closed", "That clan is closed.")
+	ErrClanFull           = newAPIError(403, "clan_full", "That clan is full.")
+	ErrUserNotInClan      = newAPIError(403, "user_not_in_clan", "That user is not in your clan.")
+	ErrInvalidClanName    = newAPIError(400, "invalid_clan_name", "Your clans name must contain alphanumerical characters, spaces, or any of _[]-.")
+	ErrClanNameTaken      = newAPIError(409, "clan_name_taken", "Another clan has already taken this name.")
+	ErrInvalidClanTag     = newAPIError(400, "invalid_clan_tag", "The given tag is too short or too long.")
+	ErrClanTagTaken       = newAPIError(409, "clan_tag_taken", "Another clan has already taken this tag.")
+	ErrInvalidClanIconURL = newAPIError(400, "invalid_icon_url", "The icon must be a link to a JPEG or PNG image.")
+)
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+var errorCodeRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func TestErrorCatalogue(t *testing.T) {
+	errs := ErrorCatalogue()
+	if len(errs) == 0 {
+		t.Fatal("empty error catalogue")
+	}
+	for _, e := range errs {
+		if !errorCodeRegex.MatchString(e.Code) {
+			t.Errorf("error code %q is not snake_case", e.Code)
+		}
+		if e.Status < 400 || e.Status > 599 {
+			t.Errorf("error %s has status %d, which is not an error", e.Code, e.Status)
+		}
+		if e.Message == "" {
+			t.Errorf("error %s has no message", e.Code)
+		}
+	}
+}
+
+func TestAPIErrorJSON(t *testing.T) {
+	var resp CodeMessager = ErrUserNotFound.WithMessage("No such user!")
+	if resp.GetCode() != 404 {
+		t.Errorf("GetCode() = %d, want 404", resp.GetCode())
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":404,"error":"user_not_found","message":"No such user!"}`
+	if string(b) != want {
+		t.Errorf("json.Marshal = %s, want %s", b, want)
+	}
+	if ErrUserNotFound.Message == "No such user!" {
+		t.Error("WithMessage modified the catalogue")
+	}
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Fields is a sparse fieldset: the fields of a response the client asked for
+// in the fields query parameter, as a comma separated list of dot separated
+// paths, e.g. fields=username,stats.std.pp. Each field maps to the fields
+// selected inside of it; an empty Fields means the whole field was selected.
+// A nil Fields selects everything.
+type Fields map[string]Fields
+
+const (
+	maxFields     = 100
+	maxFieldDepth = 8
+)
+
+// ParseFields parses the value of the fields query parameter. An empty value
+// gives a nil Fields.
+func ParseFields(s string) Fields {
+	var f Fields
+	n := 0
+	for _, path := range strings.Split(s, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if n++; n > maxFields {
+			break
+		}
+		if f == nil {
+			f = Fields{}
+		}
+		f.add(strings.SplitN(path, ".", maxFieldDepth))
+	}
+	return f
+}
+
+func (f Fields) add(parts []string) {
+	sub, ok := f[parts[0]]
+	if ok && len(sub) == 0 {
+		// the whole field was already selected.
+		return
+	}
+	if len(parts) == 1 {
+		f[parts[0]] = Fields{}
+		return
+	}
+	if !ok {
+		sub = Fields{}
+		f[parts[0]] = sub
+	}
+	sub.add(parts[1:])
+}
+
+// Has returns whether the field at the dot separated path, or any field
+// inside of it, was selected. Handlers use it to skip the work of filling in
+// fields that would be dropped anyway.
+func (f Fields) Has(path string) bool {
+	if f == nil {
+		return true
+	}
+	for _, part := range strings.Split(path, ".") {
+		if len(f) == 0 {
+			return true
+		}
+		sub, ok := f[part]
+		if !ok {
+			return false
+		}
+		f = sub
+	}
+	return true
+}
+
+// Project removes from the JSON value data all the fields that were not
+// selected. Selections apply to each element of arrays, so scores.pp keeps
+// the pp of every score. The fields kept are in the same order as in data.
+func (f Fields) Project(data []byte) ([]byte, error) {
+	if len(f) == 0 {
+		return data, nil
+	}
+	switch firstByte(data) {
+	case '{':
+		// the object is read key by key, so that the fields keep their order.
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			key, _ := t.(string)
+			sub, ok := f[key]
+			if !ok {
+				continue
+			}
+			if v, err = sub.Project(v); err != nil {
+				return nil, err
+			}
+			if buf.Len() > 1 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			buf.Write(k)
+			buf.WriteByte(':')
+			if err := json.Compact(&buf, v); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	case '[':
+		var arr []json.RawMessage
+		if err := json.Unmarshal(data, &arr); err != nil {
+			return nil, err
+		}
+		for i, v := range arr {
+			v, err := f.Project(v)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return json.Marshal(arr)
+	}
+	return data, nil
+}
+
+func firstByte(data []byte) byte {
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return b
+		}
+	}
+	return 0
+}
+
+// FieldsParam documents the fields query parameter in RouteDocs.
+var FieldsParam = QueryParam("fields", "string",
+	"Comma separated list of the fields to return, using dots for nested fields, e.g. username,stats.std.pp.")
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Fields
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"username", Fields{"username": {}}},
+		{"username, stats.std.pp", Fields{"username": {}, "stats": {"std": {"pp": {}}}}},
+		{"stats.std,stats", Fields{"stats": {}}},
+		{"stats,stats.std", Fields{"stats": {}}},
+		{"stats.std.pp,stats.taiko", Fields{"stats": {"std": {"pp": {}}, "taiko": {}}}},
+	}
+	for _, tt := range tests {
+		if got := ParseFields(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFields(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFieldsHas(t *testing.T) {
+	f := ParseFields("username,stats.std.pp")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"username", true},
+		{"tbadges", false},
+		{"stats", true},
+		{"stats.std", true},
+		{"stats.std.pp", true},
+		{"stats.std.grades", false},
+		{"stats.taiko", false},
+	}
+	for _, tt := range tests {
+		if got := f.Has(tt.path); got != tt.want {
+			t.Errorf("Has(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if !Fields(nil).Has("anything") {
+		t.Error("nil Fields should select everything")
+	}
+}
+
+func TestFieldsProject(t *testing.T) {
+	data := []byte(`{"code":200,"username":"a","tbadges":null,"stats":[{"std":{"pp":1,"accuracy":2},"taiko":{"pp":3}},{"std":{"pp":4}}]}`)
+	tests := []struct {
+		fields string
+		want   string
+	}{
+		{"", string(data)},
+		{"username", `{"username":"a"}`},
+		{"username,tbadges,missing", `{"username":"a","tbadges":null}`},
+		{"tbadges,code,username", `{"code":200,"username":"a","tbadges":null}`},
+		{"stats.std.pp", `{"stats":[{"std":{"pp":1}},{"std":{"pp":4}}]}`},
+		{"username.length", `{"username":"a"}`},
+	}
+	for _, tt := range tests {
+		got, err := ParseFields(tt.fields).Project(data)
+		if err != nil {
+			t.Errorf("Project(%q): %v", tt.fields, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Project(%q) = %s, want %s", tt.fields, got, tt.want)
+		}
+	}
+
+	indented := []byte("{\n\t\"b\": [\n\t\t1,\n\t\t2\n\t],\n\t\"a\": \"x\"\n}")
+	got, err := ParseFields("a,b").Project(indented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b":[1,2],"a":"x"}`; string(got) != want {
+		t.Errorf("Project() of indented JSON = %s, want %s", got, want)
+	}
+}
@@ -1,9 +1,10 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"runtime"
-	"strings"
+	"strconv"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/valyala/fasthttp"
@@ -13,28 +14,49 @@ import (
 
 // MethodData is a struct containing the data passed over to an API method.
 type MethodData struct {
-	User Token
-	DB   *sqlx.DB
-	R    *redis.Client
-	Ctx  *fasthttp.RequestCtx
+	User      Token
+	DB        *sqlx.DB
+	R         *redis.Client
+	Ctx       *fasthttp.RequestCtx
+	RequestID string
+	// Context is cancelled when the deadline of the route is over, or when
+	// the server shuts down and the request did not complete in time. It must
+	// be given to all the database queries. The redis client of this version
+	// can't be given a context, and R is not cancelled with it.
+	Context context.Context
+	// Fields are the fields of the response requested by the client.
+	Fields Fields
 }
 
-// ClientIP implements a best effort algorithm to return the real client IP, it parses
-// X-Real-IP and X-Forwarded-For in order to work properly with reverse-proxies such us: nginx or haproxy.
-func (md MethodData) ClientIP() string {
-	clientIP := strings.TrimSpace(string(md.Ctx.Request.Header.Peek("X-Real-Ip")))
-	if len(clientIP) > 0 {
-		return clientIP
-	}
-	clientIP = string(md.Ctx.Request.Header.Peek("X-Forwarded-For"))
-	if index := strings.IndexByte(clientIP, ','); index >= 0 {
-		clientIP = clientIP[0:index]
+// RequestIDKey is the user value of the RequestCtx holding the ID of the
+// request, which is also sent back in the X-Request-ID header.
+const RequestIDKey = "request_id"
+
+// RouteKey is the user value of the RequestCtx holding the route template the
+// request was matched against.
+const RouteKey = "route"
+
+// Route retrieves the route template the request was matched against.
+func Route(c *fasthttp.RequestCtx) string {
+	if c == nil {
+		return ""
 	}
-	clientIP = strings.TrimSpace(clientIP)
-	if len(clientIP) > 0 {
-		return clientIP
+	route, _ := c.UserValue(RouteKey).(string)
+	return route
+}
+
+// RequestID retrieves the ID of the request, if one was assigned.
+func RequestID(c *fasthttp.RequestCtx) string {
+	if c == nil {
+		return ""
 	}
-	return md.Ctx.RemoteIP().String()
+	id, _ := c.UserValue(RequestIDKey).(string)
+	return id
+}
+
+// ClientIP returns the real IP of the client, see the ClientIP function.
+func (md MethodData) ClientIP() string {
+	return ClientIP(md.Ctx)
 }
 
 // Err logs to stdout when there is an error
@@ -42,7 +64,8 @@ func (md MethodData) Err(err error) {
 	// Generate tags for error
 	tags := map[string]string{
 		"endpoint": string(md.Ctx.RequestURI()),
-		"token":    md.User.Value,
+		"user_id":  strconv.Itoa(md.User.UserID),
+		"token_id": strconv.Itoa(md.User.ID),
 	}
 
 	_err(err, tags, md.Ctx)
@@ -64,12 +87,21 @@ func GenericError(err error) {
 }
 
 func _err(err error, tags map[string]string, c *fasthttp.RequestCtx) {
-	_, file, no, ok := runtime.Caller(2)
-	if ok {
-		slog.ErrorContext(c, "An error occurred", "filename", file, "line", no, "error", err.Error())
-	} else {
-		slog.ErrorContext(c, "An error occurred", "error", err.Error())
+	args := []any{"error", err.Error()}
+	if _, file, no, ok := runtime.Caller(2); ok {
+		args = append(args, "filename", file, "line", no)
+	}
+	if id := RequestID(c); id != "" {
+		args = append(args, "request_id", id)
+	}
+	for k, v := range tags {
+		args = append(args, k, v)
+	}
+	if c == nil {
+		slog.Error("An error occurred", args...)
+		return
 	}
+	slog.ErrorContext(c, "An error occurred", args...)
 }
 
 // ID retrieves the Token's owner user ID.
@@ -89,6 +121,8 @@ func (md MethodData) HasQuery(q string) bool {
 }
 
 // Unmarshal unmarshals a request's JSON body into an interface.
+//
+// Deprecated: use ParseBody, which also validates the body.
 func (md MethodData) Unmarshal(into interface{}) error {
 	return json.Unmarshal(md.Ctx.PostBody(), into)
 }
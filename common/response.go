@@ -1,8 +1,10 @@
 package common
 
 // ResponseBase is the data that is always returned with an API request.
+// Error is set on errors to the code of the APIError, see ErrorCatalogue.
 type ResponseBase struct {
 	Code    int    `json:"code"`
+	Error   string `json:"error,omitempty"`
 	Message string `json:"message,omitempty"`
 }
 
@@ -0,0 +1,79 @@
+package common
+
+import "time"
+
+// RouteOption is additional information given when registering a route:
+// either a Scope the route requires, a Timeout, or the RouteDoc documenting
+// it.
+type RouteOption interface {
+	routeOption()
+}
+
+func (Scope) routeOption()    {}
+func (Timeout) routeOption()  {}
+func (RouteDoc) routeOption() {}
+
+// Timeout is how long the API method of a route has to complete, overriding
+// the default REQUEST_TIMEOUT. Once it is over, the context of the request is
+// cancelled.
+type Timeout time.Duration
+
+// RouteDoc documents a route, and is used to generate the OpenAPI document.
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Params      []Param
+	// Request is a value of the type of the JSON body of the request, if any.
+	Request interface{}
+	// Response is a value of the type of the successful response.
+	Response interface{}
+}
+
+// Param is a query string parameter of a route, or a header if In is set.
+type Param struct {
+	Name string
+	// In is where the parameter is given: query, if empty, or header.
+	In string
+	// Type is the OpenAPI type of the parameter: string, integer, number or
+	// boolean.
+	Type        string
+	Description string
+	Required    bool
+	// Multi means the parameter can be given more than once.
+	Multi bool
+}
+
+// QueryParam creates an optional query string parameter.
+func QueryParam(name, typ, description string) Param {
+	return Param{Name: name, Type: typ, Description: description}
+}
+
+// RequiredParam creates a required query string parameter.
+func RequiredParam(name, typ, description string) Param {
+	return Param{Name: name, Type: typ, Description: description, Required: true}
+}
+
+// HeaderParam creates an optional header.
+func HeaderParam(name, typ, description string) Param {
+	return Param{Name: name, In: "header", Type: typ, Description: description}
+}
+
+// MultiParam creates a query string parameter that can be repeated.
+func MultiParam(name, typ, description string) Param {
+	return Param{Name: name, Type: typ, Description: description, Multi: true}
+}
+
+// PaginationParams are the parameters of the routes using Paginate.
+var PaginationParams = []Param{
+	QueryParam("p", "integer", "The page, starting from 1."),
+	QueryParam("l", "integer", "The number of results per page."),
+}
+
+// Params concatenates lists of parameters.
+func Params(lists ...[]Param) []Param {
+	var r []Param
+	for _, l := range lists {
+		r = append(r, l...)
+	}
+	return r
+}
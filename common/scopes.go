@@ -0,0 +1,162 @@
+package common
+
+import "strings"
+
+// Scope is the name of an OAuth scope. There is a scope for each of the
+// Privileges, and some finer-grained scopes which only grant access to part
+// of what a privilege does. A token holding a privilege also holds all the
+// finer-grained scopes that are part of it.
+//
+// tokens.manage is neither: it is only held by the user's own API tokens,
+// and can't be granted to OAuth clients, so that no application can revoke
+// or widen the tokens of the user.
+type Scope string
+
+// These are the scopes that can be requested by OAuth clients, and that routes
+// can require.
+const (
+	ScopeRead             Scope = "read"
+	ScopeReadConfidential Scope = "read_confidential"
+	ScopeWrite            Scope = "write"
+	ScopeManageBadges     Scope = "manage_badges"
+	ScopeBetaKeys         Scope = "beta_keys"
+	ScopeManageSettings   Scope = "manage_settings"
+	ScopeViewUserAdvanced Scope = "view_user_advanced"
+	ScopeManageUser       Scope = "manage_user"
+	ScopeManageRoles      Scope = "manage_roles"
+	ScopeManageAPIKeys    Scope = "manage_api_keys"
+	ScopeBlog             Scope = "blog"
+	ScopeAPIMeta          Scope = "api_meta"
+	ScopeBeatmap          Scope = "beatmap"
+
+	ScopeFriendsRead       Scope = "friends.read"
+	ScopeSettingsRead      Scope = "settings.read"
+	ScopeConnectionsLink   Scope = "connections.link"
+	ScopeFriendsWrite      Scope = "friends.write"
+	ScopeScoresPin         Scope = "scores.pin"
+	ScopeClansManage       Scope = "clans.manage"
+	ScopeSettingsWrite     Scope = "settings.write"
+	ScopeConnectionsUnlink Scope = "connections.unlink"
+	ScopeTokensManage      Scope = "tokens.manage"
+)
+
+// ScopeInfo describes a Scope.
+type ScopeInfo struct {
+	Scope Scope `json:"scope"`
+	// Parent is the scope this scope is a subset of, if any.
+	Parent Scope `json:"parent,omitempty"`
+	// Privilege is the privilege the scope is part of. It is 0 for the scopes
+	// OAuth clients can't request.
+	Privilege   Privileges `json:"privilege"`
+	Description string     `json:"description"`
+}
+
+var scopeCatalogue = [...]ScopeInfo{
+	{ScopeRead, "", PrivilegeRead, "Read public data. Deprecated, as public data needs no token."},
+	{ScopeReadConfidential, "", PrivilegeReadConfidential, "Read your private information, such as your friends, settings and linked accounts."},
+	{ScopeWrite, "", PrivilegeWrite, "Change your information and act on your behalf."},
+	{ScopeManageBadges, "", PrivilegeManageBadges, "Create badges and give them to users."},
+	{ScopeBetaKeys, "", PrivilegeBetaKeys, "Manage beta keys."},
+	{ScopeManageSettings, "", PrivilegeManageSettings, "Change the server settings, such as maintenance mode and global alerts."},
+	{ScopeViewUserAdvanced, "", PrivilegeViewUserAdvanced, "See private information of any user, such as their email."},
+	{ScopeManageUser, "", PrivilegeManageUser, "Restrict, ban and change the information of any user."},
+	{ScopeManageRoles, "", PrivilegeManageRoles, "Give and take away privileges from any user."},
+	{ScopeManageAPIKeys, "", PrivilegeManageAPIKeys, "Manage the API tokens of any user."},
+	{ScopeBlog, "", PrivilegeBlog, "Manage the blog and the documentation."},
+	{ScopeAPIMeta, "", PrivilegeAPIMeta, "Inspect and control the API server."},
+	{ScopeBeatmap, "", PrivilegeBeatmap, "Change the ranked status of beatmaps."},
+
+	{ScopeFriendsRead, ScopeReadConfidential, PrivilegeReadConfidential, "See your friends and followers."},
+	{ScopeSettingsRead, ScopeReadConfidential, PrivilegeReadConfidential, "See your settings and supporter status."},
+	{ScopeConnectionsLink, ScopeReadConfidential, PrivilegeReadConfidential, "Link your Discord, Twitch and osu! accounts."},
+	{ScopeFriendsWrite, ScopeWrite, PrivilegeWrite, "Add and remove friends."},
+	{ScopeScoresPin, ScopeWrite, PrivilegeWrite, "Pin and unpin your scores."},
+	{ScopeClansManage, ScopeWrite, PrivilegeWrite, "Join, leave and manage your clan."},
+	{ScopeSettingsWrite, ScopeWrite, PrivilegeWrite, "Change your settings and userpage."},
+	{ScopeConnectionsUnlink, ScopeWrite, PrivilegeWrite, "Unlink your Discord, Twitch and osu! accounts."},
+
+	{ScopeTokensManage, "", 0, "Edit and revoke your API tokens and authorized applications. Can't be granted to OAuth applications."},
+}
+
+var scopeIndex = func() map[Scope]ScopeInfo {
+	m := make(map[Scope]ScopeInfo, len(scopeCatalogue))
+	for _, s := range scopeCatalogue {
+		m[s.Scope] = s
+	}
+	return m
+}()
+
+// Scopes returns every scope, in the order they should be shown to users.
+func Scopes() []ScopeInfo {
+	s := make([]ScopeInfo, len(scopeCatalogue))
+	copy(s, scopeCatalogue[:])
+	return s
+}
+
+// LookupScope retrieves the information about a scope.
+func LookupScope(s Scope) (ScopeInfo, bool) {
+	i, ok := scopeIndex[s]
+	return i, ok
+}
+
+// OAuthScopeKnown tells whether scope is a scope that can be requested by
+// OAuth clients.
+func OAuthScopeKnown(scope string) bool {
+	s, ok := scopeIndex[Scope(scope)]
+	return ok && s.Privilege != 0
+}
+
+// OAuthPrivileges returns the equivalent in Privileges of a space-separated
+// list of scopes. Finer-grained scopes are not included, see OAuthFineScopes.
+func OAuthPrivileges(scopes string) Privileges {
+	var p Privileges
+	for _, x := range strings.Fields(scopes) {
+		if s, ok := scopeIndex[Scope(x)]; ok && s.Parent == "" {
+			p |= s.Privilege
+		}
+	}
+	return p
+}
+
+// OAuthFineScopes returns the finer-grained scopes in a space-separated list
+// of scopes, leaving out those the user can't have due to their rank.
+func OAuthFineScopes(scopes string, userPrivs UserPrivileges) []Scope {
+	var r []Scope
+	for _, x := range strings.Fields(scopes) {
+		s, ok := scopeIndex[Scope(x)]
+		if ok && s.Parent != "" && s.Privilege.CanOnly(userPrivs) != 0 {
+			r = append(r, s.Scope)
+		}
+	}
+	return r
+}
+
+// HasScope tells whether the token grants the given scope, either because it
+// has the privilege the scope is part of, or because it was granted the scope
+// itself.
+func (t Token) HasScope(s Scope) bool {
+	info, ok := scopeIndex[s]
+	if !ok {
+		return false
+	}
+	if t.TokenPrivileges&info.Privilege != 0 {
+		return true
+	}
+	for _, x := range t.Scopes {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantedScopes returns all the scopes the token grants.
+func (t Token) GrantedScopes() []Scope {
+	r := make([]Scope, 0, len(scopeCatalogue))
+	for _, s := range scopeCatalogue {
+		if t.HasScope(s.Scope) {
+			r = append(r, s.Scope)
+		}
+	}
+	return r
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOAuthPrivileges(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes string
+		want   Privileges
+	}{
+		{"empty", "", 0},
+		{"single", "write", PrivilegeWrite},
+		{"multiple", "read_confidential write beatmap", PrivilegeReadConfidential | PrivilegeWrite | PrivilegeBeatmap},
+		{"fine scopes are not privileges", "friends.write scores.pin", 0},
+		{"unknown", "write nonexistent", PrivilegeWrite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OAuthPrivileges(tt.scopes); got != tt.want {
+				t.Errorf("OAuthPrivileges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOAuthScopeKnown(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"write", true},
+		{"friends.write", true},
+		{"tokens.manage", false},
+		{"nonexistent", false},
+	}
+	for _, tt := range tests {
+		if got := OAuthScopeKnown(tt.scope); got != tt.want {
+			t.Errorf("OAuthScopeKnown(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestOAuthFineScopes(t *testing.T) {
+	got := OAuthFineScopes("write friends.write nonexistent tokens.manage scores.pin", UserPrivilegeNormal)
+	want := []Scope{ScopeFriendsWrite, ScopeScoresPin}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OAuthFineScopes() = %v, want %v", got, want)
+	}
+	if got := OAuthFineScopes("friends.write", 0); got != nil {
+		t.Errorf("OAuthFineScopes() with no privileges = %v, want nil", got)
+	}
+}
+
+func TestTokenHasScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		token Token
+		scope Scope
+		want  bool
+	}{
+		{"privilege", Token{TokenPrivileges: PrivilegeWrite}, ScopeWrite, true},
+		{"fine scope through privilege", Token{TokenPrivileges: PrivilegeWrite}, ScopeFriendsWrite, true},
+		{"fine scope granted", Token{Scopes: []Scope{ScopeFriendsWrite}}, ScopeFriendsWrite, true},
+		{"fine scope does not grant privilege", Token{Scopes: []Scope{ScopeFriendsWrite}}, ScopeWrite, false},
+		{"other fine scope", Token{Scopes: []Scope{ScopeFriendsWrite}}, ScopeClansManage, false},
+		{"unknown", Token{TokenPrivileges: PrivilegeWrite}, Scope("nonexistent"), false},
+		{"tokens.manage not implied by write", Token{TokenPrivileges: PrivilegeWrite}, ScopeTokensManage, false},
+		{"tokens.manage granted", Token{Scopes: []Scope{ScopeTokensManage}}, ScopeTokensManage, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
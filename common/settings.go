@@ -15,6 +15,16 @@ func getEnv(key string) string {
 	return val
 }
 
+// getEnvDefault is like getEnv, but returns def instead of panicking if the
+// variable is not set. Use it for settings which have a sensible default.
+func getEnvDefault(key, def string) string {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return def
+	}
+	return val
+}
+
 func strToInt(s string) int {
 	val, _ := strconv.Atoi(s)
 	return val
@@ -26,8 +36,9 @@ func strToBool(s string) bool {
 }
 
 type Settings struct {
-	APP_PORT   int
-	APP_DOMAIN string
+	APP_PORT     int
+	APP_DOMAIN   string
+	METRICS_PORT int
 
 	HANAYO_KEY string
 
@@ -58,6 +69,30 @@ type Settings struct {
 	OSU_OAUTH_CLIENT_ID     string
 	OSU_OAUTH_CLIENT_SECRET string
 	OSU_OAUTH_REDIRECT_URI  string
+
+	TRUSTED_PROXIES string
+
+	RATE_LIMIT_ENABLED bool
+	RATE_LIMITS        string
+	RATE_LIMITS_ROUTES string
+
+	TOKEN_CACHE_TTL  int
+	TOKEN_CACHE_SIZE int
+
+	SHUTDOWN_DRAIN_DELAY int
+	SHUTDOWN_TIMEOUT     int
+
+	CORS_ALLOWED_ORIGINS   string
+	CORS_ALLOW_CREDENTIALS bool
+	CORS_EXPOSED_HEADERS   string
+	CORS_MAX_AGE           int
+
+	BATCH_MAX_REQUESTS int
+	BATCH_TIMEOUT      int
+
+	REQUEST_TIMEOUT int
+
+	IDEMPOTENCY_TTL int
 }
 
 var settings = Settings{}
@@ -67,6 +102,7 @@ func LoadSettings() Settings {
 
 	settings.APP_PORT = strToInt(getEnv("APP_PORT"))
 	settings.APP_DOMAIN = getEnv("APP_DOMAIN")
+	settings.METRICS_PORT = strToInt(getEnvDefault("METRICS_PORT", "0"))
 
 	settings.HANAYO_KEY = getEnv("HANAYO_KEY")
 
@@ -98,6 +134,41 @@ func LoadSettings() Settings {
 	settings.OSU_OAUTH_CLIENT_SECRET = getEnv("OSU_OAUTH_CLIENT_SECRET")
 	settings.OSU_OAUTH_REDIRECT_URI = getEnv("OSU_OAUTH_REDIRECT_URI")
 
+	settings.TRUSTED_PROXIES = getEnvDefault("TRUSTED_PROXIES",
+		"127.0.0.0/8, ::1, 10.0.0.0/8, 172.16.0.0/12, 192.168.0.0/16, fc00::/7")
+	proxies, err := parseNetworks(settings.TRUSTED_PROXIES)
+	if err != nil {
+		panic("Invalid TRUSTED_PROXIES: " + err.Error())
+	}
+	trustedProxies = proxies
+
+	settings.RATE_LIMIT_ENABLED = strToBool(getEnvDefault("RATE_LIMIT_ENABLED", "true"))
+	settings.RATE_LIMITS = getEnvDefault("RATE_LIMITS", "anonymous=2/60 user=5/120 supporter=10/240 staff=0 ip=20/480")
+	settings.RATE_LIMITS_ROUTES = getEnvDefault("RATE_LIMITS_ROUTES",
+		"/api/v1/users/full: anonymous=0.5/20 user=1/40 supporter=2/60; "+
+			"/api/v1/leaderboard: anonymous=0.5/20 user=1/40 supporter=2/60; "+
+			"/api/v1/users/lookup: anonymous=1/20 user=2/40 supporter=4/60; "+
+			"/api/v1/clans/stats/all: anonymous=0.5/10 user=1/20 supporter=2/40")
+
+	settings.TOKEN_CACHE_TTL = strToInt(getEnvDefault("TOKEN_CACHE_TTL", "30"))
+	settings.TOKEN_CACHE_SIZE = strToInt(getEnvDefault("TOKEN_CACHE_SIZE", "10000"))
+
+	settings.SHUTDOWN_DRAIN_DELAY = strToInt(getEnvDefault("SHUTDOWN_DRAIN_DELAY", "5"))
+	settings.SHUTDOWN_TIMEOUT = strToInt(getEnvDefault("SHUTDOWN_TIMEOUT", "30"))
+
+	settings.CORS_ALLOWED_ORIGINS = getEnvDefault("CORS_ALLOWED_ORIGINS", "")
+	settings.CORS_ALLOW_CREDENTIALS = strToBool(getEnvDefault("CORS_ALLOW_CREDENTIALS", "false"))
+	settings.CORS_EXPOSED_HEADERS = getEnvDefault("CORS_EXPOSED_HEADERS",
+		"X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After, ETag, X-Cache, Idempotent-Replayed")
+	settings.CORS_MAX_AGE = strToInt(getEnvDefault("CORS_MAX_AGE", "600"))
+
+	settings.BATCH_MAX_REQUESTS = strToInt(getEnvDefault("BATCH_MAX_REQUESTS", "10"))
+	settings.BATCH_TIMEOUT = strToInt(getEnvDefault("BATCH_TIMEOUT", "10"))
+
+	settings.REQUEST_TIMEOUT = strToInt(getEnvDefault("REQUEST_TIMEOUT", "10"))
+
+	settings.IDEMPOTENCY_TTL = strToInt(getEnvDefault("IDEMPOTENCY_TTL", "86400"))
+
 	return settings
 }
 
@@ -0,0 +1,174 @@
+package common
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Maintenance scopes, telling which requests are refused while maintenance
+// mode is on. Staff can always make any request.
+const (
+	// MaintenanceWrites refuses the requests changing data, that is to say
+	// anything but GET.
+	MaintenanceWrites = "writes"
+	// MaintenanceAll refuses every request.
+	MaintenanceAll = "all"
+)
+
+// SystemSettings are the settings of the server that can be changed while it
+// is running, stored in the system_settings table (see
+// migrations/007_system_settings_global_alerts.sql).
+type SystemSettings struct {
+	// Maintenance is whether maintenance mode is on, for the website and the
+	// API.
+	Maintenance bool `json:"website_maintenance"`
+	// MaintenanceScope is which API requests are refused during maintenance:
+	// MaintenanceWrites or MaintenanceAll.
+	MaintenanceScope     string `json:"api_maintenance_scope"`
+	RegistrationsEnabled bool   `json:"registrations_enabled"`
+}
+
+// systemSetting describes how a field of SystemSettings is stored in the
+// system_settings table: in value_int for booleans, or value_string.
+type systemSetting struct {
+	name string
+	get  func(s *SystemSettings) interface{}
+}
+
+// value returns how the setting is stored in the table.
+func (setting systemSetting) value(s *SystemSettings) (valueInt int, valueStr string) {
+	switch v := setting.get(s).(type) {
+	case *bool:
+		if *v {
+			valueInt = 1
+		}
+	case *string:
+		valueStr = *v
+	}
+	return valueInt, valueStr
+}
+
+var systemSettingsTable = [...]systemSetting{
+	{"website_maintenance", func(s *SystemSettings) interface{} { return &s.Maintenance }},
+	{"api_maintenance_scope", func(s *SystemSettings) interface{} { return &s.MaintenanceScope }},
+	{"registrations_enabled", func(s *SystemSettings) interface{} { return &s.RegistrationsEnabled }},
+}
+
+var (
+	systemSettings   = SystemSettings{MaintenanceScope: MaintenanceWrites, RegistrationsEnabled: true}
+	systemSettingsMu sync.RWMutex
+)
+
+// GetSystemSettings returns the system settings as they were last loaded by
+// LoadSystemSettings.
+func GetSystemSettings() SystemSettings {
+	systemSettingsMu.RLock()
+	defer systemSettingsMu.RUnlock()
+	return systemSettings
+}
+
+// LoadSystemSettings loads the system settings from the database. Settings
+// missing from the table keep their default value.
+func LoadSystemSettings(ctx context.Context, db *sqlx.DB) (SystemSettings, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, value_int, value_string FROM system_settings")
+	if err != nil {
+		return GetSystemSettings(), err
+	}
+	s, _, err := scanSystemSettings(rows)
+	if err != nil {
+		return GetSystemSettings(), err
+	}
+	setSystemSettings(s)
+	return s, nil
+}
+
+// UpdateSystemSettings changes the system settings with change, and makes
+// them the current ones. The settings are read and written in a transaction
+// locking them, so that concurrent updates are applied one after the other,
+// and only those which were changed are written. The other API instances see
+// the change the next time they load the settings.
+func UpdateSystemSettings(ctx context.Context, db *sqlx.DB, change func(s *SystemSettings)) (before, after SystemSettings, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return before, after, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	rows, err := tx.QueryContext(ctx, "SELECT name, value_int, value_string FROM system_settings FOR UPDATE")
+	if err != nil {
+		return before, after, err
+	}
+	before, stored, err := scanSystemSettings(rows)
+	if err != nil {
+		return before, after, err
+	}
+	after = before
+	change(&after)
+
+	for _, setting := range systemSettingsTable {
+		valueInt, valueStr := setting.value(&after)
+		if oldInt, oldStr := setting.value(&before); oldInt == valueInt && oldStr == valueStr {
+			continue
+		}
+		if stored[setting.name] {
+			_, err = tx.ExecContext(ctx, "UPDATE system_settings SET value_int = ?, value_string = ? WHERE name = ?",
+				valueInt, valueStr, setting.name)
+		} else {
+			_, err = tx.ExecContext(ctx, "INSERT INTO system_settings(name, value_int, value_string) VALUES (?, ?, ?)",
+				setting.name, valueInt, valueStr)
+		}
+		if err != nil {
+			return before, after, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return before, after, err
+	}
+	setSystemSettings(after)
+	return before, after, nil
+}
+
+// scanSystemSettings reads the rows of the system_settings table, closing
+// them. stored tells which settings have a row.
+func scanSystemSettings(rows *sql.Rows) (s SystemSettings, stored map[string]bool, err error) {
+	defer rows.Close()
+	s = SystemSettings{MaintenanceScope: MaintenanceWrites, RegistrationsEnabled: true}
+	stored = make(map[string]bool)
+	for rows.Next() {
+		var (
+			name     string
+			valueInt int
+			valueStr string
+		)
+		if err := rows.Scan(&name, &valueInt, &valueStr); err != nil {
+			return s, nil, err
+		}
+		for _, setting := range systemSettingsTable {
+			if setting.name != name {
+				continue
+			}
+			stored[name] = true
+			switch v := setting.get(&s).(type) {
+			case *bool:
+				*v = valueInt != 0
+			case *string:
+				if valueStr != "" {
+					*v = valueStr
+				}
+			}
+		}
+	}
+	return s, stored, rows.Err()
+}
+
+func setSystemSettings(s SystemSettings) {
+	systemSettingsMu.Lock()
+	systemSettings = s
+	systemSettingsMu.Unlock()
+}
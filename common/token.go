@@ -9,6 +9,9 @@ type Token struct {
 	UserID          int
 	TokenPrivileges Privileges
 	UserPrivileges  UserPrivileges
+	// Scopes are the finer-grained scopes the token was granted on top of
+	// TokenPrivileges. Only OAuth tokens have them.
+	Scopes []Scope
 }
 
 // OnlyUserPublic returns a string containing "(user.privileges & 1 = 1 OR users.id = <userID>)"
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"strconv"
+
+	"gopkg.in/redis.v5"
+)
+
+// TokenInvalidationChannel is the redis channel every API instance listens
+// on to drop tokens from its in-memory token cache. Messages are either
+// "token:<token ID>", "bearer:<sha256 of the access token>" or
+// "user:<user ID>", the latter dropping every token of the user, and should be
+// published whenever a token is deleted or the privileges of a user change.
+const TokenInvalidationChannel = "api:token_cache:invalidate"
+
+// InvalidateToken drops the API token with the given ID from the token caches.
+func InvalidateToken(r *redis.Client, id int) error {
+	return r.Publish(TokenInvalidationChannel, "token:"+strconv.Itoa(id)).Err()
+}
+
+// InvalidateBearerToken drops the OAuth access token with the given hash
+// from the token caches.
+func InvalidateBearerToken(r *redis.Client, hash string) error {
+	return r.Publish(TokenInvalidationChannel, "bearer:"+hash).Err()
+}
+
+// InvalidateUserTokens drops every token of the user from the token caches.
+func InvalidateUserTokens(r *redis.Client, userID int) error {
+	return r.Publish(TokenInvalidationChannel, "user:"+strconv.Itoa(userID)).Err()
+}
+
+// InvalidateToken drops the API token with the given ID from the token caches,
+// logging any error.
+func (md MethodData) InvalidateToken(id int) {
+	if err := InvalidateToken(md.R, id); err != nil {
+		md.Err(err)
+	}
+}
+
+// InvalidateBearerToken drops the OAuth access token with the given hash
+// from the token caches, logging any error.
+func (md MethodData) InvalidateBearerToken(hash string) {
+	if err := InvalidateBearerToken(md.R, hash); err != nil {
+		md.Err(err)
+	}
+}
+
+// InvalidateUserTokens drops every token of the user from the token caches,
+// logging any error.
+func (md MethodData) InvalidateUserTokens(userID int) {
+	if err := InvalidateUserTokens(md.R, userID); err != nil {
+		md.Err(err)
+	}
+}
@@ -21,12 +21,34 @@ func (u *UpdateQuery) Add(field string, value interface{}) *UpdateQuery {
 	if s, ok := value.(string); ok && s == "" {
 		return u
 	}
-	u.fields = append(u.fields, field+" = ?")
+	u.fields = append(u.fields, field)
 	u.Parameters = append(u.Parameters, value)
 	return u
 }
 
 // Fields retrieves the fields joined by a comma.
 func (u *UpdateQuery) Fields() string {
-	return strings.Join(u.fields, ", ")
+	if len(u.fields) == 0 {
+		return ""
+	}
+	return strings.Join(u.fields, " = ?, ") + " = ?"
+}
+
+// Columns retrieves the names of the fields being updated.
+func (u *UpdateQuery) Columns() []string {
+	return u.fields
+}
+
+// Changes maps the fields being updated to their new value, for the audit
+// log.
+func (u *UpdateQuery) Changes() map[string]interface{} {
+	m := make(map[string]interface{}, len(u.fields))
+	for i, f := range u.fields {
+		v := reflect.ValueOf(u.Parameters[i])
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		m[f] = v.Interface()
+	}
+	return m
 }
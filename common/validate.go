@@ -0,0 +1,308 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBodySize is the maximum size of the JSON body of a request, in bytes.
+const MaxBodySize = 1 << 20
+
+// FieldError describes why a field of a request body is invalid.
+type FieldError struct {
+	// Field is the path to the field, e.g. custom_badge.name.
+	Field string `json:"field"`
+	// Rule is the rule the field breaks: required, min, max, len, oneof,
+	// numeric, type or unknown.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the response to a request whose body is invalid.
+type ValidationErrorResponse struct {
+	ResponseBase
+	Fields []FieldError `json:"fields"`
+}
+
+// ErrInvalidFields is returned when the fields of a request body are not
+// valid. The response lists the fields and what is wrong with them.
+var (
+	ErrInvalidFields = newAPIError(422, "invalid_fields", "Some parameters are invalid.")
+	ErrBodyTooLarge  = newAPIError(413, "body_too_large", "The body of the request is too large.")
+)
+
+// InvalidFields creates the response to a request with the given invalid
+// fields. If all the fields are missing, the error is ErrMissingField, with
+// the same message as ErrMissingFields.
+func InvalidFields(errs ...FieldError) CodeMessager {
+	e := ErrInvalidFields
+	missing := make([]string, 0, len(errs))
+	msgs := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		if fe.Rule == "required" {
+			missing = append(missing, fe.Field)
+		}
+		msgs = append(msgs, fe.Field+" "+fe.Message)
+	}
+	if len(missing) == len(errs) {
+		e = ErrMissingFields(missing...)
+	} else {
+		e = e.WithMessage("Invalid parameters: " + strings.Join(msgs, "; ") + ".")
+	}
+	return ValidationErrorResponse{
+		ResponseBase: ResponseBase{Code: e.Status, Error: e.Code, Message: e.Message},
+		Fields:       errs,
+	}
+}
+
+// ParseBody decodes the JSON body of the request into into, which must be a
+// pointer to a struct, and validates it. Unknown fields are rejected, and the
+// fields are checked against the rules in their validate tag, which is a
+// comma separated list of:
+//
+//   - required: the field must not be the zero value (for pointers, it must
+//     not be null)
+//   - min=n, max=n: the minimum and maximum value of numbers, or length of
+//     strings and slices
+//   - len=n: the exact length of strings and slices
+//   - oneof=a b c: the value must be one of the space separated values
+//   - numeric: the string must be made of digits only
+//
+// Rules other than required are not checked on zero values and nil pointers,
+// so optional fields can be omitted. Nested structs are validated as well.
+// ParseBody returns nil if the body is valid, or the response to send.
+func (md MethodData) ParseBody(into interface{}) CodeMessager {
+	body := md.Ctx.PostBody()
+	if len(body) > MaxBodySize {
+		return ErrBodyTooLarge
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(into); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// more than one JSON value in the body.
+		return ErrBadJSON
+	}
+
+	if errs := Validate(into); len(errs) > 0 {
+		return InvalidFields(errs...)
+	}
+	return nil
+}
+
+func decodeError(err error) CodeMessager {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return ErrBadJSON
+		}
+		return InvalidFields(FieldError{
+			Field:   field,
+			Rule:    "type",
+			Message: "must be " + jsonTypeName(typeErr.Type),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return InvalidFields(FieldError{
+			Field:   field,
+			Rule:    "unknown",
+			Message: "is not a known field",
+		})
+	}
+	return ErrBadJSON
+}
+
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// Validate checks the struct pointed to by v against the rules in the
+// validate tags of its fields, as described in ParseBody.
+func Validate(v interface{}) []FieldError {
+	var errs []FieldError
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, errs *[]FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+}
+
+func validateStruct(v reflect.Value, path string, errs *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			// promoted fields.
+			validateValue(fv, path, errs)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		if fe, ok := checkRules(fv, f.Tag.Get("validate")); !ok {
+			fe.Field = name
+			*errs = append(*errs, fe)
+			continue
+		}
+		validateValue(fv, name, errs)
+	}
+}
+
+// checkRules checks a value against the rules of a validate tag, returning
+// false and the rule that was broken if it is not valid.
+func checkRules(v reflect.Value, tag string) (FieldError, bool) {
+	if tag == "" {
+		return FieldError{}, true
+	}
+	// the rules of pointers apply to what they point to, even when it is the
+	// zero value: the field was given.
+	zero := v.IsZero()
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "required" {
+			if zero {
+				return FieldError{Rule: rule, Message: "is required"}, false
+			}
+			continue
+		}
+		if zero {
+			continue
+		}
+		if msg, ok := checkRule(v, rule, arg); !ok {
+			return FieldError{Rule: rule, Message: msg}, false
+		}
+	}
+	return FieldError{}, true
+}
+
+func checkRule(v reflect.Value, rule, arg string) (string, bool) {
+	switch rule {
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic("invalid validate tag: " + rule + "=" + arg)
+		}
+		size, isLength := sizeOf(v)
+		what := ""
+		if isLength {
+			what = " characters"
+			if v.Kind() != reflect.String {
+				what = " elements"
+			}
+		}
+		switch {
+		case rule == "min" && size < n:
+			return "must be at least " + arg + what, false
+		case rule == "max" && size > n:
+			return "must be at most " + arg + what, false
+		case rule == "len" && size != n:
+			return "must be " + arg + what + " long", false
+		}
+	case "oneof":
+		s := valueString(v)
+		for _, allowed := range strings.Fields(arg) {
+			if s == allowed {
+				return "", true
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(arg), ", "), false
+	case "numeric":
+		s := valueString(v)
+		for _, c := range s {
+			if c < '0' || c > '9' {
+				return "must be a number", false
+			}
+		}
+	default:
+		panic("unknown validate rule: " + rule)
+	}
+	return "", true
+}
+
+// sizeOf returns the value of numbers, and the length of strings and slices.
+func sizeOf(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	}
+	return 0, false
+}
+
+func valueString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type validateBadge struct {
+	Name string `json:"name" validate:"max=5"`
+}
+
+type validateData struct {
+	ID     string          `json:"id" validate:"required,numeric"`
+	Mode   int             `json:"mode" validate:"oneof=0 1 2 3"`
+	Size   *int            `json:"size" validate:"min=1,max=100"`
+	Title  *string         `json:"title" validate:"required"`
+	Tags   []string        `json:"tags" validate:"max=2"`
+	Badges []validateBadge `json:"badges"`
+	validateBadge
+}
+
+func parseBody(body string, into interface{}) CodeMessager {
+	var c fasthttp.RequestCtx
+	c.Request.SetBodyString(body)
+	return MethodData{Ctx: &c}.ParseBody(into)
+}
+
+func TestParseBody(t *testing.T) {
+	tests := []struct {
+		body   string
+		code   string
+		fields []FieldError
+	}{
+		{`{"id":"1","title":""}`, "", nil},
+		{`{"id":"1","title":"","mode":3,"size":100,"tags":["a","b"],"name":"abcde"}`, "", nil},
+		{``, "missing_fields", []FieldError{
+			{"id", "required", "is required"},
+			{"title", "required", "is required"},
+		}},
+		{`{"id":"1","title":"","mode":4}`, "invalid_fields", []FieldError{
+			{"mode", "oneof", "must be one of 0, 1, 2, 3"},
+		}},
+		{`{"id":"1a","title":"","size":0}`, "invalid_fields", []FieldError{
+			{"id", "numeric", "must be a number"},
+			{"size", "min", "must be at least 1"},
+		}},
+		{`{"id":"1","title":"","tags":["a","b","c"],"badges":[{"name":"abcdef"}],"name":"abcdef"}`, "invalid_fields", []FieldError{
+			{"tags", "max", "must be at most 2 elements"},
+			{"badges[0].name", "max", "must be at most 5 characters"},
+			{"name", "max", "must be at most 5 characters"},
+		}},
+		{`{"id":"1","title":"","foo":1}`, "invalid_fields", []FieldError{
+			{"foo", "unknown", "is not a known field"},
+		}},
+		{`{"id":1}`, "invalid_fields", []FieldError{
+			{"id", "type", "must be a string"},
+		}},
+		{`{"id":"1","title":""} {}`, "bad_json", nil},
+		{`{"id":`, "bad_json", nil},
+	}
+	for _, tt := range tests {
+		var d validateData
+		resp := parseBody(tt.body, &d)
+		if tt.code == "" {
+			if resp != nil {
+				t.Errorf("ParseBody(%s) = %v, want nil", tt.body, resp)
+			}
+			continue
+		}
+		if resp == nil {
+			t.Errorf("ParseBody(%s) = nil, want %s", tt.body, tt.code)
+			continue
+		}
+		var got struct {
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields"`
+		}
+		b, _ := json.Marshal(resp)
+		json.Unmarshal(b, &got)
+		if got.Error != tt.code || !reflect.DeepEqual(got.Fields, tt.fields) {
+			t.Errorf("ParseBody(%s) = %s, want %s %v", tt.body, b, tt.code, tt.fields)
+		}
+	}
+}
+
+func TestParseBodyTooLarge(t *testing.T) {
+	var d validateData
+	body := make([]byte, MaxBodySize+1)
+	if resp := parseBody(string(body), &d); resp != ErrBodyTooLarge {
+		t.Errorf("ParseBody = %v, want ErrBodyTooLarge", resp)
+	}
+}
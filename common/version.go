@@ -0,0 +1,5 @@
+package common
+
+// Version is the version of the API, set when building with
+// -ldflags "-X github.com/osuAkatsuki/akatsuki-api/common.Version=...".
+var Version = "dev"
@@ -3,14 +3,18 @@ module github.com/osuAkatsuki/akatsuki-api
 go 1.20
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/buaazp/fasthttprouter v0.1.1
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/jmoiron/sqlx v1.3.4
 	github.com/joho/godotenv v1.5.1
 	github.com/microcosm-cc/bluemonday v1.0.24
+	github.com/prometheus/client_golang v1.19.1
 	github.com/serenize/snaker v0.0.0-20201027110005-a7ad2135616e
 	github.com/thehowl/go-osuapi v0.0.0-20181219091033-b29455689881
 	github.com/valyala/fasthttp v1.34.0
+	golang.org/x/crypto v0.25.0
 	gopkg.in/redis.v5 v5.2.9
 	gopkg.in/thehowl/go-osuapi.v1 v1.0.0-20170312091738-23480db9e43c
 	zxq.co/ripple/ocl v0.0.0-20190423081600-ba6c1b2f7885
@@ -20,6 +24,18 @@ require (
 
 require github.com/go-resty/resty/v2 v2.16.2 // indirect
 
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20220504180219-658193537a64 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
 require (
 	github.com/andybalholm/brotli v1.0.4 // indirect
 	github.com/aymerick/douceur v0.2.0 // indirect
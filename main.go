@@ -3,10 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"golang.org/x/exp/slog"
 
 	"github.com/osuAkatsuki/akatsuki-api/app"
+	v1 "github.com/osuAkatsuki/akatsuki-api/app/v1"
 	"github.com/osuAkatsuki/akatsuki-api/common"
 	"github.com/valyala/fasthttp"
 
@@ -47,9 +51,78 @@ func main() {
 
 	engine := app.Start(db)
 
-	err = fasthttp.ListenAndServe(fmt.Sprintf(":%d", settings.APP_PORT), engine.Handler)
-	if err != nil {
-		slog.Error("Unable to start server", "error", err.Error())
+	server := &fasthttp.Server{
+		Handler: engine.Handler,
+		// idle keep-alive connections would otherwise stop Shutdown from
+		// ever returning.
+		IdleTimeout:     time.Minute,
+		CloseOnShutdown: true,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe(fmt.Sprintf(":%d", settings.APP_PORT))
+	}()
+
+	// the metrics are on their own port, so that they are not public.
+	var metricsServer *fasthttp.Server
+	if settings.METRICS_PORT != 0 {
+		metricsServer = &fasthttp.Server{Handler: app.MetricsHandler}
+		go func() {
+			if err := metricsServer.ListenAndServe(fmt.Sprintf(":%d", settings.METRICS_PORT)); err != nil {
+				slog.Error("Unable to start metrics server", "error", err.Error())
+			}
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			slog.Error("Unable to start server", "error", err.Error())
+		}
+	case sig := <-signals:
+		slog.Info("Shutting down", "signal", sig.String())
+		shutdown(server, settings)
+	}
+	if metricsServer != nil {
+		metricsServer.Shutdown()
+	}
+
+	app.Shutdown()
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing DB connection", "error", err.Error())
+	}
+	slog.Info("Goodbye")
+}
+
+// shutdown gracefully stops the server. The health check starts failing
+// right away, and requests keep being served for SHUTDOWN_DRAIN_DELAY seconds
+// so that the load balancer has time to notice. The server then stops
+// accepting connections, and waits at most SHUTDOWN_TIMEOUT seconds for the
+// requests in flight to complete, after which they are cancelled.
+func shutdown(server *fasthttp.Server, settings common.Settings) {
+	v1.SetDraining()
+	time.Sleep(time.Duration(settings.SHUTDOWN_DRAIN_DELAY) * time.Second)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			slog.Error("Error shutting down server", "error", err.Error())
+		}
+	case <-time.After(time.Duration(settings.SHUTDOWN_TIMEOUT) * time.Second):
+		slog.Warn("Timed out waiting for requests in flight to complete, cancelling them")
+		app.AbortRequests()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
 	}
 }
 
@@ -0,0 +1,4 @@
+-- API tokens can have an expiry, as a unix timestamp. Tokens past it are
+-- refused, and NULL means the token never expires.
+ALTER TABLE tokens
+	ADD COLUMN expires_at INT UNSIGNED NULL DEFAULT NULL;
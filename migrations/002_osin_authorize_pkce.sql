@@ -0,0 +1,6 @@
+-- PKCE (RFC 7636): the code challenge of an authorization request, checked
+-- against the code_verifier when the code is exchanged for a token. Empty
+-- when the client did not use PKCE.
+ALTER TABLE osin_authorize
+	ADD COLUMN code_challenge VARCHAR(128) NOT NULL DEFAULT '',
+	ADD COLUMN code_challenge_method VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- The last time each OAuth access token was used, written in batches by the
+-- API. NULL for tokens never used since the column was added.
+ALTER TABLE osin_access
+	ADD COLUMN last_used DATETIME NULL DEFAULT NULL;
@@ -0,0 +1,18 @@
+-- Changes made through the API: who made them, with which token and route,
+-- and the state of the entity changed before and after, as JSON.
+-- token_id is -1 for OAuth access tokens.
+CREATE TABLE audit_log (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	token_id INT NOT NULL,
+	route VARCHAR(128) NOT NULL,
+	target_type VARCHAR(32) NOT NULL,
+	target_id VARCHAR(64) NOT NULL,
+	before_state JSON NULL DEFAULT NULL,
+	after_state JSON NULL DEFAULT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created_at INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id),
+	KEY user_id (user_id),
+	KEY target (target_type, target_id)
+);
@@ -0,0 +1,13 @@
+-- Moderation actions taken against users through the API. expires_at is set
+-- for silences only.
+CREATE TABLE user_moderation_history (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	moderator_id INT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	reason VARCHAR(2048) NOT NULL,
+	expires_at INT UNSIGNED NULL DEFAULT NULL,
+	created_at INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id),
+	KEY user_id (user_id)
+);
@@ -0,0 +1,18 @@
+-- Changes of the ranked status of beatmaps made through the API, one row per
+-- beatmap changed. frozen is whether the status is frozen after the change.
+CREATE TABLE beatmap_status_history (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	beatmap_id INT NOT NULL,
+	beatmapset_id INT NOT NULL,
+	beatmap_md5 CHAR(32) NOT NULL,
+	user_id INT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	old_status TINYINT NOT NULL,
+	new_status TINYINT NOT NULL,
+	frozen TINYINT(1) NOT NULL,
+	reason VARCHAR(2048) NOT NULL,
+	created_at INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id),
+	KEY beatmap_id (beatmap_id),
+	KEY beatmapset_id (beatmapset_id)
+);
@@ -0,0 +1,26 @@
+-- The settings that can be changed while the server is running. The table is
+-- part of the original schema, and is only created if missing. Settings
+-- without a row have their default value.
+CREATE TABLE IF NOT EXISTS system_settings (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	name VARCHAR(32) NOT NULL,
+	value_int INT NOT NULL DEFAULT 0,
+	value_string VARCHAR(512) NOT NULL DEFAULT '',
+	PRIMARY KEY (id),
+	UNIQUE KEY name (name)
+);
+
+-- Announcements shown on the website or in bancho from starts_at until
+-- ends_at, or until they are deleted if ends_at is NULL.
+CREATE TABLE global_alerts (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	message VARCHAR(1024) NOT NULL,
+	severity ENUM('info', 'warning', 'critical') NOT NULL,
+	target ENUM('all', 'website', 'bancho') NOT NULL DEFAULT 'all',
+	starts_at INT UNSIGNED NOT NULL,
+	ends_at INT UNSIGNED NULL DEFAULT NULL,
+	created_by INT NOT NULL,
+	created_at INT UNSIGNED NOT NULL,
+	PRIMARY KEY (id),
+	KEY active (starts_at, ends_at)
+);
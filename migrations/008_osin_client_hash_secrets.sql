@@ -0,0 +1,6 @@
+-- Client secrets are stored as their sha256 hash. Hash the ones stored in
+-- plain text before; the API also hashes them the first time the client
+-- authenticates, for clients added by other services in the meantime.
+UPDATE osin_client
+	SET secret = SHA2(secret, 256)
+	WHERE secret != '' AND secret NOT REGEXP '^[0-9a-f]{64}$';